@@ -0,0 +1,155 @@
+/*
+Package capture records the traffic between a client and a robot, and replays
+it, so that problems seen with a real robot can be reproduced in tests.
+
+A capture is a text file with a record per line: the time since the start
+of the capture in seconds, the direction, ">" from the host to the robot or
+"<" from the robot to the host, and the bytes in hex. Lines starting with
+"#" are comments.
+
+	# go-roomba capture started 2026-10-19T12:00:00Z
+	0.000000 > 80
+	0.000105 > 83
+	0.015021 < 13 05 1d 02 19 0d 00 a3
+
+A Recorder wraps the connection to a robot and writes the capture, a
+Replayer plays the robot's side of a capture back to a client.
+*/
+package capture
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Direction is the direction of recorded data.
+type Direction byte
+
+const (
+	// HostToRobot is data written by the client.
+	HostToRobot Direction = '>'
+	// RobotToHost is data read by the client.
+	RobotToHost Direction = '<'
+)
+
+// Record is data transferred in one direction at once.
+type Record struct {
+	// Time since the start of the capture.
+	Time      time.Duration
+	Direction Direction
+	Data      []byte
+}
+
+// String formats the record as a line of a capture, without the newline.
+func (r Record) String() string {
+	return fmt.Sprintf("%d.%06d %c % x", r.Time/time.Second,
+		r.Time%time.Second/time.Microsecond, r.Direction, r.Data)
+}
+
+// Writer writes records in the capture format. Should be constructed with
+// MakeWriter() function.
+type Writer struct {
+	w io.Writer
+}
+
+// MakeWriter creates a Writer, writing a header with the start time of the
+// capture to w.
+func MakeWriter(w io.Writer, start time.Time) (*Writer, error) {
+	if _, err := fmt.Fprintf(w, "# go-roomba capture started %s\n", start.Format(time.RFC3339Nano)); err != nil {
+		return nil, err
+	}
+	return &Writer{w}, nil
+}
+
+// Write writes a record.
+func (w *Writer) Write(r Record) error {
+	_, err := fmt.Fprintln(w.w, r)
+	return err
+}
+
+// Reader reads records in the capture format. Should be constructed with
+// MakeReader() function.
+type Reader struct {
+	s    *bufio.Scanner
+	line int
+}
+
+// MakeReader creates a Reader reading from r.
+func MakeReader(r io.Reader) *Reader {
+	return &Reader{s: bufio.NewScanner(r)}
+}
+
+// Read returns the next record, or io.EOF at the end of the capture.
+func (r *Reader) Read() (Record, error) {
+	for r.s.Scan() {
+		r.line++
+		line := strings.TrimSpace(r.s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		record, err := parseRecord(line)
+		if err != nil {
+			return Record{}, fmt.Errorf("line %d: %s", r.line, err)
+		}
+		return record, nil
+	}
+	if err := r.s.Err(); err != nil {
+		return Record{}, err
+	}
+	return Record{}, io.EOF
+}
+
+// ReadAll reads all the records of a capture.
+func ReadAll(r io.Reader) ([]Record, error) {
+	reader := MakeReader(r)
+	var records []Record
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return records, err
+		}
+		records = append(records, record)
+	}
+}
+
+func parseRecord(line string) (Record, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return Record{}, fmt.Errorf("invalid record %q", line)
+	}
+	seconds, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return Record{}, fmt.Errorf("invalid time %q", fields[0])
+	}
+	r := Record{Time: time.Duration(seconds*1e6+0.5) * time.Microsecond}
+	switch fields[1] {
+	case string(HostToRobot), string(RobotToHost):
+		r.Direction = Direction(fields[1][0])
+	default:
+		return Record{}, fmt.Errorf("invalid direction %q", fields[1])
+	}
+	r.Data, err = hex.DecodeString(strings.Join(fields[2:], ""))
+	if err != nil {
+		return Record{}, err
+	}
+	return r, nil
+}
+
+// Bytes returns the data of the records in the given direction, joined.
+func Bytes(records []Record, direction Direction) []byte {
+	var data []byte
+	for _, r := range records {
+		if r.Direction == direction {
+			data = append(data, r.Data...)
+		}
+	}
+	return data
+}
@@ -0,0 +1,139 @@
+package capture_test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xa4a/go-roomba"
+	"github.com/xa4a/go-roomba/capture"
+	"github.com/xa4a/go-roomba/clock"
+	"github.com/xa4a/go-roomba/constants"
+	"github.com/xa4a/go-roomba/sim"
+)
+
+func TestReadWrite(t *testing.T) {
+	records := []capture.Record{
+		{Time: 0, Direction: capture.HostToRobot, Data: []byte{128}},
+		{Time: 105 * time.Microsecond, Direction: capture.HostToRobot, Data: []byte{131}},
+		{Time: 2*time.Second + 15021*time.Microsecond, Direction: capture.RobotToHost,
+			Data: []byte{19, 5, 29, 2, 25, 13, 0, 163}},
+	}
+	buf := &bytes.Buffer{}
+	w, err := capture.MakeWriter(buf, time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range records {
+		w.Write(r)
+	}
+	want := "# go-roomba capture started 2026-10-19T12:00:00Z\n" +
+		"0.000000 > 80\n" +
+		"0.000105 > 83\n" +
+		"2.015021 < 13 05 1d 02 19 0d 00 a3\n"
+	if buf.String() != want {
+		t.Errorf("got capture\n%s\nwant\n%s", buf, want)
+	}
+
+	read, err := capture.ReadAll(buf)
+	if err != nil {
+		t.Fatalf("failed reading capture: %s", err)
+	}
+	if !reflect.DeepEqual(read, records) {
+		t.Errorf("read records %v, want %v", read, records)
+	}
+
+	if _, err := capture.ReadAll(strings.NewReader("0.1 > 80\n0.2 ? 80\n")); err == nil ||
+		!strings.Contains(err.Error(), "line 2") {
+		t.Errorf("got error %v for an invalid direction on line 2", err)
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	roombaSim, socket := sim.MakeRoombaSim()
+	defer roombaSim.Stop()
+	buf := &bytes.Buffer{}
+	recorder, err := capture.MakeRecorder(socket, buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packets := []byte{constants.SENSOR_DISTANCE, constants.SENSOR_REQUESTED_VELOCITY}
+	session := func(r *roomba.Roomba) (mode []byte, frames [][][]byte) {
+		r.Start()
+		r.Safe()
+		r.DriveStraight(100)
+		mode, err := r.Sensors(constants.SENSOR_OI_MODE)
+		if err != nil {
+			t.Fatalf("failed reading sensors: %s", err)
+		}
+		out, err := r.Stream(packets)
+		if err != nil {
+			t.Fatalf("failed starting stream: %s", err)
+		}
+		for i := 0; i < 3; i++ {
+			frames = append(frames, <-out)
+		}
+		return mode, frames
+	}
+	mode, frames := session(&roomba.Roomba{S: recorder, StreamPaused: make(chan bool, 1)})
+	if err := recorder.Err(); err != nil {
+		t.Fatalf("failed recording: %s", err)
+	}
+
+	records, err := capture.ReadAll(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("failed reading capture: %s", err)
+	}
+	host := capture.Bytes(records, capture.HostToRobot)
+	if !bytes.Equal(host, roombaSim.Received()) {
+		t.Errorf("recorded % x written by the host, the simulator received % x", host, roombaSim.Received())
+	}
+	if robot := capture.Bytes(records, capture.RobotToHost); !bytes.HasPrefix(roombaSim.Sent(), robot) {
+		t.Errorf("recorded % x read by the host, the simulator sent % x", robot, roombaSim.Sent())
+	}
+
+	replayer := capture.MakeReplayer(records, capture.ReplayConfig{})
+	replayedMode, replayedFrames := session(&roomba.Roomba{S: replayer, StreamPaused: make(chan bool, 1)})
+	if !bytes.Equal(replayedMode, mode) || !reflect.DeepEqual(replayedFrames, frames) {
+		t.Errorf("replayed mode %v and frames %v, recorded %v and %v", replayedMode, replayedFrames, mode, frames)
+	}
+	if !bytes.Equal(replayer.Written(), host) {
+		t.Errorf("replaying client wrote % x, recorded % x", replayer.Written(), host)
+	}
+}
+
+func TestReplayOriginalTiming(t *testing.T) {
+	clk := clock.MakeVirtual(time.Unix(0, 0))
+	records := []capture.Record{
+		{Time: 0, Direction: capture.RobotToHost, Data: []byte{1}},
+		{Time: 10 * time.Millisecond, Direction: capture.HostToRobot, Data: []byte{142, 7}},
+		{Time: time.Second, Direction: capture.RobotToHost, Data: []byte{2, 3}},
+	}
+	replayer := capture.MakeReplayer(records, capture.ReplayConfig{OriginalTiming: true, Clock: clk})
+
+	buf := make([]byte, 10)
+	if n, err := replayer.Read(buf); n != 1 || err != nil {
+		t.Fatalf("first read returned %d, %v", n, err)
+	}
+	done := make(chan []byte)
+	go func() {
+		n, _ := replayer.Read(buf)
+		done <- buf[:n]
+	}()
+	<-clk.Blocked(1)
+	clk.Advance(time.Second - time.Millisecond)
+	select {
+	case <-done:
+		t.Fatalf("data read before its time")
+	case <-time.After(10 * time.Millisecond):
+	}
+	clk.Advance(time.Millisecond)
+	if data := <-done; !bytes.Equal(data, []byte{2, 3}) {
+		t.Errorf("read % x, want 02 03", data)
+	}
+	if _, err := replayer.Read(buf); err == nil {
+		t.Errorf("no error at the end of the capture")
+	}
+}
@@ -0,0 +1,77 @@
+package capture
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/xa4a/go-roomba/clock"
+)
+
+// Recorder is a connection to a robot that records all the data going
+// through it. Use it as Roomba.S in place of the connection it wraps.
+// Should be constructed with MakeRecorder() function.
+type Recorder struct {
+	rw    io.ReadWriter
+	clock clock.Clock
+	start time.Time
+
+	mu  sync.Mutex
+	w   *Writer
+	err error // First error writing the capture.
+}
+
+// MakeRecorder wraps rw, recording its traffic to w. Times are taken from
+// clk, the real clock if nil.
+func MakeRecorder(rw io.ReadWriter, w io.Writer, clk clock.Clock) (*Recorder, error) {
+	clk = clock.OrReal(clk)
+	start := clk.Now()
+	writer, err := MakeWriter(w, start)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{rw: rw, clock: clk, start: start, w: writer}, nil
+}
+
+// Read reads from the wrapped connection, recording the data read.
+func (r *Recorder) Read(p []byte) (int, error) {
+	n, err := r.rw.Read(p)
+	if n > 0 {
+		r.record(RobotToHost, p[:n])
+	}
+	return n, err
+}
+
+// Write writes to the wrapped connection, recording the data written.
+func (r *Recorder) Write(p []byte) (int, error) {
+	n, err := r.rw.Write(p)
+	if n > 0 {
+		r.record(HostToRobot, p[:n])
+	}
+	return n, err
+}
+
+// Close closes the wrapped connection, if it can be closed.
+func (r *Recorder) Close() error {
+	if c, ok := r.rw.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Err returns the first error writing the capture, if any. Failing to write
+// the capture doesn't fail the traffic being recorded.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+func (r *Recorder) record(direction Direction, data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	record := Record{Time: r.clock.Now().Sub(r.start), Direction: direction, Data: data}
+	if err := r.w.Write(record); err != nil && r.err == nil {
+		r.err = err
+	}
+}
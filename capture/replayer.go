@@ -0,0 +1,86 @@
+package capture
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/xa4a/go-roomba/clock"
+)
+
+// ReplayConfig configures a Replayer.
+type ReplayConfig struct {
+	// OriginalTiming delays the data of each record until its time in the
+	// capture, counted from the creation of the Replayer. Otherwise data is
+	// read as fast as possible.
+	OriginalTiming bool
+
+	// Clock used for OriginalTiming, the real clock if nil.
+	Clock clock.Clock
+}
+
+// Replayer is a connection to a robot which plays back the data the robot
+// sent in a capture. Use it as Roomba.S to feed the client recorded data.
+// Reads return io.EOF at the end of the capture. Data written by the client
+// is kept, to be compared with the capture. Should be constructed with
+// MakeReplayer() function.
+type Replayer struct {
+	config ReplayConfig
+	start  time.Time
+
+	readMu  sync.Mutex
+	records []Record // Robot to host records still to be read.
+
+	writeMu sync.Mutex
+	written []byte
+}
+
+// MakeReplayer creates a Replayer for the robot to host records of a
+// capture.
+func MakeReplayer(records []Record, config ReplayConfig) *Replayer {
+	config.Clock = clock.OrReal(config.Clock)
+	r := &Replayer{config: config, start: config.Clock.Now()}
+	for _, record := range records {
+		if record.Direction == RobotToHost && len(record.Data) > 0 {
+			r.records = append(r.records, record)
+		}
+	}
+	return r
+}
+
+// Read reads the data of the next record, waiting for its time with
+// OriginalTiming.
+func (r *Replayer) Read(p []byte) (int, error) {
+	r.readMu.Lock()
+	defer r.readMu.Unlock()
+	if len(r.records) == 0 {
+		return 0, io.EOF
+	}
+	next := &r.records[0]
+	if r.config.OriginalTiming {
+		if wait := r.start.Add(next.Time).Sub(r.config.Clock.Now()); wait > 0 {
+			r.config.Clock.Sleep(wait)
+		}
+	}
+	n := copy(p, next.Data)
+	next.Data = next.Data[n:]
+	if len(next.Data) == 0 {
+		r.records = r.records[1:]
+	}
+	return n, nil
+}
+
+// Write keeps p, to be returned by Written.
+func (r *Replayer) Write(p []byte) (int, error) {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+	r.written = append(r.written, p...)
+	return len(p), nil
+}
+
+// Written returns all the data written so far.
+func (r *Replayer) Written() []byte {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+	return append([]byte{}, r.written...)
+}
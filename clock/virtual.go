@@ -0,0 +1,227 @@
+package clock
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// Virtual is a clock that only moves when Advance is called. Should be
+// constructed with MakeVirtual() function.
+//
+// Timers due at the same instant fire in a fixed order: AfterFunc callbacks
+// first, in the order they were created, then channel timers (After, Sleep
+// and tickers). Callbacks run synchronously in Advance, so anything they do
+// is complete when Advance returns. Goroutines woken through channels run
+// concurrently; Blocked lets a test wait until they are waiting on the clock
+// again.
+type Virtual struct {
+	mu       sync.Mutex
+	now      time.Time
+	timers   timerHeap
+	seq      int
+	waiters  int // Goroutines blocked in Sleep or on After.
+	watchers []watcher
+}
+
+type watcher struct {
+	n    int
+	done chan struct{}
+}
+
+// MakeVirtual creates a virtual clock set to start.
+func MakeVirtual(start time.Time) *Virtual {
+	return &Virtual{now: start}
+}
+
+type virtualTimer struct {
+	v      *Virtual
+	when   time.Time
+	seq    int
+	index  int // In the heap, -1 when not scheduled.
+	f      func()
+	c      chan time.Time
+	period time.Duration // For tickers.
+	waiter bool          // Counts towards Virtual.waiters until fired.
+}
+
+// Now returns the current virtual time.
+func (v *Virtual) Now() time.Time {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.now
+}
+
+// Advance moves the clock forward by d, firing all timers due until then.
+func (v *Virtual) Advance(d time.Duration) {
+	v.mu.Lock()
+	end := v.now.Add(d)
+	for len(v.timers) > 0 && !v.timers[0].when.After(end) {
+		t := heap.Pop(&v.timers).(*virtualTimer)
+		v.now = t.when
+		if t.f != nil {
+			v.mu.Unlock()
+			t.f()
+			v.mu.Lock()
+			continue
+		}
+		if t.waiter {
+			v.waiters--
+		}
+		select {
+		case t.c <- t.when:
+		default:
+			// Like time.Ticker, drop ticks nobody is receiving.
+		}
+		if t.period > 0 {
+			t.when = t.when.Add(t.period)
+			v.schedule(t)
+		}
+	}
+	v.now = end
+	v.mu.Unlock()
+}
+
+// Blocked returns a channel that is closed once at least n goroutines are
+// blocked in Sleep or waiting on a channel returned by After.
+func (v *Virtual) Blocked(n int) <-chan struct{} {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	w := watcher{n, make(chan struct{})}
+	if v.waiters >= n {
+		close(w.done)
+	} else {
+		v.watchers = append(v.watchers, w)
+	}
+	return w.done
+}
+
+// schedule must be called with v.mu held.
+func (v *Virtual) schedule(t *virtualTimer) {
+	v.seq++
+	t.seq = v.seq
+	heap.Push(&v.timers, t)
+}
+
+// addWaiter must be called with v.mu held.
+func (v *Virtual) addWaiter() {
+	v.waiters++
+	remaining := v.watchers[:0]
+	for _, w := range v.watchers {
+		if v.waiters >= w.n {
+			close(w.done)
+		} else {
+			remaining = append(remaining, w)
+		}
+	}
+	v.watchers = remaining
+}
+
+func (v *Virtual) newTimer(d time.Duration) *virtualTimer {
+	return &virtualTimer{v: v, when: v.now.Add(d)}
+}
+
+// After returns a channel that receives the time once d has passed. The
+// caller counts as blocked for Blocked until then.
+func (v *Virtual) After(d time.Duration) <-chan time.Time {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	t := v.newTimer(d)
+	t.c = make(chan time.Time, 1)
+	t.waiter = true
+	v.addWaiter()
+	v.schedule(t)
+	return t.c
+}
+
+// Sleep blocks until d has passed.
+func (v *Virtual) Sleep(d time.Duration) {
+	<-v.After(d)
+}
+
+// AfterFunc calls f from Advance once d has passed.
+func (v *Virtual) AfterFunc(d time.Duration, f func()) Timer {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	t := v.newTimer(d)
+	t.f = f
+	v.schedule(t)
+	return t
+}
+
+// NewTicker returns a ticker firing every d.
+func (v *Virtual) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	t := v.newTimer(d)
+	t.c = make(chan time.Time, 1)
+	t.period = d
+	v.schedule(t)
+	return virtualTicker{t}
+}
+
+type virtualTicker struct {
+	t *virtualTimer
+}
+
+func (t virtualTicker) C() <-chan time.Time {
+	return t.t.c
+}
+
+func (t virtualTicker) Stop() {
+	t.t.Stop()
+}
+
+func (t *virtualTimer) Stop() bool {
+	t.v.mu.Lock()
+	defer t.v.mu.Unlock()
+	if t.index < 0 {
+		return false
+	}
+	heap.Remove(&t.v.timers, t.index)
+	if t.waiter {
+		t.v.waiters--
+	}
+	return true
+}
+
+// timerHeap orders timers by due time, then callbacks before channels, then
+// creation.
+type timerHeap []*virtualTimer
+
+func (h timerHeap) Len() int { return len(h) }
+
+func (h timerHeap) Less(i, j int) bool {
+	a, b := h[i], h[j]
+	if !a.when.Equal(b.when) {
+		return a.when.Before(b.when)
+	}
+	if (a.f != nil) != (b.f != nil) {
+		return a.f != nil
+	}
+	return a.seq < b.seq
+}
+
+func (h timerHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *timerHeap) Push(x interface{}) {
+	t := x.(*virtualTimer)
+	t.index = len(*h)
+	*h = append(*h, t)
+}
+
+func (h *timerHeap) Pop() interface{} {
+	old := *h
+	t := old[len(old)-1]
+	old[len(old)-1] = nil
+	t.index = -1
+	*h = old[:len(old)-1]
+	return t
+}
@@ -0,0 +1,94 @@
+package clock_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/xa4a/go-roomba/clock"
+)
+
+var epoch = time.Unix(0, 0)
+
+func TestAdvanceFiresInOrder(t *testing.T) {
+	c := clock.MakeVirtual(epoch)
+	var fired []string
+	record := func(name string) func() {
+		return func() { fired = append(fired, name+" "+c.Now().Sub(epoch).String()) }
+	}
+	c.AfterFunc(20*time.Millisecond, record("b"))
+	c.AfterFunc(10*time.Millisecond, record("a"))
+	c.AfterFunc(20*time.Millisecond, record("c"))
+	stopped := c.AfterFunc(15*time.Millisecond, record("stopped"))
+	if !stopped.Stop() {
+		t.Errorf("Stop() of a pending timer returned false")
+	}
+
+	c.Advance(15 * time.Millisecond)
+	c.Advance(15 * time.Millisecond)
+
+	want := []string{"a 10ms", "b 20ms", "c 20ms"}
+	if !reflect.DeepEqual(fired, want) {
+		t.Errorf("fired %v, want %v", fired, want)
+	}
+	if got := c.Now().Sub(epoch); got != 30*time.Millisecond {
+		t.Errorf("Now() is %v after start, want 30ms", got)
+	}
+}
+
+func TestCallbacksScheduleMore(t *testing.T) {
+	c := clock.MakeVirtual(epoch)
+	ticks := 0
+	var tick func()
+	tick = func() {
+		ticks++
+		c.AfterFunc(10*time.Millisecond, tick)
+	}
+	c.AfterFunc(10*time.Millisecond, tick)
+	c.Advance(time.Second)
+	if ticks != 100 {
+		t.Errorf("got %d ticks in 1s, want 100", ticks)
+	}
+}
+
+func TestTicker(t *testing.T) {
+	c := clock.MakeVirtual(epoch)
+	ticker := c.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	c.Advance(10 * time.Millisecond)
+	if got := <-ticker.C(); !got.Equal(epoch.Add(10 * time.Millisecond)) {
+		t.Errorf("got tick at %v", got)
+	}
+	// Ticks nobody receives are dropped.
+	c.Advance(30 * time.Millisecond)
+	if got := <-ticker.C(); !got.Equal(epoch.Add(20 * time.Millisecond)) {
+		t.Errorf("got tick at %v", got)
+	}
+	select {
+	case got := <-ticker.C():
+		t.Errorf("unexpected tick at %v", got)
+	default:
+	}
+}
+
+func TestSleep(t *testing.T) {
+	c := clock.MakeVirtual(epoch)
+	woke := make(chan time.Time)
+	go func() {
+		c.Sleep(time.Second)
+		woke <- c.Now()
+	}()
+
+	<-c.Blocked(1)
+	c.Advance(999 * time.Millisecond)
+	select {
+	case <-woke:
+		t.Fatalf("Sleep returned early")
+	default:
+	}
+	c.Advance(time.Millisecond)
+	if got := <-woke; !got.Equal(epoch.Add(time.Second)) {
+		t.Errorf("woke at %v", got)
+	}
+}
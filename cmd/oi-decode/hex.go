@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// parseHex decodes a hex dump. Offsets ending with ":", as well as the offset
+// and character columns of hexdump -C, are skipped.
+func parseHex(dump string) ([]byte, error) {
+	var data []byte
+	hexdumpC := false
+	for i, line := range strings.Split(dump, "\n") {
+		fields := strings.FieldsFunc(line, func(r rune) bool {
+			return r == ' ' || r == '\t' || r == '\r' || r == ','
+		})
+		if len(fields) == 0 {
+			continue
+		}
+		if j := strings.Index(line, "|"); j >= 0 {
+			// hexdump -C, which also ends with a line holding just the
+			// offset.
+			hexdumpC = true
+			fields = strings.Fields(line[:j])
+		}
+		if hexdumpC || strings.HasSuffix(fields[0], ":") {
+			fields = fields[1:]
+		}
+		for _, field := range fields {
+			field = strings.TrimPrefix(strings.TrimPrefix(field, "0x"), "0X")
+			b, err := hex.DecodeString(field)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", i+1, err)
+			}
+			data = append(data, b...)
+		}
+	}
+	return data, nil
+}
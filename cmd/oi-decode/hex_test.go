@@ -0,0 +1,30 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseHex(t *testing.T) {
+	want := []byte{0x80, 0x83, 0x89, 0xff, 0x38, 0x01, 0xf4}
+	for _, dump := range []string{
+		"80 83 89 ff 38 01 f4\n",
+		"0x80, 0x83, 0x89, 0xff,\n0x38, 0x01, 0xf4",
+		"808389ff3801f4",
+		"00000000: 80 83 89 ff\n00000004: 38 01 f4",
+		"00000000  80 83 89 ff 38 01 f4                              |....8..|\n00000007\n",
+	} {
+		got, err := parseHex(dump)
+		if err != nil {
+			t.Errorf("failed parsing %q: %s", dump, err)
+			continue
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("parsing %q got % x, want % x", dump, got, want)
+		}
+	}
+
+	if _, err := parseHex("\x80\x83"); err == nil {
+		t.Errorf("raw bytes parsed as hex")
+	}
+}
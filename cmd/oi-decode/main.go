@@ -0,0 +1,151 @@
+/*
+Command oi-decode decodes captured Open Interface traffic.
+
+	oi-decode host.hex
+	oi-decode -host host.bin -robot robot.bin
+	xxd -p capture.bin | oi-decode -robot -
+	oi-decode -capture session.txt
+
+Input files are raw bytes or hex dumps: hex bytes separated by spaces or
+commas, optionally prefixed with 0x, or as printed by xxd -p and hexdump -C.
+Without -host or -robot, the file given as an argument, or stdin, holds bytes
+sent by the host. Responses to Sensors and QueryList commands can only be
+decoded when the host's bytes are given too.
+
+With -capture, both directions are read from a capture recorded with the
+capture package, and printed with their times.
+
+Commands sent by the host are printed prefixed with ">", messages sent by the
+robot with "<".
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/xa4a/go-roomba/capture"
+	"github.com/xa4a/go-roomba/oi"
+)
+
+var (
+	hostPath    = flag.String("host", "", "file with the bytes sent by the host, - for stdin")
+	robotPath   = flag.String("robot", "", "file with the bytes sent by the robot, - for stdin")
+	format      = flag.String("format", "auto", "input format: hex, raw or auto")
+	capturePath = flag.String("capture", "", "capture file recorded with the capture package, - for stdin")
+)
+
+func main() {
+	flag.Parse()
+	if *capturePath != "" {
+		decodeCapture(*capturePath)
+		return
+	}
+	if *hostPath == "" && *robotPath == "" {
+		*hostPath = "-"
+		if flag.NArg() > 0 {
+			*hostPath = flag.Arg(0)
+		}
+	}
+	d := oi.MakeDecoder()
+	if *hostPath != "" {
+		data, err := readInput(*hostPath, *format)
+		if err != nil {
+			log.Fatalf("failed reading host bytes: %s", err)
+		}
+		for _, cmd := range d.Host(data) {
+			fmt.Println(">", cmd)
+		}
+		if rest := d.Incomplete(); len(rest) > 0 {
+			fmt.Printf("> incomplete [% x]\n", rest)
+		}
+	}
+	if *robotPath != "" {
+		data, err := readInput(*robotPath, *format)
+		if err != nil {
+			log.Fatalf("failed reading robot bytes: %s", err)
+		}
+		for _, msg := range append(d.Robot(data), d.Flush()...) {
+			fmt.Println("<", msg)
+		}
+	}
+}
+
+// readInput reads the file at path, or stdin for "-", and decodes it in the
+// given format.
+func readInput(path, format string) ([]byte, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case "raw":
+		return data, nil
+	case "hex":
+		return parseHex(string(data))
+	case "auto":
+		if decoded, err := parseHex(string(data)); err == nil {
+			return decoded, nil
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+// decodeCapture prints the decoded traffic of a capture, in the order it was
+// recorded.
+func decodeCapture(path string) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			log.Fatalf("failed opening capture: %s", err)
+		}
+		defer f.Close()
+		r = f
+	}
+	records, err := capture.ReadAll(r)
+	if err != nil {
+		log.Fatalf("failed reading capture: %s", err)
+	}
+	d := oi.MakeDecoder()
+	var last capture.Record
+	for _, record := range records {
+		last = record
+		prefix := recordPrefix(record.Time, record.Direction)
+		if record.Direction == capture.HostToRobot {
+			for _, cmd := range d.Host(record.Data) {
+				fmt.Println(prefix, cmd)
+			}
+		} else {
+			for _, msg := range d.Robot(record.Data) {
+				fmt.Println(prefix, msg)
+			}
+		}
+	}
+	if rest := d.Incomplete(); len(rest) > 0 {
+		fmt.Printf("> incomplete [% x]\n", rest)
+	}
+	for _, msg := range d.Flush() {
+		fmt.Println(recordPrefix(last.Time, capture.RobotToHost), msg)
+	}
+}
+
+// recordPrefix formats the time and direction of a record like the capture.
+func recordPrefix(t time.Duration, direction capture.Direction) string {
+	return strings.TrimSpace(capture.Record{Time: t, Direction: direction}.String())
+}
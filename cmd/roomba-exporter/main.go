@@ -0,0 +1,64 @@
+/*
+Command roomba-exporter serves the battery, current and event counters of a
+Roomba as Prometheus metrics.
+
+	roomba-exporter -port /dev/ttyUSB0 -listen :9750
+	roomba-exporter -sim
+
+The sensors are streamed from the robot and the latest frame is served on
+/metrics, so scrapes cost nothing on the serial link. Gauges disappear when
+no frame arrived for -max-age, and roomba_up drops to 0. The stream resyncs
+after line noise, and is restarted if it ends.
+*/
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/xa4a/go-roomba"
+	"github.com/xa4a/go-roomba/metrics"
+	"github.com/xa4a/go-roomba/sim"
+)
+
+var (
+	port   = flag.String("port", "/dev/ttyUSB0", "serial port of the robot")
+	useSim = flag.Bool("sim", false, "export a simulated robot instead of the one on -port")
+	listen = flag.String("listen", ":9750", "address to serve the metrics on")
+	maxAge = flag.Duration("max-age", metrics.DefaultMaxAge, "age of the last frame after which gauges aren't exported")
+)
+
+func main() {
+	flag.Parse()
+
+	var r *roomba.Roomba
+	if *useSim {
+		roombaSim, socket := sim.MakeRoombaSim()
+		defer roombaSim.Stop()
+		r = &roomba.Roomba{S: socket, StreamPaused: make(chan bool, 1)}
+		log.Printf("exporting a simulated robot")
+	} else {
+		var err error
+		if r, err = roomba.MakeRoomba(*port); err != nil {
+			log.Fatalf("failed to open %s: %s", *port, err)
+		}
+	}
+	if err := r.Start(); err != nil {
+		log.Fatalf("failed to start the OI: %s", err)
+	}
+
+	e := metrics.MakeExporter(metrics.Config{
+		MaxAge:         *maxAge,
+		ChecksumErrors: r.ChecksumErrors,
+		FramingErrors:  r.FramingErrors,
+	})
+	go func() {
+		log.Fatalf("stream failed: %s", e.RunStream(context.Background(), r))
+	}()
+
+	http.Handle("/metrics", e.Handler())
+	log.Printf("serving metrics on %s/metrics", *listen)
+	log.Fatal(http.ListenAndServe(*listen, nil))
+}
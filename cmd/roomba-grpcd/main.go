@@ -0,0 +1,65 @@
+/*
+Command roomba-grpcd serves the Roomba gRPC service of the rpc package,
+defined in rpc/roombapb/roomba.proto, for a robot attached to this host.
+
+	roomba-grpcd -port /dev/ttyUSB0 -listen :50051
+	roomba-grpcd -sim
+
+Go programs control the robot with an rpc.Client, which implements
+roomba.Robot like a local roomba.Roomba. Other languages use the code
+generated from roomba.proto. The server supports reflection, so that tools
+like grpcurl can call it without the .proto file:
+
+	grpcurl -plaintext -d '{"velocity": 200, "radius": 500}' localhost:50051 roomba.Roomba/Drive
+*/
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+
+	"github.com/xa4a/go-roomba"
+	"github.com/xa4a/go-roomba/rpc"
+	"github.com/xa4a/go-roomba/rpc/roombapb"
+	"github.com/xa4a/go-roomba/sim"
+)
+
+var (
+	port   = flag.String("port", "/dev/ttyUSB0", "serial port of the robot")
+	useSim = flag.Bool("sim", false, "serve a simulated robot instead of the one on -port")
+	listen = flag.String("listen", ":50051", "address to serve the gRPC service on")
+)
+
+func main() {
+	flag.Parse()
+
+	var r *roomba.Roomba
+	if *useSim {
+		roombaSim, socket := sim.MakeRoombaSim()
+		defer roombaSim.Stop()
+		r = &roomba.Roomba{S: socket, StreamPaused: make(chan bool, 1)}
+		log.Printf("serving a simulated robot")
+	} else {
+		var err error
+		if r, err = roomba.MakeRoomba(*port); err != nil {
+			log.Fatalf("failed to open %s: %s", *port, err)
+		}
+	}
+	if err := r.Start(); err != nil {
+		log.Fatalf("failed to start the OI: %s", err)
+	}
+
+	lis, err := net.Listen("tcp", *listen)
+	if err != nil {
+		log.Fatalf("failed to listen on %s: %s", *listen, err)
+	}
+	s := grpc.NewServer()
+	roombapb.RegisterRoombaServer(s, rpc.MakeServer(r))
+	reflection.Register(s)
+	log.Printf("serving gRPC on %s", *listen)
+	log.Fatal(s.Serve(lis))
+}
@@ -0,0 +1,362 @@
+// Dashboard of roomba-httpd. Telemetry comes from the /ws WebSocket, which the
+// page also drives the robot through while its joystick is in use.
+"use strict";
+
+const MAX_LINEAR = 0.3;  // m/s at full joystick deflection.
+const MAX_ANGULAR = 2;   // rad/s.
+const DRIVE_PERIOD = 200; // ms between drive commands, below the dead-man timeout.
+const CONTROL_IDLE = 10000; // ms the joystick may be idle before the page gives up control.
+const TRAIL_LENGTH = 5000;
+
+// Songs stored on the robot by the song buttons. Notes are MIDI numbers and
+// durations in 1/64 s; numbers below 31 are rests.
+const SONGS = [
+  {name: "Beep", notes: [[72, 8], [76, 8]]},
+  {name: "Locate", notes: [[84, 16], [0, 8], [84, 16], [0, 8], [84, 16], [0, 8], [88, 32]]},
+  {name: "Charge", notes: [[67, 10], [72, 10], [76, 10], [79, 20], [76, 10], [79, 30]]},
+  {name: "Scale", notes: [[60, 12], [62, 12], [64, 12], [65, 12], [67, 12], [69, 12], [71, 12], [72, 24]]},
+];
+
+const $ = (id) => document.getElementById(id);
+
+function showError(message) {
+  $("error").textContent = message;
+}
+
+async function request(method, path, body) {
+  const options = {method};
+  if (body !== undefined) {
+    options.body = JSON.stringify(body);
+    options.headers = {"Content-Type": "application/json"};
+  }
+  const resp = await fetch(path, options);
+  if (!resp.ok) {
+    let message = resp.statusText;
+    try {
+      message = (await resp.json()).error;
+    } catch (e) {
+      // Not a JSON error.
+    }
+    throw new Error(`${method} ${path}: ${message}`);
+  }
+  showError("");
+}
+
+function packetMap(packets) {
+  const m = {};
+  for (const p of packets) {
+    m[p.name] = p;
+  }
+  return m;
+}
+
+// Telemetry.
+
+function showBattery(p) {
+  if (!p.battery_charge || !p.battery_capacity || !p.battery_capacity.value) {
+    return;
+  }
+  const percent = Math.round(100 * p.battery_charge.value / p.battery_capacity.value);
+  const level = $("battery-level");
+  level.style.width = Math.min(100, percent) + "%";
+  level.classList.toggle("low", percent < 20);
+  $("battery-percent").textContent = percent + "%";
+  let detail = `${p.battery_charge.value} / ${p.battery_capacity.value} mAh`;
+  if (p.voltage) {
+    detail += `, ${(p.voltage.value / 1000).toFixed(2)} V`;
+  }
+  if (p.current) {
+    detail += `, ${p.current.value} mA`;
+  }
+  if (p.temperature) {
+    detail += `, ${p.temperature.value} °C`;
+  }
+  $("battery-detail").textContent = detail;
+  if (p.charging) {
+    $("charging").textContent = p.charging.note.replace("_", " ");
+  }
+}
+
+function showMode(p) {
+  if (!p.oi_mode) {
+    return;
+  }
+  const mode = $("mode");
+  mode.textContent = "mode: " + (p.oi_mode.note || p.oi_mode.value);
+  mode.classList.toggle("ok", p.oi_mode.note === "safe" || p.oi_mode.note === "full");
+}
+
+function showHazards(p) {
+  const active = [];
+  const set = (id, on) => {
+    $(id).classList.toggle("active", on);
+    if (on) {
+      active.push(id.replaceAll("-", " "));
+    }
+  };
+  const bits = p.bump_wheels_drops ? p.bump_wheels_drops.value : 0;
+  set("bump-right", (bits & 1) !== 0);
+  set("bump-left", (bits & 2) !== 0);
+  set("wheel-drop-right", (bits & 4) !== 0);
+  set("wheel-drop-left", (bits & 8) !== 0);
+  for (const cliff of ["cliff_left", "cliff_front_left", "cliff_front_right", "cliff_right"]) {
+    set(cliff.replaceAll("_", "-"), p[cliff] !== undefined && p[cliff].value !== 0);
+  }
+  $("hazards").textContent = active.length ? active.join(", ") : "none";
+}
+
+// Odometry trail.
+
+let trail = [];
+
+function addPose(pose) {
+  const last = trail[trail.length - 1];
+  if (!last || Math.hypot(pose.x - last.x, pose.y - last.y) > 0.005 || pose.theta !== last.theta) {
+    trail.push(pose);
+    if (trail.length > TRAIL_LENGTH) {
+      trail.shift();
+    }
+  }
+  $("pose").textContent = `x ${pose.x.toFixed(2)} m, y ${pose.y.toFixed(2)} m, ` +
+    `heading ${(pose.theta * 180 / Math.PI).toFixed(0)}°`;
+  drawTrail();
+}
+
+function drawTrail() {
+  const canvas = $("trail");
+  const ctx = canvas.getContext("2d");
+  ctx.clearRect(0, 0, canvas.width, canvas.height);
+  if (!trail.length) {
+    return;
+  }
+  // Fit the trail, showing at least 2 m around it.
+  let minX = Infinity, maxX = -Infinity, minY = Infinity, maxY = -Infinity;
+  for (const p of trail) {
+    minX = Math.min(minX, p.x);
+    maxX = Math.max(maxX, p.x);
+    minY = Math.min(minY, p.y);
+    maxY = Math.max(maxY, p.y);
+  }
+  const span = Math.max(2, maxX - minX, maxY - minY) * 1.2;
+  const scale = Math.min(canvas.width, canvas.height) / span;
+  const cx = (minX + maxX) / 2, cy = (minY + maxY) / 2;
+  // X to the right, Y up.
+  const toCanvas = (p) => [canvas.width / 2 + (p.x - cx) * scale, canvas.height / 2 - (p.y - cy) * scale];
+
+  ctx.strokeStyle = "#1e88e5";
+  ctx.lineWidth = 2;
+  ctx.beginPath();
+  trail.forEach((p, i) => {
+    const [x, y] = toCanvas(p);
+    if (i === 0) {
+      ctx.moveTo(x, y);
+    } else {
+      ctx.lineTo(x, y);
+    }
+  });
+  ctx.stroke();
+
+  const pose = trail[trail.length - 1];
+  const [x, y] = toCanvas(pose);
+  const r = Math.max(4, 0.17 * scale); // Roomba's radius is about 17 cm.
+  ctx.fillStyle = "rgba(68, 68, 68, 0.3)";
+  ctx.beginPath();
+  ctx.arc(x, y, r, 0, 2 * Math.PI);
+  ctx.fill();
+  ctx.strokeStyle = "#444";
+  ctx.beginPath();
+  ctx.moveTo(x, y);
+  ctx.lineTo(x + r * Math.cos(pose.theta), y - r * Math.sin(pose.theta));
+  ctx.stroke();
+}
+
+$("clear-trail").addEventListener("click", () => {
+  trail = trail.slice(-1);
+  drawTrail();
+});
+
+// WebSocket connection. The page watches, and asks for control when the
+// joystick is used, so that it doesn't keep other clients from controlling
+// the robot while nobody drives.
+
+let socket = null;
+let controlling = false;
+let connection = null; // Latest connection, replacing the previous ones.
+let wantControl = false;
+let refused = false; // Whether another client had control.
+
+function connect(control) {
+  if (connection) {
+    connection.close();
+  }
+  socket = null;
+  controlling = false;
+  wantControl = control;
+  const url = new URL("ws", location.href);
+  url.protocol = location.protocol === "https:" ? "wss:" : "ws:";
+  if (control) {
+    url.searchParams.set("control", "true");
+  }
+  const ws = new WebSocket(url);
+  connection = ws;
+  let opened = false;
+  ws.onopen = () => {
+    opened = true;
+    socket = ws;
+    controlling = control;
+    if (control) {
+      refused = false;
+    }
+    const badge = $("connection");
+    badge.textContent = control ? "controlling" : "viewing";
+    badge.className = "badge " + (control ? "ok" : "warn");
+    $("control").textContent = control ? "Drag the joystick to drive." :
+      refused ? "Another client controls the robot." : "Drag the joystick to take control.";
+    if (control && joystick.held) {
+      sendDrive();
+    }
+  };
+  ws.onmessage = (event) => {
+    const m = JSON.parse(event.data);
+    if (m.error) {
+      showError(m.error);
+      return;
+    }
+    const p = packetMap(m.packets);
+    showBattery(p);
+    showMode(p);
+    showHazards(p);
+    addPose(m.odometry);
+  };
+  ws.onclose = () => {
+    if (ws !== connection) {
+      // Replaced.
+      return;
+    }
+    socket = null;
+    controlling = false;
+    if (!opened && control) {
+      // Refused as a controller.
+      refused = true;
+      connect(false);
+      return;
+    }
+    $("connection").textContent = "disconnected";
+    $("connection").className = "badge";
+    setTimeout(() => {
+      if (connection === ws) {
+        connect(false);
+      }
+    }, 2000);
+  };
+}
+
+// Joystick.
+
+const joystick = {x: 0, y: 0, held: false, timer: null, idle: null};
+
+function drawJoystick() {
+  const canvas = $("joystick");
+  const ctx = canvas.getContext("2d");
+  const r = canvas.width / 2;
+  ctx.clearRect(0, 0, canvas.width, canvas.height);
+  ctx.strokeStyle = "#bbb";
+  ctx.beginPath();
+  ctx.moveTo(r, 0);
+  ctx.lineTo(r, 2 * r);
+  ctx.moveTo(0, r);
+  ctx.lineTo(2 * r, r);
+  ctx.stroke();
+  ctx.fillStyle = joystick.held ? "#1e88e5" : "#888";
+  ctx.beginPath();
+  ctx.arc(r + joystick.x * r * 0.8, r + joystick.y * r * 0.8, r * 0.2, 0, 2 * Math.PI);
+  ctx.fill();
+}
+
+function sendDrive() {
+  if (!socket || !controlling) {
+    return;
+  }
+  // Up drives forward, left turns counter-clockwise.
+  socket.send(JSON.stringify({
+    linear: -joystick.y * MAX_LINEAR,
+    angular: -joystick.x * MAX_ANGULAR,
+  }));
+}
+
+function moveJoystick(event) {
+  const rect = $("joystick").getBoundingClientRect();
+  let x = (event.clientX - rect.left) / rect.width * 2 - 1;
+  let y = (event.clientY - rect.top) / rect.height * 2 - 1;
+  const d = Math.hypot(x, y);
+  if (d > 1) {
+    x /= d;
+    y /= d;
+  }
+  joystick.x = x;
+  joystick.y = y;
+  drawJoystick();
+}
+
+$("joystick").addEventListener("pointerdown", (event) => {
+  $("joystick").setPointerCapture(event.pointerId);
+  joystick.held = true;
+  clearTimeout(joystick.idle);
+  if (!wantControl) {
+    connect(true);
+  }
+  moveJoystick(event);
+  sendDrive();
+  // Repeated, so that the dead-man timeout doesn't stop the robot.
+  joystick.timer = setInterval(sendDrive, DRIVE_PERIOD);
+});
+
+$("joystick").addEventListener("pointermove", (event) => {
+  if (joystick.held) {
+    moveJoystick(event);
+  }
+});
+
+function releaseJoystick() {
+  if (!joystick.held) {
+    return;
+  }
+  joystick.held = false;
+  clearInterval(joystick.timer);
+  joystick.x = joystick.y = 0;
+  drawJoystick();
+  sendDrive();
+  joystick.idle = setTimeout(() => {
+    if (wantControl) {
+      connect(false);
+    }
+  }, CONTROL_IDLE);
+}
+
+$("joystick").addEventListener("pointerup", releaseJoystick);
+$("joystick").addEventListener("pointercancel", releaseJoystick);
+
+// Buttons.
+
+for (const button of document.querySelectorAll("[data-post]")) {
+  button.addEventListener("click", () => {
+    request("POST", button.dataset.post).catch((e) => showError(e.message));
+  });
+}
+
+SONGS.forEach((song, i) => {
+  const button = document.createElement("button");
+  button.textContent = song.name;
+  button.addEventListener("click", async () => {
+    try {
+      await request("PUT", `songs/${i}`, {notes: song.notes.map(([number, duration]) => ({number, duration}))});
+      await request("POST", `songs/${i}/play`);
+    } catch (e) {
+      showError(e.message);
+    }
+  });
+  $("songs").appendChild(button);
+});
+
+drawJoystick();
+connect(false);
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"io"
+	"io/fs"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestDashboard(t *testing.T) {
+	_, _, srv := startServer(t)
+	for path, want := range map[string]string{
+		"/":          "<title>Roomba</title>",
+		"/app.js":    "new WebSocket(",
+		"/style.css": "#joystick",
+	} {
+		resp, err := srv.Client().Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s failed: %s", path, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), want) {
+			t.Errorf("GET %s: got %d, without %q", path, resp.StatusCode, want)
+		}
+	}
+}
+
+// The dashboard must work on networks without internet access.
+func TestDashboardOffline(t *testing.T) {
+	external := regexp.MustCompile(`(?i)(https?:)?//[a-z0-9.-]+\.[a-z]{2,}`)
+	fs.WalkDir(dashboardFiles, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, _ := dashboardFiles.ReadFile(path)
+		if url := external.Find(data); url != nil {
+			t.Errorf("%s refers to %s", path, url)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,91 @@
+/*
+Command roomba-httpd serves a REST API controlling a Roomba, so that web and
+mobile clients can drive a robot without linking Go code.
+
+	roomba-httpd -port /dev/ttyUSB0 -listen :8000
+	roomba-httpd -sim
+
+Commands are POSTed, with JSON bodies where they take arguments:
+
+	POST /mode/{passive,safe,full}
+	POST /drive   {"velocity": 200, "radius": 500}, {"right": 100, "left": 150}
+	              or {"linear": 0.2, "angular": 0.5}
+	POST /stop
+	POST /clean, /spot, /max, /dock, /power
+	POST /leds    {"check_robot": true, "power_color": 255, "power_intensity": 128}
+	PUT  /songs/{0-4}  {"notes": [{"number": 60, "duration": 32}]}
+	POST /songs/{0-4}/play
+
+and sensors are read with
+
+	GET /sensors?packets=oi_mode,voltage,22
+
+which returns the decoded packets. Errors are returned as {"error": "..."}.
+
+For driving, a WebSocket at /ws pushes decoded stream frames with the
+odometry integrated from them, at -rate frames per second or the rate the
+client asks for:
+
+	GET /ws?rate=20
+	GET /ws?control=true
+
+Any number of clients can watch, and one at a time can control the robot,
+sending drive commands with the body of POST /drive as messages. POST /drive
+is rejected while a client controls the robot. While the sensors are
+streamed, GET /sensors reads the latest frame.
+
+The root serves a dashboard built on the WebSocket, with the battery level,
+mode, bumpers, cliff sensors and wheel drops, an odometry trail, a joystick
+and song buttons. It watches the robot, takes control when its joystick is
+used and gives it up once the joystick was idle for a while. It is embedded in
+the binary and loads nothing from other hosts.
+
+Motion is guarded by a dead-man timeout: the robot is stopped if no drive
+command arrives within -deadman of the previous one, so a client which loses
+its connection doesn't leave the robot driving. Clients keep the robot moving
+by repeating their drive command. The robot also stops when the WebSocket
+controller disconnects, even with -deadman=0.
+*/
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/xa4a/go-roomba"
+	"github.com/xa4a/go-roomba/sim"
+)
+
+var (
+	port    = flag.String("port", "/dev/ttyUSB0", "serial port of the robot")
+	useSim  = flag.Bool("sim", false, "control a simulated robot instead of the one on -port")
+	listen  = flag.String("listen", ":8000", "address to serve the API on")
+	deadman = flag.Duration("deadman", defaultDeadman, "stop the robot when no drive command arrives for this long; 0 to disable")
+	rate    = flag.Float64("rate", defaultFrameRate, "default frames per second sent to WebSocket clients")
+)
+
+func main() {
+	flag.Parse()
+
+	var r *roomba.Roomba
+	if *useSim {
+		roombaSim, socket := sim.MakeRoombaSim()
+		defer roombaSim.Stop()
+		r = &roomba.Roomba{S: socket, StreamPaused: make(chan bool, 1)}
+		log.Printf("controlling a simulated robot")
+	} else {
+		var err error
+		if r, err = roomba.MakeRoomba(*port); err != nil {
+			log.Fatalf("failed to open %s: %s", *port, err)
+		}
+	}
+	if err := r.Start(); err != nil {
+		log.Fatalf("failed to start the OI: %s", err)
+	}
+
+	s := makeServer(r, *deadman, nil)
+	s.frameRate = *rate
+	log.Printf("serving API on %s", *listen)
+	log.Fatal(http.ListenAndServe(*listen, s.handler()))
+}
@@ -0,0 +1,429 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xa4a/go-roomba"
+	"github.com/xa4a/go-roomba/clock"
+	"github.com/xa4a/go-roomba/constants"
+	"github.com/xa4a/go-roomba/oi"
+)
+
+// defaultDeadman is long enough for a client repeating its drive command a
+// few times a second over a slow network.
+const defaultDeadman = time.Second
+
+// defaultPackets are read by GET /sensors without a packets parameter.
+var defaultPackets = []byte{
+	constants.SENSOR_BUMP_WHEELS_DROPS,
+	constants.SENSOR_OI_MODE,
+	constants.SENSOR_CHARGING,
+	constants.SENSOR_VOLTAGE,
+	constants.SENSOR_CURRENT,
+	constants.SENSOR_TEMPERATURE,
+	constants.SENSOR_BATTERY_CHARGE,
+	constants.SENSOR_BATTERY_CAPACITY,
+	constants.SENSOR_REQUESTED_VELOCITY,
+	constants.SENSOR_REQUESTED_RADIUS,
+}
+
+// server serves the API of a single robot. Should be constructed with
+// makeServer() function.
+type server struct {
+	clock   clock.Clock
+	deadman time.Duration
+
+	// Commands, including the dead-man's stop, don't wait for queries.
+	r *roomba.Serialized
+
+	// mu guards the dead-man timer and the stream, and orders the commands
+	// changing them. It isn't held while querying the robot.
+	mu     sync.Mutex
+	moving clock.Timer // Dead-man timer, nil while the robot isn't driven.
+	// Whether the WebSocket controller drove the robot, which is stopped
+	// when it disconnects.
+	controllerDrove bool
+
+	// The stream of sensor data sent to WebSocket clients. Once it is
+	// started, sensors are read from it.
+	streaming bool // Guarded by mu.
+	hub       hub
+	frameRate float64 // Default frames per second sent to clients.
+}
+
+// makeServer creates a server of the API of r. Drive commands stop the robot
+// after deadman unless repeated, if deadman isn't zero.
+func makeServer(r roomba.Robot, deadman time.Duration, clk clock.Clock) *server {
+	s := &server{r: roomba.MakeSerialized(r), deadman: deadman, clock: clock.OrReal(clk), frameRate: defaultFrameRate}
+	s.r.Clock = s.clock
+	return s
+}
+
+// handler returns the handler of the API.
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /mode/{mode}", s.handleMode)
+	mux.HandleFunc("POST /drive", s.handleDrive)
+	mux.HandleFunc("POST /stop", s.handleStop)
+	for path, command := range map[string]func(roomba.Robot) error{
+		"/clean": roomba.Robot.Clean,
+		"/spot":  roomba.Robot.Spot,
+		"/max":   roomba.Robot.Max,
+		"/dock":  roomba.Robot.SeekDock,
+		"/power": roomba.Robot.Power,
+	} {
+		mux.HandleFunc("POST "+path, func(w http.ResponseWriter, req *http.Request) {
+			// Cleaning takes over the wheels.
+			s.respond(w, s.stopDeadman(command))
+		})
+	}
+	mux.HandleFunc("POST /leds", s.handleLEDs)
+	mux.HandleFunc("PUT /songs/{song}", s.handleSong)
+	mux.HandleFunc("POST /songs/{song}/play", s.handlePlay)
+	mux.HandleFunc("GET /sensors", s.handleSensors)
+	mux.HandleFunc("GET /ws", s.handleWebSocket)
+	mux.Handle("GET /", dashboardHandler())
+	return mux
+}
+
+// httpError is an error in the request, as opposed to one talking to the
+// robot, with the status code of the response.
+type httpError struct {
+	code int
+	err  error
+}
+
+func (e httpError) Error() string {
+	return e.err.Error()
+}
+
+func errorf(code int, format string, args ...interface{}) error {
+	return httpError{code, fmt.Errorf(format, args...)}
+}
+
+func badRequestf(format string, args ...interface{}) error {
+	return errorf(http.StatusBadRequest, format, args...)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// respond replies to a command with 204 No Content, or the error.
+func (s *server) respond(w http.ResponseWriter, err error) {
+	if err == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	code := http.StatusBadGateway
+	var e httpError
+	if errors.As(err, &e) {
+		code = e.code
+	} else if errors.Is(err, roomba.ErrInvalidArgument) {
+		code = http.StatusBadRequest
+	} else if errors.Is(err, roomba.ErrTimeout) {
+		code = http.StatusGatewayTimeout
+	}
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
+
+// decode decodes the JSON body of a request or message into v.
+func decode(r io.Reader, v interface{}) error {
+	d := json.NewDecoder(r)
+	d.DisallowUnknownFields()
+	if err := d.Decode(v); err != nil {
+		return badRequestf("invalid body: %s", err)
+	}
+	return nil
+}
+
+// command sends a command which doesn't change the dead-man timer to the
+// robot.
+func (s *server) command(f func(roomba.Robot) error) error {
+	return f(s.r)
+}
+
+// drive sends a drive command to the robot and restarts the dead-man timer.
+func (s *server) drive(f func(roomba.Robot) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := f(s.r); err != nil {
+		return err
+	}
+	s.resetDeadman()
+	return nil
+}
+
+// stopDeadman sends a command which stops the drive commands, and so the
+// dead-man timer.
+func (s *server) stopDeadman(f func(roomba.Robot) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.moving != nil {
+		s.moving.Stop()
+		s.moving = nil
+	}
+	return f(s.r)
+}
+
+// resetDeadman restarts the dead-man timer. Must be called with s.mu held.
+func (s *server) resetDeadman() {
+	if s.deadman == 0 {
+		return
+	}
+	if s.moving != nil {
+		s.moving.Stop()
+	}
+	var t clock.Timer
+	t = s.clock.AfterFunc(s.deadman, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		// A timer replaced while it fired has nothing to stop.
+		if s.moving != t {
+			return
+		}
+		s.moving = nil
+		log.Printf("no drive command for %s, stopping", s.deadman)
+		if err := s.r.Stop(); err != nil {
+			log.Printf("failed to stop: %s", err)
+		}
+	})
+	s.moving = t
+}
+
+func (s *server) handleMode(w http.ResponseWriter, req *http.Request) {
+	var command func(roomba.Robot) error
+	switch mode := req.PathValue("mode"); mode {
+	case "passive":
+		command = roomba.Robot.Passive
+	case "safe":
+		command = roomba.Robot.Safe
+	case "full":
+		command = roomba.Robot.Full
+	default:
+		s.respond(w, badRequestf("unknown mode %q", mode))
+		return
+	}
+	// Mode changes stop the robot.
+	s.respond(w, s.stopDeadman(command))
+}
+
+// driveRequest is the body of POST /drive. Exactly one of the pairs of fields
+// must be set: velocity (mm/s) and radius (mm) as in Roomba.Drive, right and
+// left wheel velocities (mm/s) as in Roomba.DirectDrive, or linear (m/s) and
+// angular (rad/s) velocities as in Roomba.DriveTwist.
+type driveRequest struct {
+	Velocity *int16 `json:"velocity"`
+	Radius   *int16 `json:"radius"`
+
+	Right *int16 `json:"right"`
+	Left  *int16 `json:"left"`
+
+	Linear  *float64 `json:"linear"`
+	Angular *float64 `json:"angular"`
+}
+
+// command returns the drive command of the request.
+func (d driveRequest) command() (func(roomba.Robot) error, error) {
+	var commands []func(roomba.Robot) error
+	if d.Velocity != nil || d.Radius != nil {
+		if d.Velocity == nil || d.Radius == nil {
+			return nil, badRequestf("velocity and radius must be given together")
+		}
+		commands = append(commands, func(r roomba.Robot) error { return r.Drive(*d.Velocity, *d.Radius) })
+	}
+	if d.Right != nil || d.Left != nil {
+		if d.Right == nil || d.Left == nil {
+			return nil, badRequestf("right and left must be given together")
+		}
+		commands = append(commands, func(r roomba.Robot) error { return r.DirectDrive(*d.Right, *d.Left) })
+	}
+	if d.Linear != nil || d.Angular != nil {
+		var linear, angular float64
+		if d.Linear != nil {
+			linear = *d.Linear
+		}
+		if d.Angular != nil {
+			angular = *d.Angular
+		}
+		commands = append(commands, func(r roomba.Robot) error { return r.DriveTwist(linear, angular) })
+	}
+	if len(commands) != 1 {
+		return nil, badRequestf("one of velocity and radius, right and left, or linear and angular must be given")
+	}
+	return commands[0], nil
+}
+
+func (s *server) handleDrive(w http.ResponseWriter, req *http.Request) {
+	var d driveRequest
+	if err := decode(req.Body, &d); err != nil {
+		s.respond(w, err)
+		return
+	}
+	command, err := d.command()
+	if err != nil {
+		s.respond(w, err)
+		return
+	}
+	if s.hub.isControlled() {
+		s.respond(w, errorf(http.StatusConflict, "a WebSocket client controls the robot"))
+		return
+	}
+	s.respond(w, s.drive(command))
+}
+
+func (s *server) handleStop(w http.ResponseWriter, req *http.Request) {
+	s.respond(w, s.stopDeadman(roomba.Robot.Stop))
+}
+
+// ledsRequest is the body of POST /leds, with the arguments of Roomba.LEDs.
+type ledsRequest struct {
+	CheckRobot     bool `json:"check_robot"`
+	Dock           bool `json:"dock"`
+	Spot           bool `json:"spot"`
+	Debris         bool `json:"debris"`
+	PowerColor     byte `json:"power_color"`
+	PowerIntensity byte `json:"power_intensity"`
+}
+
+func (s *server) handleLEDs(w http.ResponseWriter, req *http.Request) {
+	var l ledsRequest
+	if err := decode(req.Body, &l); err != nil {
+		s.respond(w, err)
+		return
+	}
+	s.respond(w, s.command(func(r roomba.Robot) error {
+		return r.LEDs(l.CheckRobot, l.Dock, l.Spot, l.Debris, l.PowerColor, l.PowerIntensity)
+	}))
+}
+
+// songNumber parses the song number of the request path.
+func songNumber(req *http.Request) (byte, error) {
+	n, err := strconv.ParseUint(req.PathValue("song"), 10, 8)
+	if err != nil || n > 4 {
+		return 0, badRequestf("invalid song number %q", req.PathValue("song"))
+	}
+	return byte(n), nil
+}
+
+// songRequest is the body of PUT /songs/{song}.
+type songRequest struct {
+	Notes []struct {
+		Number   byte `json:"number"`
+		Duration byte `json:"duration"` // In 1/64ths of a second.
+	} `json:"notes"`
+}
+
+func (s *server) handleSong(w http.ResponseWriter, req *http.Request) {
+	n, err := songNumber(req)
+	if err != nil {
+		s.respond(w, err)
+		return
+	}
+	var song songRequest
+	if err := decode(req.Body, &song); err != nil {
+		s.respond(w, err)
+		return
+	}
+	if len(song.Notes) < 1 || len(song.Notes) > 16 {
+		s.respond(w, badRequestf("songs have 1 to 16 notes, got %d", len(song.Notes)))
+		return
+	}
+	notes := make([]roomba.Note, len(song.Notes))
+	for i, note := range song.Notes {
+		notes[i] = roomba.Note{Number: note.Number, Duration: note.Duration}
+	}
+	s.respond(w, s.command(func(r roomba.Robot) error { return r.Song(n, notes) }))
+}
+
+func (s *server) handlePlay(w http.ResponseWriter, req *http.Request) {
+	n, err := songNumber(req)
+	if err != nil {
+		s.respond(w, err)
+		return
+	}
+	s.respond(w, s.command(func(r roomba.Robot) error { return r.Play(n) }))
+}
+
+// parsePackets parses a comma separated list of packet names, as returned by
+// oi.Packet.Name, or IDs, of at most roomba.MaxPackets packets.
+func parsePackets(list string) ([]byte, error) {
+	names := strings.Split(list, ",")
+	if len(names) > roomba.MaxPackets {
+		return nil, badRequestf("%d sensor packets, at most %d", len(names), roomba.MaxPackets)
+	}
+	var ids []byte
+	for _, name := range names {
+		id, ok := oi.PacketId(name)
+		if n, err := strconv.ParseUint(name, 10, 8); err == nil {
+			id, ok = byte(n), true
+		}
+		// Groups aren't supported, their members can be listed instead.
+		if !ok || !(oi.Packet{ID: id}).Known() {
+			return nil, badRequestf("unknown sensor packet %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// packet is a decoded sensor packet returned by GET /sensors.
+type packet struct {
+	ID    byte   `json:"id"`
+	Name  string `json:"name"`
+	Value int    `json:"value"`
+	Unit  string `json:"unit,omitempty"`
+	Note  string `json:"note,omitempty"`
+	Data  string `json:"data"` // The raw bytes in hex.
+}
+
+// decodePackets decodes the data of the given packets.
+func decodePackets(ids []byte, data [][]byte) []packet {
+	packets := make([]packet, len(ids))
+	for i, id := range ids {
+		p := oi.Packet{ID: id, Data: data[i]}
+		packets[i] = packet{ID: id, Name: p.Name(), Value: p.Value(), Unit: p.Unit(), Note: p.Note(),
+			Data: hex.EncodeToString(p.Data)}
+	}
+	return packets
+}
+
+func (s *server) handleSensors(w http.ResponseWriter, req *http.Request) {
+	ids := defaultPackets
+	if list := req.URL.Query().Get("packets"); list != "" {
+		var err error
+		if ids, err = parsePackets(list); err != nil {
+			s.respond(w, err)
+			return
+		}
+	}
+	s.mu.Lock()
+	streaming := s.streaming
+	s.mu.Unlock()
+	var data [][]byte
+	var err error
+	if !streaming {
+		data, err = s.r.QueryList(ids)
+	}
+	if streaming || errors.Is(err, roomba.ErrStreaming) {
+		// The robot can't answer queries while it streams.
+		data, err = s.hub.latest(ids)
+	}
+	if err != nil {
+		s.respond(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string][]packet{"packets": decodePackets(ids, data)})
+}
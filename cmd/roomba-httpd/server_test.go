@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xa4a/go-roomba"
+	"github.com/xa4a/go-roomba/clock"
+	"github.com/xa4a/go-roomba/constants"
+	rt "github.com/xa4a/go-roomba/testing"
+)
+
+// startServer serves the API of a harness' robot, with the dead-man timer on
+// a virtual clock.
+func startServer(t *testing.T) (*rt.Harness, *clock.Virtual, *httptest.Server) {
+	return startServerDeadman(t, defaultDeadman)
+}
+
+// startServerDeadman is startServer with the given dead-man timeout.
+func startServerDeadman(t *testing.T, deadman time.Duration) (*rt.Harness, *clock.Virtual, *httptest.Server) {
+	h := rt.NewHarness(t)
+	clk := clock.MakeVirtual(time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC))
+	srv := httptest.NewServer(makeServer(h.Roomba, deadman, clk).handler())
+	t.Cleanup(srv.Close)
+	return h, clk, srv
+}
+
+// call sends a request with a JSON body, if not empty, and returns the status
+// code and the decoded response, if any.
+func call(t *testing.T, srv *httptest.Server, method, path, body string) (int, map[string]interface{}) {
+	t.Helper()
+	req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %s", method, path, err)
+	}
+	defer resp.Body.Close()
+	var v map[string]interface{}
+	if resp.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+			t.Fatalf("%s %s: failed decoding response: %s", method, path, err)
+		}
+	}
+	return resp.StatusCode, v
+}
+
+func TestCommands(t *testing.T) {
+	h, _, srv := startServer(t)
+	for _, test := range []struct {
+		method, path, body string
+		expected           rt.Command
+	}{
+		{"POST", "/mode/full", "", rt.Full()},
+		{"POST", "/mode/passive", "", rt.Start()},
+		{"POST", "/mode/safe", "", rt.Safe()},
+		{"POST", "/drive", `{"velocity": -200, "radius": 500}`, rt.Drive(-200, 500)},
+		{"POST", "/drive", `{"right": 100, "left": 150}`, rt.DirectDrive(100, 150)},
+		{"POST", "/drive", `{"linear": 0.2}`, rt.Drive(200, constants.DRIVE_STRAIGHT)},
+		{"POST", "/stop", "", rt.Drive(0, 0)},
+		{"POST", "/leds", `{"check_robot": true, "debris": true, "power_color": 255, "power_intensity": 128}`,
+			rt.Cmd("LEDs", 9, 255, 128)},
+		{"PUT", "/songs/1", `{"notes": [{"number": 60, "duration": 16}, {"number": 67, "duration": 32}]}`,
+			rt.Cmd("Song", 1, 2, 60, 16, 67, 32)},
+		{"POST", "/songs/1/play", "", rt.Cmd("Play", 1)},
+		{"POST", "/clean", "", rt.Cmd("Clean")},
+		{"POST", "/spot", "", rt.Cmd("Spot")},
+		{"POST", "/max", "", rt.Cmd("Max")},
+		{"POST", "/dock", "", rt.Cmd("Seek_dock")},
+		{"POST", "/power", "", rt.Cmd("Power")},
+	} {
+		if code, resp := call(t, srv, test.method, test.path, test.body); code != http.StatusNoContent {
+			t.Errorf("%s %s: got %d %v", test.method, test.path, code, resp)
+		}
+		h.Expect(test.expected)
+	}
+}
+
+func TestBadRequests(t *testing.T) {
+	h, _, srv := startServer(t)
+	for _, test := range []struct {
+		method, path, body string
+	}{
+		{"POST", "/mode/off", ""},
+		{"POST", "/drive", `{"velocity": 200}`},
+		{"POST", "/drive", `{"velocity": 200, "radius": 0, "right": 100, "left": 100}`},
+		{"POST", "/drive", `{"velocity": 600, "radius": 0}`},
+		{"POST", "/drive", `{"speed": 200}`},
+		{"POST", "/drive", `{`},
+		{"PUT", "/songs/5", `{"notes": [{"number": 60, "duration": 16}]}`},
+		{"PUT", "/songs/0", `{"notes": []}`},
+		{"POST", "/songs/x/play", ""},
+		{"GET", "/sensors?packets=oi_mode,group_6", ""},
+		{"GET", "/sensors?packets=bogus", ""},
+		{"GET", "/sensors?packets=" + strings.Repeat("oi_mode,", roomba.MaxPackets) + "oi_mode", ""},
+	} {
+		code, resp := call(t, srv, test.method, test.path, test.body)
+		if code != http.StatusBadRequest || resp["error"] == "" {
+			t.Errorf("%s %s %s: got %d %v, want an error", test.method, test.path, test.body, code, resp)
+		}
+	}
+	if received := h.Sim.Received(); len(received) != 0 {
+		t.Errorf("bad requests sent % x to the robot", received)
+	}
+}
+
+func TestSensors(t *testing.T) {
+	h, _, srv := startServer(t)
+	call(t, srv, "POST", "/drive", `{"velocity": -200, "radius": 500}`)
+	code, resp := call(t, srv, "GET", "/sensors?packets=oi_mode,requested_velocity,22", "")
+	if code != http.StatusOK {
+		t.Fatalf("got %d %v", code, resp)
+	}
+	h.Expect(rt.Drive(-200, 500), rt.QueryList(constants.SENSOR_OI_MODE,
+		constants.SENSOR_REQUESTED_VELOCITY, constants.SENSOR_VOLTAGE))
+	var packets []packet
+	data, _ := json.Marshal(resp["packets"])
+	json.Unmarshal(data, &packets)
+	want := []packet{
+		{ID: 35, Name: "oi_mode", Value: 2, Note: "safe", Data: "02"},
+		{ID: 39, Name: "requested_velocity", Value: -200, Unit: "mm/s", Data: "ff38"},
+	}
+	if len(packets) != 3 || !reflect.DeepEqual(packets[:2], want) || packets[2].Name != "voltage" {
+		t.Errorf("got packets %+v", packets)
+	}
+
+	if code, resp := call(t, srv, "GET", "/sensors", ""); code != http.StatusOK ||
+		len(resp["packets"].([]interface{})) != len(defaultPackets) {
+		t.Errorf("got %d %v, want the default packets", code, resp)
+	}
+}
+
+func TestDeadman(t *testing.T) {
+	h, clk, srv := startServer(t)
+	drive := `{"velocity": 200, "radius": 500}`
+
+	// Repeated drive commands keep the robot moving.
+	call(t, srv, "POST", "/drive", drive)
+	clk.Advance(defaultDeadman - time.Millisecond)
+	call(t, srv, "POST", "/drive", drive)
+	clk.Advance(defaultDeadman - time.Millisecond)
+	h.Expect(rt.Drive(200, 500), rt.Drive(200, 500))
+	clk.Advance(time.Millisecond)
+	h.Expect(rt.Drive(0, 0))
+
+	// Stopping cancels the timer.
+	call(t, srv, "POST", "/drive", drive)
+	call(t, srv, "POST", "/stop", "")
+	h.Expect(rt.Drive(200, 500), rt.Drive(0, 0))
+	received := len(h.Sim.Received())
+	clk.Advance(2 * defaultDeadman)
+	if n := len(h.Sim.Received()); n != received {
+		t.Errorf("robot stopped again after /stop: % x", h.Sim.Received()[received:])
+	}
+}
+
+// stalledRobot doesn't answer queries until released.
+type stalledRobot struct {
+	roomba.Robot
+	started chan struct{}
+	release chan struct{}
+}
+
+func (r stalledRobot) QueryList(packet_ids []byte) ([][]byte, error) {
+	r.started <- struct{}{}
+	<-r.release
+	return r.Robot.QueryList(packet_ids)
+}
+
+func TestDeadmanDuringQuery(t *testing.T) {
+	h := rt.NewHarness(t)
+	stalled := stalledRobot{h.Roomba, make(chan struct{}), make(chan struct{})}
+	clk := clock.MakeVirtual(time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC))
+	srv := httptest.NewServer(makeServer(stalled, defaultDeadman, clk).handler())
+	t.Cleanup(srv.Close)
+
+	call(t, srv, "POST", "/drive", `{"velocity": 200, "radius": 500}`)
+	codes := make(chan int)
+	go func() {
+		code, _ := call(t, srv, "GET", "/sensors?packets=oi_mode", "")
+		codes <- code
+	}()
+	<-stalled.started
+
+	// The robot stops while the query is pending, which then times out.
+	clk.Advance(defaultDeadman)
+	h.Expect(rt.Drive(200, 500), rt.Drive(0, 0))
+	if code := <-codes; code != http.StatusGatewayTimeout {
+		t.Errorf("got %d for a query the robot didn't answer", code)
+	}
+	close(stalled.release)
+}
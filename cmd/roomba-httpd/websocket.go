@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/xa4a/go-roomba"
+	"github.com/xa4a/go-roomba/constants"
+	"github.com/xa4a/go-roomba/navigation"
+	"github.com/xa4a/go-roomba/oi"
+)
+
+// streamPackets are streamed from the robot once the first WebSocket client
+// connects. Distance and angle are integrated into the odometry sent with
+// every frame, as clients receiving fewer frames would miss some of them.
+var streamPackets = []byte{
+	constants.SENSOR_BUMP_WHEELS_DROPS,
+	constants.SENSOR_WALL,
+	constants.SENSOR_CLIFF_LEFT,
+	constants.SENSOR_CLIFF_FRONT_LEFT,
+	constants.SENSOR_CLIFF_FRONT_RIGHT,
+	constants.SENSOR_CLIFF_RIGHT,
+	constants.SENSOR_VIRTUAL_WALL,
+	constants.SENSOR_WHEEL_OVERCURRENT,
+	constants.SENSOR_DISTANCE,
+	constants.SENSOR_ANGLE,
+	constants.SENSOR_CHARGING,
+	constants.SENSOR_VOLTAGE,
+	constants.SENSOR_CURRENT,
+	constants.SENSOR_TEMPERATURE,
+	constants.SENSOR_BATTERY_CHARGE,
+	constants.SENSOR_BATTERY_CAPACITY,
+	constants.SENSOR_OI_MODE,
+	constants.SENSOR_SONG_PLAYING,
+	constants.SENSOR_REQUESTED_VELOCITY,
+	constants.SENSOR_REQUESTED_RADIUS,
+}
+
+// defaultFrameRate is the number of frames per second sent to WebSocket
+// clients which don't ask for a rate.
+const defaultFrameRate = 10
+
+// streamPeriod is the interval between the frames streamed by the robot, so
+// no client gets frames more often.
+const streamPeriod = 15 * time.Millisecond
+
+// hub keeps the latest frame of the stream for the WebSocket clients.
+type hub struct {
+	mu         sync.Mutex
+	frame      [][]byte // Latest frame, nil until the first one.
+	seq        int      // Number of frames received.
+	odometry   navigation.Odometry
+	controlled bool // Whether a client controls the robot.
+}
+
+// message is sent to WebSocket clients for stream frames.
+type message struct {
+	Packets  []packet     `json:"packets"`
+	Odometry odometryPose `json:"odometry"`
+}
+
+// odometryPose is the pose of the robot integrated from the distance and
+// angle it reports since the stream was started.
+type odometryPose struct {
+	X     float64 `json:"x"`     // m
+	Y     float64 `json:"y"`     // m
+	Theta float64 `json:"theta"` // rad, counter-clockwise.
+}
+
+func (h *hub) update(frame [][]byte) {
+	value := func(id byte) int16 {
+		return int16(oi.Packet{ID: id, Data: frame[bytes.IndexByte(streamPackets, id)]}.Value())
+	}
+	h.odometry.Update(value(constants.SENSOR_DISTANCE), value(constants.SENSOR_ANGLE))
+	h.mu.Lock()
+	h.frame = frame
+	h.seq++
+	h.mu.Unlock()
+}
+
+// latest returns the data of the given packets in the latest frame.
+func (h *hub) latest(ids []byte) ([][]byte, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.frame == nil {
+		return nil, errorf(http.StatusServiceUnavailable, "waiting for the first stream frame")
+	}
+	data := make([][]byte, len(ids))
+	for i, id := range ids {
+		j := bytes.IndexByte(streamPackets, id)
+		if j < 0 {
+			return nil, errorf(http.StatusConflict, "%s can't be read while sensors are streamed",
+				oi.Packet{ID: id}.Name())
+		}
+		data[i] = h.frame[j]
+	}
+	return data, nil
+}
+
+// message returns the message of the latest frame, and its number, if it
+// came after frame number after.
+func (h *hub) message(after int) (message, int, bool) {
+	h.mu.Lock()
+	frame, seq := h.frame, h.seq
+	h.mu.Unlock()
+	if frame == nil || seq <= after {
+		return message{}, after, false
+	}
+	pose, _ := h.odometry.Pose()
+	return message{
+		Packets:  decodePackets(streamPackets, frame),
+		Odometry: odometryPose{pose.X, pose.Y, pose.Theta},
+	}, seq, true
+}
+
+// takeControl reports whether the caller became the controller, as there was
+// none.
+func (h *hub) takeControl() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.controlled {
+		return false
+	}
+	h.controlled = true
+	return true
+}
+
+// isControlled reports whether a client controls the robot.
+func (h *hub) isControlled() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.controlled
+}
+
+// startStream starts the stream of sensor data, unless it is running.
+func (s *server) startStream() error {
+	s.mu.Lock()
+	if s.streaming {
+		s.mu.Unlock()
+		return nil
+	}
+	s.streaming = true
+	s.mu.Unlock()
+	// Starting the stream waits for pending queries.
+	frames, err := s.r.Stream(streamPackets)
+	if err != nil {
+		s.mu.Lock()
+		s.streaming = false
+		s.mu.Unlock()
+		return err
+	}
+	go s.readFrames(frames)
+	return nil
+}
+
+// readFrames keeps the latest frame of the stream until it ends. The next
+// client restarts it.
+func (s *server) readFrames(frames <-chan [][]byte) {
+	for frame := range frames {
+		s.hub.update(frame)
+	}
+	log.Printf("sensor stream ended")
+	s.mu.Lock()
+	s.streaming = false
+	s.mu.Unlock()
+	s.hub.mu.Lock()
+	s.hub.frame = nil
+	s.hub.mu.Unlock()
+}
+
+// releaseControl stops the robot if the controller drove it, even without a
+// dead-man timer, and lets another client take control.
+func (s *server) releaseControl() {
+	s.mu.Lock()
+	if s.moving != nil {
+		s.moving.Stop()
+		s.moving = nil
+	}
+	if s.controllerDrove {
+		s.controllerDrove = false
+		if err := s.r.Stop(); err != nil {
+			log.Printf("failed to stop: %s", err)
+		}
+	}
+	s.mu.Unlock()
+	s.hub.mu.Lock()
+	s.hub.controlled = false
+	s.hub.mu.Unlock()
+}
+
+// Origins other than the API's own are rejected, so that other web pages
+// can't drive the robot through the browser.
+var upgrader = websocket.Upgrader{}
+
+// wsConn serializes the writes to a WebSocket connection.
+type wsConn struct {
+	*websocket.Conn
+	mu sync.Mutex
+}
+
+func (c *wsConn) send(v interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.WriteJSON(v)
+}
+
+func (c *wsConn) sendError(err error) error {
+	return c.send(map[string]string{"error": err.Error()})
+}
+
+// framePeriod returns the interval between the frames sent to a client asking
+// for rate frames per second, or for the default rate if rate is empty.
+func framePeriod(rate string, defaultRate float64) (time.Duration, error) {
+	r := defaultRate
+	if rate != "" {
+		var err error
+		if r, err = strconv.ParseFloat(rate, 64); err != nil || r <= 0 {
+			return 0, badRequestf("invalid rate %q", rate)
+		}
+	}
+	period := time.Duration(float64(time.Second) / r)
+	if period < streamPeriod {
+		period = streamPeriod
+	}
+	return period, nil
+}
+
+// handleWebSocket sends stream frames to the client and, if it asked for
+// control and no other client has it, executes its drive commands.
+func (s *server) handleWebSocket(w http.ResponseWriter, req *http.Request) {
+	period, err := framePeriod(req.URL.Query().Get("rate"), s.frameRate)
+	if err != nil {
+		s.respond(w, err)
+		return
+	}
+	control, _ := strconv.ParseBool(req.URL.Query().Get("control"))
+	if control {
+		if !s.hub.takeControl() {
+			s.respond(w, errorf(http.StatusConflict, "another client controls the robot"))
+			return
+		}
+		defer s.releaseControl()
+	}
+	ws, err := upgrader.Upgrade(w, req, nil)
+	if err != nil {
+		// Upgrade replied with the error.
+		return
+	}
+	c := &wsConn{Conn: ws}
+	defer c.Close()
+	if err := s.startStream(); err != nil {
+		c.sendError(err)
+		return
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go s.sendFrames(c, period, done)
+	s.receiveCommands(c, control)
+}
+
+// sendFrames sends the latest frame every period, unless it was sent already,
+// until done is closed.
+func (s *server) sendFrames(c *wsConn, period time.Duration, done <-chan struct{}) {
+	ticker := s.clock.NewTicker(period)
+	defer ticker.Stop()
+	sent := 0
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C():
+		}
+		m, seq, ok := s.hub.message(sent)
+		if !ok {
+			continue
+		}
+		sent = seq
+		if err := c.send(m); err != nil {
+			return
+		}
+	}
+}
+
+// receiveCommands executes the drive commands of the controller, with the
+// body of POST /drive, until the connection is closed. Like those, they stop
+// the robot after the dead-man timeout unless repeated.
+func (s *server) receiveCommands(c *wsConn, control bool) {
+	for {
+		_, r, err := c.NextReader()
+		if err != nil {
+			return
+		}
+		if !control {
+			c.sendError(badRequestf("viewers can't drive the robot"))
+			continue
+		}
+		var d driveRequest
+		if err := decode(r, &d); err != nil {
+			c.sendError(err)
+			continue
+		}
+		command, err := d.command()
+		if err == nil {
+			// Run with s.mu held.
+			err = s.drive(func(r roomba.Robot) error {
+				if err := command(r); err != nil {
+					return err
+				}
+				s.controllerDrove = true
+				return nil
+			})
+		}
+		if err != nil {
+			c.sendError(err)
+		}
+	}
+}
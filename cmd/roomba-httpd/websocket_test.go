@@ -0,0 +1,182 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/xa4a/go-roomba/clock"
+	rt "github.com/xa4a/go-roomba/testing"
+)
+
+// wsMessage is a message received from the WebSocket, either a frame or an
+// error.
+type wsMessage struct {
+	message
+	Error string `json:"error"`
+}
+
+// wsClient is a WebSocket connection to the server, with its messages read
+// into a channel.
+type wsClient struct {
+	*websocket.Conn
+	messages chan wsMessage
+}
+
+func dial(t *testing.T, srv string, query string) (*wsClient, *http.Response, error) {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(srv, "http") + "/ws?" + query
+	conn, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		return nil, resp, err
+	}
+	c := &wsClient{conn, make(chan wsMessage)}
+	closed := make(chan struct{})
+	t.Cleanup(func() {
+		close(closed)
+		c.Close()
+	})
+	go func() {
+		defer close(c.messages)
+		for {
+			var m wsMessage
+			if err := c.ReadJSON(&m); err != nil {
+				return
+			}
+			select {
+			case c.messages <- m:
+			case <-closed:
+				return
+			}
+		}
+	}()
+	return c, resp, nil
+}
+
+// receive waits for a message satisfying ok. Frames are sent when the clock
+// ticks, once the stream delivers them.
+func (c *wsClient) receive(t *testing.T, clk *clock.Virtual, ok func(wsMessage) bool) wsMessage {
+	t.Helper()
+	deadline := time.After(5 * time.Second)
+	for {
+		clk.Advance(streamPeriod)
+		select {
+		case m, open := <-c.messages:
+			if !open {
+				t.Fatalf("connection closed")
+			}
+			if ok(m) {
+				return m
+			}
+		case <-time.After(streamPeriod):
+		case <-deadline:
+			t.Fatalf("timed out waiting for a message")
+		}
+	}
+}
+
+func packetValue(m wsMessage, name string) (int, bool) {
+	for _, p := range m.Packets {
+		if p.Name == name {
+			return p.Value, true
+		}
+	}
+	return 0, false
+}
+
+func TestWebSocket(t *testing.T) {
+	h, clk, srv := startServer(t)
+	viewer, _, err := dial(t, srv.URL, "rate=50")
+	if err != nil {
+		t.Fatalf("failed to connect: %s", err)
+	}
+	controller, _, err := dial(t, srv.URL, "control=true")
+	if err != nil {
+		t.Fatalf("failed to connect the controller: %s", err)
+	}
+	if _, resp, err := dial(t, srv.URL, "control=true"); resp == nil || resp.StatusCode != http.StatusConflict {
+		t.Errorf("second controller connected: %v", err)
+	}
+
+	controller.WriteJSON(map[string]int{"velocity": 200, "radius": 500})
+	h.Expect(rt.Stream(streamPackets...), rt.Drive(200, 500))
+	m := viewer.receive(t, clk, func(m wsMessage) bool {
+		v, _ := packetValue(m, "requested_velocity")
+		return v == 200 && m.Odometry.X > 0
+	})
+	if mode, _ := packetValue(m, "oi_mode"); mode != 2 {
+		t.Errorf("got mode %d, want safe", mode)
+	}
+
+	// Sensors are read from the stream.
+	code, resp := call(t, srv, "GET", "/sensors?packets=requested_velocity", "")
+	if code != http.StatusOK {
+		t.Errorf("got %d %v", code, resp)
+	}
+	if code, _ := call(t, srv, "GET", "/sensors?packets=song_number", ""); code != http.StatusConflict {
+		t.Errorf("got %d reading a packet which isn't streamed", code)
+	}
+
+	viewer.WriteJSON(map[string]int{"velocity": 100, "radius": 500})
+	viewer.receive(t, clk, func(m wsMessage) bool { return m.Error != "" })
+	controller.WriteJSON(map[string]int{"velocity": 600, "radius": 500})
+	controller.receive(t, clk, func(m wsMessage) bool { return m.Error != "" })
+
+	// Without commands, the watchdog stops the robot.
+	clk.Advance(defaultDeadman)
+	h.Expect(rt.Drive(0, 0))
+
+	// So does disconnecting.
+	controller.WriteJSON(map[string]float64{"linear": 0.1})
+	h.Expect(rt.Drive(100, 32767))
+	controller.Close()
+	h.Expect(rt.Drive(0, 0))
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, _, err := dial(t, srv.URL, "control=1"); err == nil {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatalf("failed to take over control: %s", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestControlWithoutDeadman(t *testing.T) {
+	h, _, srv := startServerDeadman(t, 0)
+	controller, _, err := dial(t, srv.URL, "control=true")
+	if err != nil {
+		t.Fatalf("failed to connect the controller: %s", err)
+	}
+	controller.WriteJSON(map[string]int{"velocity": 200, "radius": 500})
+	h.Expect(rt.Stream(streamPackets...), rt.Drive(200, 500))
+
+	// The controller has the wheels to itself.
+	if code, resp := call(t, srv, "POST", "/drive", `{"velocity": -200, "radius": 500}`); code != http.StatusConflict {
+		t.Errorf("got %d %v driving while a client controls the robot", code, resp)
+	}
+
+	// Disconnecting stops the robot, though no dead-man timer runs.
+	controller.Close()
+	h.Expect(rt.Drive(0, 0))
+}
+
+func TestFramePeriod(t *testing.T) {
+	for rate, want := range map[string]time.Duration{
+		"":     100 * time.Millisecond,
+		"2":    500 * time.Millisecond,
+		"1000": streamPeriod,
+	} {
+		if got, err := framePeriod(rate, defaultFrameRate); err != nil || got != want {
+			t.Errorf("framePeriod(%q) = %s, %v, want %s", rate, got, err, want)
+		}
+	}
+	for _, rate := range []string{"0", "-1", "fast"} {
+		if _, err := framePeriod(rate, defaultFrameRate); err == nil {
+			t.Errorf("framePeriod(%q) accepted", rate)
+		}
+	}
+}
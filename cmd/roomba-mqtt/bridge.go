@@ -0,0 +1,278 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/xa4a/go-roomba"
+	"github.com/xa4a/go-roomba/clock"
+	"github.com/xa4a/go-roomba/constants"
+	"github.com/xa4a/go-roomba/oi"
+)
+
+// broker is the part of an MQTT client used by the bridge, so that tests can
+// use an in-process stand-in.
+type broker interface {
+	Publish(topic string, retained bool, payload []byte) error
+	Subscribe(topic string, handle func(payload []byte)) error
+}
+
+// Home Assistant vacuum states.
+const (
+	stateCleaning  = "cleaning"
+	stateDocked    = "docked"
+	stateIdle      = "idle"
+	stateReturning = "returning"
+	stateError     = "error"
+)
+
+// locateSong is stored in the last song slot and played by the locate
+// command.
+var locateSong = []roomba.Note{
+	{Number: 84, Duration: 16}, {Number: 0, Duration: 8},
+	{Number: 84, Duration: 16}, {Number: 0, Duration: 8},
+	{Number: 84, Duration: 16}, {Number: 0, Duration: 8},
+	{Number: 88, Duration: 32},
+}
+
+const locateSongNumber = 4
+
+// statePackets are queried for the published state.
+var statePackets = []byte{
+	constants.SENSOR_CHARGING,
+	constants.SENSOR_CHARGING_SOURCE,
+	constants.SENSOR_BATTERY_CHARGE,
+	constants.SENSOR_BATTERY_CAPACITY,
+	constants.SENSOR_OI_MODE,
+	constants.SENSOR_DIRT_DETECT,
+}
+
+// config configures a bridge.
+type config struct {
+	// ID of the robot, used in topics and as Home Assistant's unique ID.
+	ID string
+	// Name of the robot shown by Home Assistant.
+	Name string
+	// Topics of the robot start with Prefix/ID.
+	Prefix string
+	// Prefix of Home Assistant's discovery topics, empty to disable
+	// discovery.
+	DiscoveryPrefix string
+}
+
+// state is the state published to the state topic, in the format of Home
+// Assistant's MQTT vacuum.
+type state struct {
+	State        string `json:"state"`
+	BatteryLevel int    `json:"battery_level"` // Percent.
+	Charging     string `json:"charging"`
+	Mode         string `json:"mode"`
+	DirtDetect   int    `json:"dirt_detect"`
+}
+
+// bridge publishes the state of a robot to MQTT topics and executes the
+// commands received on its command topic. Should be constructed with
+// makeBridge() function.
+type bridge struct {
+	b      broker
+	config config
+
+	r roomba.Robot
+
+	// mu keeps the commands of a payload together, and guards the fields
+	// below.
+	mu       sync.Mutex
+	activity string            // Vacuum state set by the last command.
+	last     map[string]string // Last payload published to each topic.
+}
+
+// makeBridge creates a bridge between the robot r and an MQTT broker.
+func makeBridge(r roomba.Robot, b broker, c config) *bridge {
+	return &bridge{b: b, config: c, r: roomba.MakeSerialized(r), activity: stateIdle, last: map[string]string{}}
+}
+
+func (br *bridge) topic(name string) string {
+	return br.config.Prefix + "/" + br.config.ID + "/" + name
+}
+
+// start subscribes to the command topic and announces the robot. It must be
+// called on every connection to the broker.
+func (br *bridge) start() error {
+	if err := br.b.Subscribe(br.topic("command"), br.handleCommand); err != nil {
+		return err
+	}
+	if br.config.DiscoveryPrefix != "" {
+		if err := br.publishDiscovery(); err != nil {
+			return err
+		}
+	}
+	if err := br.b.Publish(br.topic("availability"), true, []byte("online")); err != nil {
+		return err
+	}
+	// The broker may have lost the retained state.
+	br.mu.Lock()
+	br.last = map[string]string{}
+	br.mu.Unlock()
+	return br.publishState()
+}
+
+// publishDiscovery publishes the config of a Home Assistant MQTT vacuum.
+func (br *bridge) publishDiscovery() error {
+	discovery := map[string]interface{}{
+		"name":               br.config.Name,
+		"unique_id":          br.config.ID,
+		"schema":             "state",
+		"command_topic":      br.topic("command"),
+		"state_topic":        br.topic("state"),
+		"availability_topic": br.topic("availability"),
+		"supported_features": []string{"start", "stop", "return_home", "clean_spot", "locate", "status", "battery"},
+		"device": map[string]interface{}{
+			"identifiers":  []string{br.config.ID},
+			"name":         br.config.Name,
+			"manufacturer": "iRobot",
+		},
+	}
+	payload, err := json.Marshal(discovery)
+	if err != nil {
+		return err
+	}
+	return br.b.Publish(br.config.DiscoveryPrefix+"/vacuum/"+br.config.ID+"/config", true, payload)
+}
+
+// vacuumState returns the Home Assistant vacuum state of the robot, given
+// the state set by the last command.
+func vacuumState(activity string, charging, source byte) string {
+	switch {
+	case charging == constants.CHARGING_FAULT:
+		return stateError
+	case source&constants.CHARGING_SOURCE_HOME_BASE != 0:
+		return stateDocked
+	}
+	return activity
+}
+
+// publishState queries the robot and publishes the topics whose payload
+// changed, retained so that new subscribers get them at once.
+func (br *bridge) publishState() error {
+	values, err := br.r.QueryList(statePackets)
+	if err != nil {
+		return fmt.Errorf("failed querying state: %s", err)
+	}
+	packets := make(map[byte]oi.Packet)
+	for i, id := range statePackets {
+		packets[id] = oi.Packet{ID: id, Data: values[i]}
+	}
+	charging := byte(packets[constants.SENSOR_CHARGING].Value())
+	br.mu.Lock()
+	s := state{
+		State:      vacuumState(br.activity, charging, byte(packets[constants.SENSOR_CHARGING_SOURCE].Value())),
+		Charging:   packets[constants.SENSOR_CHARGING].Note(),
+		Mode:       packets[constants.SENSOR_OI_MODE].Note(),
+		DirtDetect: packets[constants.SENSOR_DIRT_DETECT].Value(),
+	}
+	if capacity := packets[constants.SENSOR_BATTERY_CAPACITY].Value(); capacity > 0 {
+		s.BatteryLevel = packets[constants.SENSOR_BATTERY_CHARGE].Value() * 100 / capacity
+	}
+	if s.State == stateDocked {
+		// Done returning.
+		br.activity = stateIdle
+	}
+	br.mu.Unlock()
+
+	payload, _ := json.Marshal(s)
+	for _, p := range []struct{ name, payload string }{
+		{"state", string(payload)},
+		{"battery_level", strconv.Itoa(s.BatteryLevel)},
+		{"charging", s.Charging},
+		{"mode", s.Mode},
+		{"dirt_detect", strconv.Itoa(s.DirtDetect)},
+	} {
+		if err := br.publishChanged(br.topic(p.name), p.payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (br *bridge) publishChanged(topic, payload string) error {
+	br.mu.Lock()
+	changed := br.last[topic] != payload
+	br.last[topic] = payload
+	br.mu.Unlock()
+	if !changed {
+		return nil
+	}
+	return br.b.Publish(topic, true, []byte(payload))
+}
+
+// execute sends the command with the given payload of the command topic to
+// the robot. Both Home Assistant's payloads and shorter aliases are
+// accepted.
+func (br *bridge) execute(command string) error {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	var err error
+	switch command {
+	case "start", "clean":
+		err = br.r.Clean()
+		br.activity = stateCleaning
+	case "clean_spot", "spot":
+		err = br.r.Spot()
+		br.activity = stateCleaning
+	case "return_to_base", "dock":
+		err = br.r.SeekDock()
+		br.activity = stateReturning
+	case "stop", "pause":
+		// Taking control stops cleaning, then the OI is handed back.
+		err = firstError(br.r.Safe(), br.r.Stop(), br.r.Passive())
+		br.activity = stateIdle
+	case "locate":
+		// Songs are only played in Safe and Full mode.
+		err = firstError(br.r.Safe(), br.r.Song(locateSongNumber, locateSong), br.r.Play(locateSongNumber))
+		br.activity = stateIdle
+	default:
+		return fmt.Errorf("unknown command %q", command)
+	}
+	return err
+}
+
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleCommand executes a command received on the command topic and
+// publishes the new state.
+func (br *bridge) handleCommand(payload []byte) {
+	if err := br.execute(string(payload)); err != nil {
+		log.Printf("command %q failed: %s", payload, err)
+		return
+	}
+	if err := br.publishState(); err != nil {
+		log.Printf("failed publishing state: %s", err)
+	}
+}
+
+// run publishes the state every interval until quit is closed.
+func (br *bridge) run(clk clock.Clock, interval time.Duration, quit <-chan struct{}) {
+	ticker := clk.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-quit:
+			return
+		case <-ticker.C():
+			if err := br.publishState(); err != nil {
+				log.Printf("failed publishing state: %s", err)
+			}
+		}
+	}
+}
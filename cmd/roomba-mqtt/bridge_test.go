@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/xa4a/go-roomba/constants"
+	rt "github.com/xa4a/go-roomba/testing"
+)
+
+// memBroker is an in-process stand-in for an MQTT broker, delivering the
+// messages sent to subscribed topics at once.
+type memBroker struct {
+	mu       sync.Mutex
+	retained map[string]string
+	count    map[string]int // Messages published to each topic.
+	handlers map[string]func(payload []byte)
+}
+
+func makeMemBroker() *memBroker {
+	return &memBroker{
+		retained: map[string]string{},
+		count:    map[string]int{},
+		handlers: map[string]func([]byte){},
+	}
+}
+
+func (b *memBroker) Publish(topic string, retained bool, payload []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if retained {
+		b.retained[topic] = string(payload)
+	}
+	b.count[topic]++
+	return nil
+}
+
+func (b *memBroker) Subscribe(topic string, handle func(payload []byte)) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[topic] = handle
+	return nil
+}
+
+// send publishes a message from another client.
+func (b *memBroker) send(topic, payload string) {
+	b.mu.Lock()
+	handle := b.handlers[topic]
+	b.mu.Unlock()
+	if handle != nil {
+		handle([]byte(payload))
+	}
+}
+
+func (b *memBroker) get(topic string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.retained[topic]
+}
+
+func (b *memBroker) state(t *testing.T) state {
+	t.Helper()
+	var s state
+	if err := json.Unmarshal([]byte(b.get("roomba/r1/state")), &s); err != nil {
+		t.Fatalf("invalid state: %s", err)
+	}
+	return s
+}
+
+func TestBridge(t *testing.T) {
+	h := rt.NewHarness(t)
+	b := makeMemBroker()
+	br := makeBridge(h.Roomba, b, config{ID: "r1", Name: "Roomba", Prefix: "roomba", DiscoveryPrefix: "homeassistant"})
+	if err := br.start(); err != nil {
+		t.Fatalf("failed to start: %s", err)
+	}
+	query := rt.QueryList(statePackets...)
+	h.Expect(query)
+
+	var discovery map[string]interface{}
+	if err := json.Unmarshal([]byte(b.get("homeassistant/vacuum/r1/config")), &discovery); err != nil {
+		t.Fatalf("invalid discovery config: %s", err)
+	}
+	if discovery["command_topic"] != "roomba/r1/command" || discovery["state_topic"] != "roomba/r1/state" ||
+		discovery["schema"] != "state" || discovery["unique_id"] != "r1" {
+		t.Errorf("got discovery config %v", discovery)
+	}
+	if a := b.get("roomba/r1/availability"); a != "online" {
+		t.Errorf("got availability %q", a)
+	}
+	want := state{State: stateIdle, BatteryLevel: 80, Charging: "not_charging", Mode: "safe"}
+	if s := b.state(t); s != want {
+		t.Errorf("got state %+v, want %+v", s, want)
+	}
+	for topic, want := range map[string]string{"battery_level": "80", "charging": "not_charging",
+		"mode": "safe", "dirt_detect": "0"} {
+		if got := b.get("roomba/r1/" + topic); got != want {
+			t.Errorf("got %s %q, want %q", topic, got, want)
+		}
+	}
+
+	b.send("roomba/r1/command", "clean")
+	h.Expect(rt.Cmd("Clean"), query)
+	if s := b.state(t); s.State != stateCleaning || s.Mode != "passive" {
+		t.Errorf("got state %+v, want cleaning", s)
+	}
+	b.send("roomba/r1/command", "return_to_base")
+	h.Expect(rt.Cmd("Seek_dock"), query)
+	if s := b.state(t); s.State != stateReturning {
+		t.Errorf("got state %+v, want returning", s)
+	}
+	b.send("roomba/r1/command", "stop")
+	h.Expect(rt.Safe(), rt.Drive(0, 0), rt.Start(), query)
+	if s := b.state(t); s.State != stateIdle {
+		t.Errorf("got state %+v, want idle", s)
+	}
+
+	song := []byte{locateSongNumber, byte(len(locateSong))}
+	for _, note := range locateSong {
+		song = append(song, note.Number, note.Duration)
+	}
+	b.send("roomba/r1/command", "locate")
+	h.Expect(rt.Safe(), rt.Cmd("Song", song...), rt.Cmd("Play", locateSongNumber), query)
+
+	b.send("roomba/r1/command", "dance")
+	if err := br.publishState(); err != nil {
+		t.Fatalf("failed publishing state: %s", err)
+	}
+	h.Expect(query)
+
+	// Only changes are published.
+	if n := b.count["roomba/r1/battery_level"]; n != 1 {
+		t.Errorf("battery level published %d times", n)
+	}
+}
+
+func TestVacuumState(t *testing.T) {
+	for _, test := range []struct {
+		activity         string
+		charging, source byte
+		want             string
+	}{
+		{stateCleaning, constants.CHARGING_NOT_CHARGING, 0, stateCleaning},
+		{stateReturning, constants.CHARGING_NOT_CHARGING, 0, stateReturning},
+		{stateReturning, constants.CHARGING_TRICKLE, constants.CHARGING_SOURCE_HOME_BASE, stateDocked},
+		{stateIdle, constants.CHARGING_WAITING, constants.CHARGING_SOURCE_HOME_BASE, stateDocked},
+		{stateIdle, constants.CHARGING_FAULT, constants.CHARGING_SOURCE_HOME_BASE, stateError},
+	} {
+		if got := vacuumState(test.activity, test.charging, test.source); got != test.want {
+			t.Errorf("vacuumState(%q, %d, %d) = %q, want %q", test.activity, test.charging, test.source,
+				got, test.want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+/*
+Command roomba-mqtt connects a Roomba to an MQTT broker, for home and office
+automation.
+
+	roomba-mqtt -port /dev/ttyUSB0 -broker tcp://localhost:1883 -id roomba1
+	roomba-mqtt -sim
+
+The state of the robot is published, retained, under roomba/<id>/ every
+-interval and after every command:
+
+	state          {"state": "docked", "battery_level": 80, "charging": "trickle",
+	                "mode": "passive", "dirt_detect": 0}
+	battery_level  80
+	charging       trickle
+	mode           passive
+	dirt_detect    0
+	availability   online or offline
+
+The OI doesn't report a full bin, only dirt detection. Commands are received
+on roomba/<id>/command: start (or clean), clean_spot (spot), return_to_base
+(dock), stop (pause), and locate, which plays a song and leaves the robot in
+Safe mode.
+
+Home Assistant finds the robot as an MQTT vacuum through its discovery
+topic, homeassistant/vacuum/<id>/config, unless -discovery is empty.
+*/
+package main
+
+import (
+	"flag"
+	"log"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/xa4a/go-roomba"
+	"github.com/xa4a/go-roomba/clock"
+	"github.com/xa4a/go-roomba/sim"
+)
+
+var (
+	port      = flag.String("port", "/dev/ttyUSB0", "serial port of the robot")
+	useSim    = flag.Bool("sim", false, "connect a simulated robot instead of the one on -port")
+	brokerURL = flag.String("broker", "tcp://localhost:1883", "URL of the MQTT broker")
+	user      = flag.String("user", "", "MQTT user name")
+	password  = flag.String("password", "", "MQTT password")
+	id        = flag.String("id", "roomba", "ID of the robot in topics and Home Assistant")
+	name      = flag.String("name", "Roomba", "name of the robot in Home Assistant")
+	prefix    = flag.String("prefix", "roomba", "prefix of the robot's topics")
+	discovery = flag.String("discovery", "homeassistant", "prefix of Home Assistant's discovery topics; empty to disable discovery")
+	interval  = flag.Duration("interval", 10*time.Second, "interval between state updates")
+)
+
+func main() {
+	flag.Parse()
+
+	var r *roomba.Roomba
+	if *useSim {
+		roombaSim, socket := sim.MakeRoombaSim()
+		defer roombaSim.Stop()
+		r = &roomba.Roomba{S: socket, StreamPaused: make(chan bool, 1)}
+		log.Printf("connecting a simulated robot")
+	} else {
+		var err error
+		if r, err = roomba.MakeRoomba(*port); err != nil {
+			log.Fatalf("failed to open %s: %s", *port, err)
+		}
+	}
+	if err := r.Start(); err != nil {
+		log.Fatalf("failed to start the OI: %s", err)
+	}
+
+	c := config{ID: *id, Name: *name, Prefix: *prefix, DiscoveryPrefix: *discovery}
+	b := &pahoBroker{}
+	br := makeBridge(r, b, c)
+	opts := mqtt.NewClientOptions().
+		AddBroker(*brokerURL).
+		SetClientID("roomba-mqtt-"+*id).
+		SetUsername(*user).
+		SetPassword(*password).
+		SetWill(br.topic("availability"), "offline", 1, true).
+		// Commands publish the new state, which must not wait for other
+		// messages to be handled.
+		SetOrderMatters(false).
+		SetOnConnectHandler(func(mqtt.Client) {
+			// Subscriptions don't survive reconnections.
+			log.Printf("connected to %s", *brokerURL)
+			if err := br.start(); err != nil {
+				log.Printf("failed to start: %s", err)
+			}
+		})
+	b.c = mqtt.NewClient(opts)
+	if t := b.c.Connect(); t.Wait() && t.Error() != nil {
+		log.Fatalf("failed to connect to %s: %s", *brokerURL, t.Error())
+	}
+	br.run(clock.Real, *interval, nil)
+}
+
+// pahoBroker is a broker reached through a Paho MQTT client.
+type pahoBroker struct {
+	c mqtt.Client
+}
+
+func (b *pahoBroker) Publish(topic string, retained bool, payload []byte) error {
+	t := b.c.Publish(topic, 1, retained, payload)
+	t.Wait()
+	return t.Error()
+}
+
+func (b *pahoBroker) Subscribe(topic string, handle func(payload []byte)) error {
+	t := b.c.Subscribe(topic, 1, func(_ mqtt.Client, m mqtt.Message) {
+		handle(m.Payload())
+	})
+	t.Wait()
+	return t.Error()
+}
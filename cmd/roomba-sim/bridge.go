@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errBusy = errors.New("simulator busy with another client")
+
+// defaultWriteTimeout is how long a client may take to read the simulator's
+// output before it is disconnected.
+const defaultWriteTimeout = time.Second
+
+// bridge connects clients to the simulator, one at a time. Like the robot's
+// UART, the simulator's output is dropped while no client is connected.
+type bridge struct {
+	rw           io.ReadWriter // Connection to the simulator.
+	traffic      bool
+	writeTimeout time.Duration
+
+	mu     sync.Mutex
+	client io.ReadWriter // Nil if none is connected.
+}
+
+func makeBridge(rw io.ReadWriter, traffic bool) *bridge {
+	b := &bridge{rw: rw, traffic: traffic, writeTimeout: defaultWriteTimeout}
+	go b.pump()
+	return b
+}
+
+// pump forwards the simulator's output to the connected client.
+func (b *bridge) pump() {
+	buf := make([]byte, 1024)
+	for {
+		n, err := b.rw.Read(buf)
+		if n > 0 {
+			b.log("robot -> host", buf[:n])
+			b.mu.Lock()
+			client := b.client
+			b.mu.Unlock()
+			if client != nil {
+				b.write(client, buf[:n])
+			}
+		}
+		if err != nil {
+			log.Printf("simulator closed: %s", err)
+			return
+		}
+	}
+}
+
+// write sends the simulator's output to a client, which is disconnected if
+// it doesn't read it in time, so that it can't stall the simulator.
+func (b *bridge) write(client io.ReadWriter, data []byte) {
+	if c, ok := client.(interface{ SetWriteDeadline(time.Time) error }); ok {
+		c.SetWriteDeadline(time.Now().Add(b.writeTimeout))
+	}
+	if _, err := client.Write(data); err != nil {
+		log.Printf("failed writing to the client, disconnecting it: %s", err)
+		// Ends serve, which lets another client connect.
+		if c, ok := client.(io.Closer); ok {
+			c.Close()
+		}
+	}
+}
+
+// serve forwards the bytes sent by client to the simulator until the client
+// disconnects.
+func (b *bridge) serve(client io.ReadWriter) error {
+	b.mu.Lock()
+	if b.client != nil {
+		b.mu.Unlock()
+		return errBusy
+	}
+	b.client = client
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		b.client = nil
+		b.mu.Unlock()
+	}()
+
+	buf := make([]byte, 1024)
+	for {
+		n, err := client.Read(buf)
+		if n > 0 {
+			b.log("host -> robot", buf[:n])
+			if _, err := b.rw.Write(buf[:n]); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// serveListener accepts clients until the listener fails.
+func (b *bridge) serveListener(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			log.Printf("client %s connected", conn.RemoteAddr())
+			if err := b.serve(conn); err != nil {
+				log.Printf("client %s: %s", conn.RemoteAddr(), err)
+				return
+			}
+			log.Printf("client %s disconnected", conn.RemoteAddr())
+		}()
+	}
+}
+
+func (b *bridge) log(direction string, data []byte) {
+	if !b.traffic {
+		return
+	}
+	hex := make([]string, len(data))
+	for i, d := range data {
+		hex[i] = fmt.Sprintf("%02x", d)
+	}
+	log.Printf("%s: %s", direction, strings.Join(hex, " "))
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/xa4a/go-roomba"
+	"github.com/xa4a/go-roomba/clock"
+	"github.com/xa4a/go-roomba/constants"
+	"github.com/xa4a/go-roomba/sim"
+)
+
+func TestServeTCP(t *testing.T) {
+	clk := clock.MakeVirtual(time.Unix(0, 0))
+	roombaSim, socket := sim.MakeRoombaSimClock(clk)
+	defer roombaSim.Stop()
+	b := makeBridge(socket, false)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	defer l.Close()
+	go b.serveListener(l)
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to connect: %s", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	r := &roomba.Roomba{S: conn, StreamPaused: make(chan bool, 1)}
+	r.Start()
+	r.Safe()
+	r.DriveStraight(200)
+	mode, err := r.Sensors(constants.SENSOR_OI_MODE)
+	if err != nil {
+		t.Fatalf("failed reading mode over TCP: %s", err)
+	}
+	if mode[0] != constants.OI_MODE_SAFE {
+		t.Errorf("got mode %d, want safe", mode[0])
+	}
+
+	// The robot moves, as shown on the status page. The drive command was
+	// handled before the query.
+	clk.Advance(100 * time.Millisecond)
+	srv := httptest.NewServer(statusHandler(roombaSim))
+	defer srv.Close()
+	resp, err := srv.Client().Get(srv.URL + "/status.json")
+	if err != nil {
+		t.Fatalf("failed getting status: %s", err)
+	}
+	defer resp.Body.Close()
+	var s status
+	if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+		t.Fatalf("failed decoding status: %s", err)
+	}
+	if s.X <= 0 || s.Mode != constants.OI_MODE_SAFE {
+		t.Errorf("unexpected status %+v", s)
+	}
+}
+
+func TestOneClientAtATime(t *testing.T) {
+	roombaSim, socket := sim.MakeRoombaSim()
+	defer roombaSim.Stop()
+	b := makeBridge(socket, false)
+	first, host := net.Pipe()
+	go b.serve(first)
+	defer first.Close()
+
+	// The pipe is synchronous, so the first client is served once the
+	// bridge read its start command.
+	host.Write([]byte{constants.OpCodes["Start"]})
+	second, _ := net.Pipe()
+	defer second.Close()
+	if err := b.serve(second); err != errBusy {
+		t.Errorf("second client served concurrently, err %v", err)
+	}
+}
+
+func TestStalledClientDisconnected(t *testing.T) {
+	roombaSim, socket := sim.MakeRoombaSim()
+	defer roombaSim.Stop()
+	b := makeBridge(socket, false)
+	b.writeTimeout = 10 * time.Millisecond
+	first, host := net.Pipe()
+	defer host.Close()
+	done := make(chan error)
+	go func() { done <- b.serve(first) }()
+
+	// The first client queries the mode but never reads the response.
+	host.Write([]byte{constants.OpCodes["Start"], constants.OpCodes["Sensors"], constants.SENSOR_OI_MODE})
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stalled client not disconnected")
+	}
+
+	// Another client connects, and gets the simulator's output.
+	second, host := net.Pipe()
+	defer second.Close()
+	go b.serve(second)
+	host.SetDeadline(time.Now().Add(5 * time.Second))
+	r := &roomba.Roomba{S: host, StreamPaused: make(chan bool, 1)}
+	mode, err := r.Sensors(constants.SENSOR_OI_MODE)
+	if err != nil {
+		t.Fatalf("failed reading mode after a stalled client: %s", err)
+	}
+	if mode[0] != constants.OI_MODE_PASSIVE {
+		t.Errorf("got mode %d, want passive", mode[0])
+	}
+}
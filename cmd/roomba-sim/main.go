@@ -0,0 +1,93 @@
+/*
+Command roomba-sim serves the go-roomba simulator over TCP or a pseudo-terminal,
+so that any OI client can connect to it as if it were a real robot.
+
+	roomba-sim -listen :2000 -world room.json -http :8080
+	roomba-sim -pty
+
+With -pty the path of the terminal to open is printed on startup. All traffic
+is logged, and -http serves a status page with the simulated pose. The
+simulator's state changes are logged too, and with -v every command it
+executes.
+*/
+package main
+
+import (
+	"flag"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/creack/pty"
+	"golang.org/x/term"
+
+	"github.com/xa4a/go-roomba/sim"
+)
+
+var (
+	listen    = flag.String("listen", ":2000", "TCP address to serve the simulator on")
+	usePty    = flag.Bool("pty", false, "serve the simulator on a pseudo-terminal instead of TCP")
+	worldPath = flag.String("world", "", "JSON world description for the simulator")
+	httpAddr  = flag.String("http", "", "address of the status page, e.g. :8080; empty to disable")
+	traffic   = flag.Bool("traffic", true, "log all traffic between the client and the simulator")
+	verbose   = flag.Bool("v", false, "log every command executed by the simulator")
+)
+
+func main() {
+	flag.Parse()
+
+	roombaSim, socket := sim.MakeRoombaSim()
+	level := slog.LevelInfo
+	if *verbose {
+		level = slog.LevelDebug
+	}
+	roombaSim.SetLogger(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+	if *worldPath != "" {
+		world, err := sim.LoadWorld(*worldPath)
+		if err != nil {
+			log.Fatalf("failed loading world: %s", err)
+		}
+		roombaSim.SetWorld(world)
+	}
+	b := makeBridge(socket, *traffic)
+
+	if *httpAddr != "" {
+		http.Handle("/", statusHandler(roombaSim))
+		go func() {
+			log.Fatal(http.ListenAndServe(*httpAddr, nil))
+		}()
+		log.Printf("status page on %s", *httpAddr)
+	}
+
+	if *usePty {
+		servePty(b)
+		return
+	}
+	l, err := net.Listen("tcp", *listen)
+	if err != nil {
+		log.Fatalf("failed to listen: %s", err)
+	}
+	log.Printf("serving simulator on %s", l.Addr())
+	log.Fatal(b.serveListener(l))
+}
+
+// servePty serves the simulator on a new pseudo-terminal.
+func servePty(b *bridge) {
+	ptmx, tty, err := pty.Open()
+	if err != nil {
+		log.Fatalf("failed to open pty: %s", err)
+	}
+	defer ptmx.Close()
+	// Keep the terminal open so that clients can come and go, and pass
+	// bytes through unchanged.
+	defer tty.Close()
+	if _, err := term.MakeRaw(int(tty.Fd())); err != nil {
+		log.Fatalf("failed to set pty to raw mode: %s", err)
+	}
+	log.Printf("serving simulator on %s", tty.Name())
+	if err := b.serve(ptmx); err != nil {
+		log.Fatalf("pty failed: %s", err)
+	}
+}
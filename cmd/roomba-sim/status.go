@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"html/template"
+	"log"
+	"math"
+	"net/http"
+
+	"github.com/xa4a/go-roomba/sim"
+)
+
+// status is a snapshot of the simulator shown on the status page.
+type status struct {
+	X       float64 `json:"x"`       // m
+	Y       float64 `json:"y"`       // m
+	Heading float64 `json:"heading"` // degrees
+	Mode    byte    `json:"mode"`
+
+	LeftVelocity  float64 `json:"left_velocity"`  // mm/s
+	RightVelocity float64 `json:"right_velocity"` // mm/s
+
+	BatteryCharge   float64 `json:"battery_charge"`   // mAh
+	BatteryCapacity float64 `json:"battery_capacity"` // mAh
+	ChargingState   byte    `json:"charging_state"`
+}
+
+func getStatus(s *sim.RoombaSimulator) status {
+	p, b := s.Physics(), s.Battery()
+	return status{
+		X:               p.Pose.X,
+		Y:               p.Pose.Y,
+		Heading:         p.Pose.Theta * 180 / math.Pi,
+		Mode:            s.Mode(),
+		LeftVelocity:    p.Wheels.Left,
+		RightVelocity:   p.Wheels.Right,
+		BatteryCharge:   b.Charge,
+		BatteryCapacity: b.Capacity,
+		ChargingState:   b.State,
+	}
+}
+
+var modeNames = []string{"off", "passive", "safe", "full"}
+
+var statusPage = template.Must(template.New("status").Funcs(template.FuncMap{
+	"mode": func(m byte) string {
+		if int(m) < len(modeNames) {
+			return modeNames[m]
+		}
+		return "unknown"
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta http-equiv="refresh" content="1">
+<title>Roomba simulator</title>
+</head>
+<body>
+<h1>Roomba simulator</h1>
+<table>
+<tr><td>Position</td><td>{{printf "%.3f" .X}} m, {{printf "%.3f" .Y}} m</td></tr>
+<tr><td>Heading</td><td>{{printf "%.1f" .Heading}}&deg;</td></tr>
+<tr><td>Wheels</td><td>left {{printf "%.0f" .LeftVelocity}} mm/s, right {{printf "%.0f" .RightVelocity}} mm/s</td></tr>
+<tr><td>Mode</td><td>{{mode .Mode}}</td></tr>
+<tr><td>Battery</td><td>{{printf "%.0f" .BatteryCharge}} / {{printf "%.0f" .BatteryCapacity}} mAh, charging state {{.ChargingState}}</td></tr>
+</table>
+</body>
+</html>
+`))
+
+// statusHandler serves the status page at / and the same data as JSON at
+// /status.json.
+func statusHandler(s *sim.RoombaSimulator) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(getStatus(s)); err != nil {
+			log.Printf("failed writing status: %s", err)
+		}
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		if err := statusPage.Execute(w, getStatus(s)); err != nil {
+			log.Printf("failed writing status page: %s", err)
+		}
+	})
+	return mux
+}
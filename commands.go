@@ -22,11 +22,13 @@ package roomba
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"math"
 
 	"github.com/xa4a/go-roomba/constants"
+	"github.com/xa4a/go-roomba/kinematics"
 )
 
 func to_byte(b bool) byte {
@@ -89,7 +91,11 @@ func (this *Roomba) Clean() error {
 	return this.WriteByte(OpCodes["Clean"])
 }
 
-// TODO: Max command.
+// Max command starts the Max cleaning mode, which cleans until the battery
+// is dead.
+func (this *Roomba) Max() error {
+	return this.WriteByte(OpCodes["Max"])
+}
 
 // Spot command starts the Spot cleaning mode.
 func (this *Roomba) Spot() error {
@@ -98,7 +104,7 @@ func (this *Roomba) Spot() error {
 
 // SeekDock command sends Roomba to the dock.
 func (this *Roomba) SeekDock() error {
-	return this.WriteByte(OpCodes["SeekDock"])
+	return this.WriteByte(OpCodes["Seek_dock"])
 }
 
 // TODO: Schedule, Set Day/Time.
@@ -120,17 +126,94 @@ func (this *Roomba) Power() error {
 // or drive straight. A negative velocity makes Roomba drive backward. Velocity
 // is in range (-500 – 500 mm/s), radius (-2000 – 2000 mm). Special cases:
 // straight = 32768 or 32767 = hex 8000 or 7FFF, turn in place clockwise = -1,
-// turn in place counter-clockwise = 1
+// turn in place counter-clockwise = 1. DriveStraight, SpinCW, SpinCCW and
+// DriveArc cover the special cases without magic numbers.
 func (this *Roomba) Drive(velocity, radius int16) error {
 	if !(-500 <= velocity && velocity <= 500) {
-		return fmt.Errorf("invalid velocity: %d", velocity)
+		return fmt.Errorf("%w: velocity %d", ErrInvalidArgument, velocity)
 	}
-	if !(-2000 <= radius && radius <= 2000) {
-		fmt.Errorf("invalid readius: %d", radius)
+	if !validRadius(radius) {
+		return fmt.Errorf("%w: radius %d", ErrInvalidArgument, radius)
 	}
 	return this.Write(OpCodes["Drive"], Pack([]interface{}{velocity, radius}))
 }
 
+// validRadius reports whether radius is accepted by the Drive command, either
+// as a regular radius or as one of the special cases.
+func validRadius(radius int16) bool {
+	switch radius {
+	case constants.DRIVE_STRAIGHT, -32768:
+		return true
+	}
+	return -2000 <= radius && radius <= 2000
+}
+
+// DriveStraight makes Roomba drive straight at the given velocity (mm/s).
+func (this *Roomba) DriveStraight(velocity int16) error {
+	return this.Drive(velocity, constants.DRIVE_STRAIGHT)
+}
+
+// SpinCW makes Roomba turn in place clockwise. The velocity (mm/s) is that of
+// the wheels.
+func (this *Roomba) SpinCW(velocity int16) error {
+	return this.Drive(velocity, constants.DRIVE_SPIN_CW)
+}
+
+// SpinCCW makes Roomba turn in place counter-clockwise. The velocity (mm/s) is
+// that of the wheels.
+func (this *Roomba) SpinCCW(velocity int16) error {
+	return this.Drive(velocity, constants.DRIVE_SPIN_CCW)
+}
+
+// DriveArc makes Roomba drive along an arc of the given radius (mm) at the
+// given velocity (mm/s). Unlike Drive, radii below 2 mm are rejected, so that
+// a radius of 1 mm never silently turns into a spin.
+func (this *Roomba) DriveArc(velocity, radius int16) error {
+	if err := CheckArcRadius(radius); err != nil {
+		return err
+	}
+	return this.Drive(velocity, radius)
+}
+
+// CheckArcRadius returns the error of DriveArc for radius, if it isn't
+// accepted, for other implementations of Robot.
+func CheckArcRadius(radius int16) error {
+	if (-2 < radius && radius < 2) || !(-2000 <= radius && radius <= 2000) {
+		return fmt.Errorf("%w: radius %d", ErrInvalidArgument, radius)
+	}
+	return nil
+}
+
+// DriveTwist makes Roomba move with the given linear (m/s) and angular (rad/s,
+// counter-clockwise positive) velocities by sending the closest matching
+// command. Straight motion and turning in place use the special cases of
+// Drive, arcs with a radius Drive can express use Drive, and anything else
+// falls back to DirectDrive. Twists the wheels can't reach are slowed down
+// along the same curve, as kinematics.Drive does.
+func (this *Roomba) DriveTwist(linear, angular float64) error {
+	if math.IsNaN(linear) || math.IsInf(linear, 0) || math.IsNaN(angular) || math.IsInf(angular, 0) {
+		return fmt.Errorf("%w: twist %g m/s, %g rad/s", ErrInvalidArgument, linear, angular)
+	}
+	twist := kinematics.Twist{Linear: linear, Angular: angular}.Wheels().
+		Saturate(kinematics.MaxWheelVelocity).Twist()
+	velocity := math.Round(twist.Linear * 1000)
+	// Velocity of each wheel relative to the center when turning.
+	turn := twist.Angular * constants.WHEEL_SEPARATION / 2
+	switch {
+	case math.Round(turn) == 0:
+		return this.DriveStraight(int16(velocity))
+	case velocity == 0 && turn > 0:
+		return this.SpinCCW(int16(math.Round(turn)))
+	case velocity == 0:
+		return this.SpinCW(int16(math.Round(-turn)))
+	}
+	radius := math.Round(twist.Linear * 1000 / twist.Angular)
+	if 2 <= math.Abs(radius) && math.Abs(radius) <= 2000 {
+		return this.Drive(int16(velocity), int16(radius))
+	}
+	return this.DirectDrive(twist.Wheels().Command())
+}
+
 // Stop commands is equivalent to Drive(0, 0).
 func (this *Roomba) Stop() error {
 	return this.Drive(0, 0)
@@ -146,12 +229,35 @@ func (this *Roomba) Stop() error {
 func (this *Roomba) DirectDrive(right, left int16) error {
 	if !(-500 <= right && right <= 500) ||
 		!(-500 <= left && left <= 500) {
-		return fmt.Errorf("invalid velocity. one of %d or %d", right, left)
+		return fmt.Errorf("%w: velocity. one of %d or %d", ErrInvalidArgument, right, left)
 	}
 	return this.Write(OpCodes["DirectDrive"], Pack([]interface{}{right, left}))
 }
 
-// TODO: Drive PWM, Motors, PWM Motors commands.
+// DrivePWM command lets you control the raw forward and backward motion of
+// Roomba’s drive wheels independently. It takes two 16-bit signed values. The
+// first specifies the PWM of the right wheel, the next one that of the left
+// wheel. A positive PWM makes that wheel drive forward, while a negative PWM
+// makes it drive backward. Right and left wheel PWM (-255 – 255).
+func (this *Roomba) DrivePWM(right, left int16) error {
+	if !(-255 <= right && right <= 255) ||
+		!(-255 <= left && left <= 255) {
+		return fmt.Errorf("%w: pwm. one of %d or %d", ErrInvalidArgument, right, left)
+	}
+	return this.Write(OpCodes["DrivePwm"], Pack([]interface{}{right, left}))
+}
+
+// Motors command turns the side brush, vacuum and main brush of Roomba on and
+// off. The brushes turn in their default direction.
+func (this *Roomba) Motors(side_brush, vacuum, main_brush bool) error {
+	var motors byte
+	for i, bit := range []bool{side_brush, vacuum, main_brush} {
+		motors |= to_byte(bit) << i
+	}
+	return this.Write(OpCodes["Motors"], []byte{motors})
+}
+
+// TODO: PWM Motors command.
 
 // LEDs command controls the LEDs common to all models of Roomba 500. The
 // Clean/Power LED is specified by two data bytes: one for the color and the
@@ -169,7 +275,41 @@ func (this *Roomba) LEDs(check_robot, dock, spot, debris bool, power_color, powe
 		led_bits, power_color, power_intensity}))
 }
 
-// TODO: Scheduling LEDs, Digit LEDs ASCII, Buttons, Song, Play.
+// TODO: Scheduling LEDs, Digit LEDs ASCII, Buttons.
+
+// Note is a note of a song.
+type Note struct {
+	Number   byte // MIDI note number (31 – 127), other values are rests.
+	Duration byte // In 1/64ths of a second.
+}
+
+// Song command specifies a song to be played later with the Play command.
+// Roomba stores up to five songs (0 – 4) of up to 16 notes each.
+func (this *Roomba) Song(song_number byte, notes []Note) error {
+	if song_number > 4 {
+		return fmt.Errorf("%w: song number %d", ErrInvalidArgument, song_number)
+	}
+	if len(notes) < 1 || len(notes) > 16 {
+		return fmt.Errorf("%w: number of notes %d", ErrInvalidArgument, len(notes))
+	}
+	b := new(bytes.Buffer)
+	b.WriteByte(song_number)
+	b.WriteByte(byte(len(notes)))
+	for _, note := range notes {
+		b.WriteByte(note.Number)
+		b.WriteByte(note.Duration)
+	}
+	return this.Write(OpCodes["Song"], b.Bytes())
+}
+
+// Play command plays a song specified with the Song command. It doesn't play
+// anything if another song is playing, or in Passive mode.
+func (this *Roomba) Play(song_number byte) error {
+	if song_number > 4 {
+		return fmt.Errorf("%w: song number %d", ErrInvalidArgument, song_number)
+	}
+	return this.Write(OpCodes["Play"], []byte{song_number})
+}
 
 // Sensors command requests the OI to send a packet of sensor data bytes. There
 // are 58 different sensor data packets. Each provides a value of a specific
@@ -177,7 +317,7 @@ func (this *Roomba) LEDs(check_robot, dock, spot, debris bool, power_color, powe
 func (this *Roomba) Sensors(packet_id byte) ([]byte, error) {
 	bytes_to_read, ok := constants.SENSOR_PACKET_LENGTH[packet_id]
 	if !ok {
-		return []byte{}, fmt.Errorf("unknown packet id requested: %d", packet_id)
+		return []byte{}, fmt.Errorf("%w: unknown packet id %d", ErrInvalidArgument, packet_id)
 	}
 
 	this.Write(OpCodes["Sensors"], []byte{packet_id})
@@ -189,21 +329,28 @@ func (this *Roomba) Sensors(packet_id byte) ([]byte, error) {
 		bytes_to_read -= byte(n)
 		n, err = this.Read(result_view)
 		if err != nil {
-			log.Printf("error %v", err)
+			this.logger().Error("failed reading sensors", LogPacketId, packet_id, "err", err)
 			return result, fmt.Errorf("failed reading sensors data for packet id %d: %s", packet_id, err)
 		}
 	}
 	return result, nil
 }
 
+// MaxPackets is the largest number of packets QueryList and Stream accept, as
+// the number is sent in a single byte.
+const MaxPackets = 255
+
 // QueryList command lets you ask for a list of sensor packets. The result is
 // returned once, as in the Sensors command. The robot returns the packets in
 /// the order you specify.
 func (this *Roomba) QueryList(packet_ids []byte) ([][]byte, error) {
+	if len(packet_ids) > MaxPackets {
+		return [][]byte{}, fmt.Errorf("%w: %d packets, at most %d", ErrInvalidArgument, len(packet_ids), MaxPackets)
+	}
 	for _, packet_id := range packet_ids {
 		_, ok := constants.SENSOR_PACKET_LENGTH[packet_id]
 		if !ok {
-			return [][]byte{}, fmt.Errorf("unknown packet id requested: %d", packet_id)
+			return [][]byte{}, fmt.Errorf("%w: unknown packet id %d", ErrInvalidArgument, packet_id)
 		}
 	}
 
@@ -237,22 +384,30 @@ func (this *Roomba) PauseStream() {
 	this.StreamPaused <- true
 }
 
+// ReadStream reads the stream frames of the given packets into out, dropping
+// frames with a wrong checksum. When the data doesn't look like a frame, as
+// after a lost byte, it skips to the next header to resync. It closes out when
+// the stream is paused or ends.
 func (this *Roomba) ReadStream(packet_ids []byte, out chan<- [][]byte) {
-	var data_length byte
+	data_length := 0
 	for _, packet_id := range packet_ids {
 		packet_length, ok := constants.SENSOR_PACKET_LENGTH[packet_id]
 		if !ok {
-			log.Printf("unknown packet id requested: %d", packet_id)
+			this.logger().Error("unknown packet id requested", LogPacketId, packet_id)
+			close(out)
 			return
 		}
-		data_length += packet_length
+		data_length += int(packet_length)
 	}
 
 	// Input buffer. 3 is for 19, N-bytes and checksum.
-	buf := make([]byte, data_length+byte(len(packet_ids))+3)
+	buf := make([]byte, data_length+len(packet_ids)+3)
+	bytes_read := 0
+	// Whether the last frame was valid, so that a loss of sync is only
+	// counted once.
+	synced := true
 
 	for {
-	Loop:
 		select {
 		case <-this.StreamPaused:
 			// Pause stream.
@@ -260,71 +415,85 @@ func (this *Roomba) ReadStream(packet_ids []byte, out chan<- [][]byte) {
 			close(out)
 			return
 		default:
-			// Read single stream frame.
-			bytes_read := 0
-			for bytes_read < len(buf) {
-				n, err := this.S.Read(buf[bytes_read:])
-				if n != 0 {
-					bytes_read += n
-				}
-				if err != nil {
-					if err == io.EOF {
-						return
-					}
-					goto Loop
-				}
-			}
-			// Process frame.
-			buf_r := bytes.NewReader(buf)
-			if b, err := buf_r.ReadByte(); err != nil || b != 19 {
-				log.Fatalf("stream data doesn't start with header 19")
-				return
-			}
-			if b, err := buf_r.ReadByte(); err != nil || b != byte(len(buf)-3) {
-				log.Fatalf("invalid N-bytes: %d, expected %d.", buf[1],
-					len(buf)-3)
-			}
+		}
+		// Read single stream frame.
+		n, err := this.S.Read(buf[bytes_read:])
+		bytes_read += n
+		if err == io.EOF {
+			close(out)
+			return
+		}
+		if bytes_read < len(buf) {
+			continue
+		}
+		this.logger().Debug("stream frame", LogDirection, RobotToHost, "data", buf)
+		result, err := decodeFrame(buf, packet_ids)
+		switch {
+		case err == nil:
+			synced = true
+			bytes_read = 0
+			out <- result
+			continue
+		case err == errChecksum:
+			this.logger().Warn("computed checksum didn't match, dropping frame")
+			this.checksumErrors.Add(1)
+		case synced:
+			this.logger().Warn("lost stream sync, skipping to the next header", "error", err)
+			this.framingErrors.Add(1)
+		}
+		// The frame may have been cut short, and the next one start within
+		// it.
+		synced = false
+		next := bytes.IndexByte(buf[1:], 19)
+		if next < 0 {
+			bytes_read = 0
+		} else {
+			bytes_read = copy(buf, buf[1+next:])
+		}
+	}
+}
 
-			result := make([][]byte, len(packet_ids))
-
-			i := 0
-			// Used for verifying checksum.
-			sum := byte(len(buf) - 3) // N-bytes
-			packet_id, err := buf_r.ReadByte()
-			for ; err == nil; packet_id, err = buf_r.ReadByte() {
-				sum += packet_id
-				bytes_to_read := int(constants.SENSOR_PACKET_LENGTH[packet_id])
-				bytes_read := 0
-				result[i] = make([]byte, bytes_to_read)
-
-				for bytes_to_read > 0 {
-					n, err := buf_r.Read(result[i][bytes_read:])
-					bytes_read += n
-					bytes_to_read -= n
-					if err != nil {
-						log.Fatalf("error reading packet data")
-					}
-				}
-				for _, b := range result[i] {
-					sum += b
-				}
-				i += 1
-				if buf_r.Len() == 1 {
-					break
-				}
-			}
+var errChecksum = errors.New("wrong checksum")
 
-			expected_checksum, err := buf_r.ReadByte()
-			if err != nil {
-				log.Fatalf("missing checksum")
-			}
-			sum += expected_checksum
-			if sum != 0 {
-				log.Fatalf("computed checksum didn't match: %d", sum)
-			}
-			out <- result
+// decodeFrame returns the data of the packets of a stream frame.
+func decodeFrame(frame, packet_ids []byte) ([][]byte, error) {
+	if frame[0] != 19 {
+		return nil, fmt.Errorf("header %d, expected 19", frame[0])
+	}
+	if int(frame[1]) != len(frame)-3 {
+		return nil, fmt.Errorf("N-bytes %d, expected %d", frame[1], len(frame)-3)
+	}
+	result := make([][]byte, len(packet_ids))
+	data := frame[2 : len(frame)-1]
+	for i, packet_id := range packet_ids {
+		if data[0] != packet_id {
+			return nil, fmt.Errorf("packet id %d, expected %d", data[0], packet_id)
 		}
+		packet_length := int(constants.SENSOR_PACKET_LENGTH[packet_id])
+		result[i] = append([]byte(nil), data[1:1+packet_length]...)
+		data = data[1+packet_length:]
 	}
+	// Used for verifying checksum, which covers the header too.
+	var sum byte
+	for _, b := range frame {
+		sum += b
+	}
+	if sum != 0 {
+		return nil, errChecksum
+	}
+	return result, nil
+}
+
+// ChecksumErrors returns the number of stream frames ReadStream dropped for
+// a wrong checksum.
+func (this *Roomba) ChecksumErrors() uint64 {
+	return this.checksumErrors.Load()
+}
+
+// FramingErrors returns the number of times ReadStream lost the frame
+// boundaries and skipped data to find the next frame.
+func (this *Roomba) FramingErrors() uint64 {
+	return this.framingErrors.Load()
 }
 
 // Stream command starts a stream of data packets. The list of packets
@@ -333,6 +502,9 @@ func (this *Roomba) ReadStream(packet_ids []byte, out chan<- [][]byte) {
 // over a wireless network (which has poor real-time characteristics) with
 // software running on a desktop computer.
 func (this *Roomba) Stream(packet_ids []byte) (<-chan [][]byte, error) {
+	if len(packet_ids) > MaxPackets {
+		return nil, fmt.Errorf("%w: %d packets, at most %d", ErrInvalidArgument, len(packet_ids), MaxPackets)
+	}
 	b := new(bytes.Buffer)
 	b.WriteByte(byte(len(packet_ids)))
 	b.Write(packet_ids)
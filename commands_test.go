@@ -1,33 +1,64 @@
-//Tests for roomba package functions
+// Tests for roomba package functions
 package roomba_test
 
 import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
 	"testing"
 
+	"github.com/xa4a/go-roomba"
 	"github.com/xa4a/go-roomba/constants"
 	rt "github.com/xa4a/go-roomba/testing"
 )
 
 func TestDrive(t *testing.T) {
-	expected := []byte{137, 255, 56, 1, 244}
-	r := rt.MakeTestRoomba()
-	defer rt.ClearTestRoomba()
-	r.Drive(-200, 500)
-	rt.VerifyWritten(r, expected, t)
+	t.Parallel()
+	h := rt.NewHarness(t)
+	h.Roomba.Drive(-200, 500)
+	h.Expect(rt.Drive(-200, 500))
+	// The same in raw bytes.
+	h.Roomba.Drive(-200, 500)
+	h.VerifyWritten([]byte{137, 255, 56, 1, 244})
 }
 
 func TestLEDs(t *testing.T) {
+	t.Parallel()
 	expected := []byte{139, 4, 0, 128}
-	r := rt.MakeTestRoomba()
-	defer rt.ClearTestRoomba()
+	h := rt.NewHarness(t)
+	r := h.Roomba
 	r.LEDs(false, true, false, false, 0, 128)
-	rt.VerifyWritten(r, expected, t)
+	h.VerifyWritten(expected)
+}
+
+func TestMotors(t *testing.T) {
+	t.Parallel()
+	h := rt.NewHarness(t)
+	h.Roomba.Motors(true, false, true)
+	h.Expect(rt.Cmd("Motors", constants.MOTOR_SIDE_BRUSH|constants.MOTOR_MAIN_BRUSH))
+}
+
+func TestSong(t *testing.T) {
+	t.Parallel()
+	h := rt.NewHarness(t)
+	r := h.Roomba
+	if err := r.Song(5, []roomba.Note{{Number: 60, Duration: 32}}); err == nil {
+		t.Errorf("song number 5 accepted")
+	}
+	if err := r.Song(0, nil); err == nil {
+		t.Errorf("song without notes accepted")
+	}
+	r.Song(1, []roomba.Note{{Number: 60, Duration: 32}, {Number: 31, Duration: 16}})
+	r.Play(1)
+	h.Expect(rt.Cmd("Song", 1, 2, 60, 32, 31, 16), rt.Cmd("Play", 1))
 }
 
 func TestQueryLists(t *testing.T) {
+	t.Parallel()
 	output := []byte{3, 5}
-	r := rt.MakeTestRoomba()
-	defer rt.ClearTestRoomba()
+	h := rt.NewHarness(t)
+	r := h.Roomba
 
 	expected_input := []byte{149, 2, 7, 13}
 	res, err := r.QueryList([]byte{
@@ -36,7 +67,7 @@ func TestQueryLists(t *testing.T) {
 	if err != nil {
 		t.Fatalf("error querying sensors: %s", err)
 	}
-	rt.VerifyWritten(r, expected_input, t)
+	h.VerifyWritten(expected_input)
 	for i, b := range res {
 		if len(b) != 1 {
 			t.Errorf("query_list returned wrong packet len for packet_id %d",
@@ -49,9 +80,10 @@ func TestQueryLists(t *testing.T) {
 }
 
 func TestStream(t *testing.T) {
+	t.Parallel()
 	expected_data := [][]byte{{2, 25}, {5}}
-	r := rt.MakeTestRoomba()
-	defer rt.ClearTestRoomba()
+	h := rt.NewHarness(t)
+	r := h.Roomba
 
 	expected_input := []byte{148, 2, 29, 13}
 	out, err := r.Stream([]byte{
@@ -61,7 +93,7 @@ func TestStream(t *testing.T) {
 		t.Fatal("error querying senors")
 	}
 	response := <-out
-	rt.VerifyWritten(r, expected_input, t)
+	h.VerifyWritten(expected_input)
 	for i, packet_data := range response {
 		for j, packet_byte := range packet_data {
 			if expected_data[i][j] != packet_byte {
@@ -71,9 +103,114 @@ func TestStream(t *testing.T) {
 	}
 }
 
+func TestStreamChecksumErrors(t *testing.T) {
+	t.Parallel()
+	// Frames of the bumps packet, the second one with a wrong checksum.
+	stream := []byte{
+		19, 2, 7, 1, 227,
+		19, 2, 7, 2, 0,
+		19, 2, 7, 3, 225,
+	}
+	r := &roomba.Roomba{S: struct {
+		io.Reader
+		io.Writer
+	}{bytes.NewReader(stream), &bytes.Buffer{}}, StreamPaused: make(chan bool, 1)}
+	out := make(chan [][]byte, 3)
+	r.ReadStream([]byte{constants.SENSOR_BUMP_WHEELS_DROPS}, out)
+	var received []byte
+	for frame := range out {
+		received = append(received, frame[0][0])
+	}
+	if !bytes.Equal(received, []byte{1, 3}) {
+		t.Errorf("received frames %v, want 1 and 3", received)
+	}
+	if n := r.ChecksumErrors(); n != 1 {
+		t.Errorf("got %d checksum errors, want 1", n)
+	}
+}
+
+func TestStreamSpecFrame(t *testing.T) {
+	t.Parallel()
+	// The example frame of the OI specification.
+	stream := []byte{19, 5, 29, 2, 25, 13, 0, 163}
+	r := &roomba.Roomba{S: struct {
+		io.Reader
+		io.Writer
+	}{bytes.NewReader(stream), &bytes.Buffer{}}, StreamPaused: make(chan bool, 1)}
+	out := make(chan [][]byte, 1)
+	r.ReadStream([]byte{constants.SENSOR_CLIFF_FRONT_LEFT_SIGNAL, constants.SENSOR_VIRTUAL_WALL}, out)
+	frame, ok := <-out
+	if !ok {
+		t.Fatalf("frame dropped, %d checksum errors", r.ChecksumErrors())
+	}
+	if !bytes.Equal(frame[0], []byte{2, 25}) || !bytes.Equal(frame[1], []byte{0}) {
+		t.Errorf("got packets %v, want [2 25] and [0]", frame)
+	}
+}
+
+func TestStreamResync(t *testing.T) {
+	t.Parallel()
+	// Frames of the bumps packet after line noise, the second one cut
+	// short.
+	stream := []byte{
+		0x55, 19,
+		19, 2, 7, 1, 227,
+		19, 2, 7,
+		19, 2, 7, 3, 225,
+		19, 2, 7, 4, 224,
+	}
+	r := &roomba.Roomba{S: struct {
+		io.Reader
+		io.Writer
+	}{bytes.NewReader(stream), &bytes.Buffer{}}, StreamPaused: make(chan bool, 1)}
+	out := make(chan [][]byte, 3)
+	r.ReadStream([]byte{constants.SENSOR_BUMP_WHEELS_DROPS}, out)
+	var received []byte
+	for frame := range out {
+		received = append(received, frame[0][0])
+	}
+	if !bytes.Equal(received, []byte{1, 3, 4}) {
+		t.Errorf("received frames %v, want 1, 3 and 4", received)
+	}
+	if n := r.FramingErrors(); n != 1 {
+		t.Errorf("got %d framing errors, want 1", n)
+	}
+	if n := r.ChecksumErrors(); n != 1 {
+		t.Errorf("got %d checksum errors, want 1", n)
+	}
+}
+
+func TestQueryListLength(t *testing.T) {
+	t.Parallel()
+	h := rt.NewHarness(t)
+	r := h.Roomba
+	ids := bytes.Repeat([]byte{constants.SENSOR_OI_MODE}, 40)
+	modes, err := r.QueryList(ids)
+	if err != nil {
+		t.Fatalf("error querying %d packets: %s", len(ids), err)
+	}
+	if len(modes) != len(ids) || modes[len(ids)-1][0] != constants.OI_MODE_SAFE {
+		t.Errorf("got modes %v", modes)
+	}
+	h.Expect(rt.QueryList(ids...))
+
+	// The number of packets doesn't fit in a byte.
+	ids = bytes.Repeat([]byte{constants.SENSOR_OI_MODE}, roomba.MaxPackets+1)
+	if _, err := r.QueryList(ids); !errors.Is(err, roomba.ErrInvalidArgument) {
+		t.Errorf("got error %v querying %d packets", err, len(ids))
+	}
+	if _, err := r.Stream(ids); !errors.Is(err, roomba.ErrInvalidArgument) {
+		t.Errorf("got error %v streaming %d packets", err, len(ids))
+	}
+	// Nothing reached the robot.
+	r.Stop()
+	h.Expect(rt.Drive(0, 0))
+}
+
 func TestPauseStream(t *testing.T) {
-	r := rt.MakeTestRoomba()
-	defer rt.ClearTestRoomba()
+	t.Parallel()
+	h := rt.NewHarness(t)
+	r := h.Roomba
 	r.PauseStream()
 	out, _ := r.Stream([]byte{})
 	_, ok := <-out
@@ -81,5 +218,73 @@ func TestPauseStream(t *testing.T) {
 		t.Fatalf("non-empty channel return by empty stream")
 	}
 	expected_input := []byte{148, 0, 150, 0}
-	rt.VerifyWritten(r, expected_input, t)
+	h.VerifyWritten(expected_input)
+}
+
+func TestDriveStraight(t *testing.T) {
+	t.Parallel()
+	h := rt.NewHarness(t)
+	r := h.Roomba
+	r.DriveStraight(100)
+	radius, err := r.Sensors(constants.SENSOR_REQUESTED_RADIUS)
+	if err != nil {
+		t.Fatalf("error querying sensors: %s", err)
+	}
+	h.Expect(rt.Drive(100, constants.DRIVE_STRAIGHT), rt.Sensors(constants.SENSOR_REQUESTED_RADIUS))
+	if radius[0] != 127 || radius[1] != 255 {
+		t.Errorf("requested radius %v, expected straight", radius)
+	}
+}
+
+func TestDriveInvalidRadius(t *testing.T) {
+	t.Parallel()
+	h := rt.NewHarness(t)
+	r := h.Roomba
+	if err := r.Drive(100, 2001); !errors.Is(err, roomba.ErrInvalidArgument) {
+		t.Errorf("expected error for radius out of range")
+	}
+	if err := r.DriveArc(100, 1); !errors.Is(err, roomba.ErrInvalidArgument) {
+		t.Errorf("expected error for special radius passed to DriveArc")
+	}
+}
+
+func TestDriveTwist(t *testing.T) {
+	t.Parallel()
+	h := rt.NewHarness(t)
+	r := h.Roomba
+	// 0.2 m/s along an arc of 0.5 m.
+	r.DriveTwist(0.2, 0.4)
+	radius, err := r.Sensors(constants.SENSOR_REQUESTED_RADIUS)
+	if err != nil {
+		t.Fatalf("error querying sensors: %s", err)
+	}
+	h.VerifyWritten([]byte{137, 0, 200, 1, 244, 142, 40})
+	if radius[0] != 1 || radius[1] != 244 {
+		t.Errorf("requested radius %v, expected 500 mm", radius)
+	}
+}
+
+func TestDriveTwistSaturation(t *testing.T) {
+	t.Parallel()
+	h := rt.NewHarness(t)
+	r := h.Roomba
+	for _, test := range []struct {
+		linear, angular float64
+		want            rt.Command
+	}{
+		{-2, 0, rt.Drive(-500, constants.DRIVE_STRAIGHT)},
+		{0, -10, rt.Drive(500, constants.DRIVE_SPIN_CW)},
+		// The outer wheel at 1149 mm/s is slowed down to 500 mm/s, keeping
+		// the radius of 1 m.
+		{1, 1, rt.Drive(435, 1000)},
+		{0.001, 5, rt.DirectDrive(500, -499)},
+	} {
+		if err := r.DriveTwist(test.linear, test.angular); err != nil {
+			t.Errorf("DriveTwist(%g, %g) failed: %s", test.linear, test.angular, err)
+		}
+		h.Expect(test.want)
+	}
+	if err := r.DriveTwist(math.Inf(1), 0); err == nil {
+		t.Errorf("expected error for infinite twist")
+	}
 }
@@ -195,6 +195,22 @@ const (
 
 	// The radius most recently requested with a Drive command.
 	SENSOR_REQUESTED_RADIUS = 40
+
+	// The right wheel velocity most recently requested with a Drive Direct
+	// command. Range: -500 – 500 mm/s
+	SENSOR_REQUESTED_RIGHT_VELOCITY = 41
+
+	// The left wheel velocity most recently requested with a Drive Direct
+	// command. Range: -500 – 500 mm/s
+	SENSOR_REQUESTED_LEFT_VELOCITY = 42
+
+	// The cumulative number of raw left encoder counts is returned as an
+	// unsigned 16-bit value, high byte first. This number will roll over to 0
+	// after it passes 65535.
+	SENSOR_LEFT_ENCODER_COUNTS = 43
+
+	// Same as above for the right encoder.
+	SENSOR_RIGHT_ENCODER_COUNTS = 44
 	//....
 	SENSOR_ALL = 100
 )
@@ -230,15 +246,19 @@ var SENSOR_PACKET_LENGTH = map[byte]byte{
 	SENSOR_CLIFF_FRONT_RIGHT_SIGNAL: 2,
 	SENSOR_CLIFF_RIGHT_SIGNAL:       2,
 	//unused
-	32: 3,
-	33: 3,
-	SENSOR_CHARGING_SOURCE:    1,
-	SENSOR_OI_MODE:            1,
-	SENSOR_SONG_NUMBER:        1,
-	SENSOR_SONG_PLAYING:       1,
-	SENSOR_NUM_STREAM_PACKETS: 1,
-	SENSOR_REQUESTED_VELOCITY: 2,
-	SENSOR_REQUESTED_RADIUS:   2,
+	32:                              3,
+	33:                              3,
+	SENSOR_CHARGING_SOURCE:          1,
+	SENSOR_OI_MODE:                  1,
+	SENSOR_SONG_NUMBER:              1,
+	SENSOR_SONG_PLAYING:             1,
+	SENSOR_NUM_STREAM_PACKETS:       1,
+	SENSOR_REQUESTED_VELOCITY:       2,
+	SENSOR_REQUESTED_RADIUS:         2,
+	SENSOR_REQUESTED_RIGHT_VELOCITY: 2,
+	SENSOR_REQUESTED_LEFT_VELOCITY:  2,
+	SENSOR_LEFT_ENCODER_COUNTS:      2,
+	SENSOR_RIGHT_ENCODER_COUNTS:     2,
 	//....
 	// Group packets.
 	0:          26,
@@ -255,3 +275,73 @@ var SENSOR_PACKET_LENGTH = map[byte]byte{
 }
 
 const WHEEL_SEPARATION = 298 // mm
+
+// Wheel diameter and encoder resolution, for converting encoder counts to
+// distance: mm = counts * Pi * WHEEL_DIAMETER / ENCODER_COUNTS_PER_REV.
+const (
+	WHEEL_DIAMETER         = 72.0  // mm
+	ENCODER_COUNTS_PER_REV = 508.8 // counts
+)
+
+// IR_* constants define the characters received by the IR sensors
+// (SENSOR_IR_OMNI, SENSOR_IR_LEFT and SENSOR_IR_RIGHT) from Roomba 500
+// accessories. Home Base characters can be combined with bitwise or, e.g.
+// IR_RED_BUOY | IR_FORCE_FIELD = 169.
+const (
+	IR_FORCE_FIELD  = 161
+	IR_VIRTUAL_WALL = 162
+	IR_GREEN_BUOY   = 164
+	IR_RED_BUOY     = 168
+)
+
+// OI_MODE_* constants define the OI modes reported by SENSOR_OI_MODE.
+const (
+	OI_MODE_OFF     = 0
+	OI_MODE_PASSIVE = 1
+	OI_MODE_SAFE    = 2
+	OI_MODE_FULL    = 3
+)
+
+// DRIVE_* constants define the special radius values of the Drive command.
+const (
+	// Drive straight. The OI also accepts -32768 (hex 8000) for this.
+	DRIVE_STRAIGHT = 32767
+
+	// Turn in place clockwise.
+	DRIVE_SPIN_CW = -1
+
+	// Turn in place counter-clockwise.
+	DRIVE_SPIN_CCW = 1
+)
+
+// CHARGING_* constants define the charging states reported by
+// SENSOR_CHARGING.
+const (
+	CHARGING_NOT_CHARGING   = 0
+	CHARGING_RECONDITIONING = 1
+	CHARGING_FULL           = 2
+	CHARGING_TRICKLE        = 3
+	CHARGING_WAITING        = 4
+	CHARGING_FAULT          = 5
+)
+
+// CHARGING_SOURCE_* constants are the bits of SENSOR_CHARGING_SOURCE.
+const (
+	CHARGING_SOURCE_INTERNAL  = 1 << 0
+	CHARGING_SOURCE_HOME_BASE = 1 << 1
+)
+
+// MOTOR_* constants are the bits of the Motors command argument.
+const (
+	MOTOR_SIDE_BRUSH = 1 << 0
+	MOTOR_VACUUM     = 1 << 1
+	MOTOR_MAIN_BRUSH = 1 << 2
+)
+
+// OVERCURRENT_* constants are the bits of SENSOR_WHEEL_OVERCURRENT.
+const (
+	OVERCURRENT_SIDE_BRUSH  = 1 << 0
+	OVERCURRENT_MAIN_BRUSH  = 1 << 2
+	OVERCURRENT_RIGHT_WHEEL = 1 << 3
+	OVERCURRENT_LEFT_WHEEL  = 1 << 4
+)
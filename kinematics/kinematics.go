@@ -0,0 +1,130 @@
+/*
+Package kinematics converts between twist (linear and angular velocity of the
+robot) and the wheel velocities of Roomba's differential drive, and predicts
+the robot's pose from its velocity.
+
+Twists use SI units (m/s, rad/s) while wheel velocities use the mm/s of the
+Open Interface. Angles are counter-clockwise positive, as in the
+SENSOR_ANGLE packet.
+*/
+package kinematics
+
+import (
+	"math"
+	"time"
+
+	"github.com/xa4a/go-roomba/constants"
+)
+
+// MaxWheelVelocity is the largest wheel velocity accepted by DirectDrive.
+const MaxWheelVelocity = 500 // mm/s
+
+// Twist is the velocity of the robot.
+type Twist struct {
+	Linear  float64 // m/s
+	Angular float64 // rad/s
+}
+
+// WheelVelocities are the velocities of the drive wheels.
+type WheelVelocities struct {
+	Right, Left float64 // mm/s
+}
+
+// Wheels converts the twist to wheel velocities (inverse kinematics).
+func (t Twist) Wheels() WheelVelocities {
+	linear := t.Linear * 1000
+	turn := t.Angular * constants.WHEEL_SEPARATION / 2
+	return WheelVelocities{Right: linear + turn, Left: linear - turn}
+}
+
+// Twist converts the wheel velocities to the robot's twist (forward
+// kinematics).
+func (w WheelVelocities) Twist() Twist {
+	return Twist{
+		Linear:  (w.Right + w.Left) / 2 / 1000,
+		Angular: (w.Right - w.Left) / constants.WHEEL_SEPARATION,
+	}
+}
+
+// Saturate scales both wheel velocities down so that neither exceeds max in
+// magnitude. Since the ratio between the wheels is kept, the robot follows the
+// same curve, only slower.
+func (w WheelVelocities) Saturate(max float64) WheelVelocities {
+	peak := math.Max(math.Abs(w.Right), math.Abs(w.Left))
+	if peak <= max {
+		return w
+	}
+	scale := max / peak
+	return WheelVelocities{Right: w.Right * scale, Left: w.Left * scale}
+}
+
+// Command returns the arguments for DirectDrive, saturated to
+// MaxWheelVelocity.
+func (w WheelVelocities) Command() (right, left int16) {
+	w = w.Saturate(MaxWheelVelocity)
+	return int16(math.Round(w.Right)), int16(math.Round(w.Left))
+}
+
+// DirectDriver is the part of the roomba.Roomba API used by Drive.
+type DirectDriver interface {
+	DirectDrive(right, left int16) error
+}
+
+// Drive sends the twist to the robot with a DirectDrive command, slowing it
+// down if necessary to keep the wheels within their limits.
+func Drive(d DirectDriver, t Twist) error {
+	right, left := t.Wheels().Command()
+	return d.DirectDrive(right, left)
+}
+
+// Pose is the position and heading of the robot in a fixed frame.
+type Pose struct {
+	X, Y  float64 // m
+	Theta float64 // rad
+}
+
+// Integrate returns the pose reached by moving with a constant twist for the
+// given duration. The robot moves along an exact arc, so the result doesn't
+// depend on how the duration is split into steps.
+func (p Pose) Integrate(t Twist, d time.Duration) Pose {
+	dt := d.Seconds()
+	dTheta := t.Angular * dt
+	var dx, dy float64
+	if math.Abs(dTheta) < 1e-9 {
+		dx = t.Linear * dt * math.Cos(p.Theta)
+		dy = t.Linear * dt * math.Sin(p.Theta)
+	} else {
+		r := t.Linear / t.Angular
+		dx = r * (math.Sin(p.Theta+dTheta) - math.Sin(p.Theta))
+		dy = -r * (math.Cos(p.Theta+dTheta) - math.Cos(p.Theta))
+	}
+	return Pose{X: p.X + dx, Y: p.Y + dy, Theta: NormalizeAngle(p.Theta + dTheta)}
+}
+
+// Advance returns the pose reached after travelling distance (m) while
+// turning by angle (rad), as reported by the SENSOR_DISTANCE and SENSOR_ANGLE
+// packets, assuming both happened at a constant rate.
+func (p Pose) Advance(distance, angle float64) Pose {
+	return p.Integrate(Twist{Linear: distance, Angular: angle}, time.Second)
+}
+
+// Predict returns the poses of the robot moving with a constant twist,
+// sampled every step until the horizon. The first element is p itself.
+func Predict(p Pose, t Twist, horizon, step time.Duration) []Pose {
+	poses := []Pose{p}
+	for elapsed := step; elapsed <= horizon; elapsed += step {
+		poses = append(poses, p.Integrate(t, elapsed))
+	}
+	return poses
+}
+
+// NormalizeAngle wraps the angle (rad) into the range (-Pi, Pi].
+func NormalizeAngle(a float64) float64 {
+	a = math.Mod(a, 2*math.Pi)
+	if a > math.Pi {
+		a -= 2 * math.Pi
+	} else if a <= -math.Pi {
+		a += 2 * math.Pi
+	}
+	return a
+}
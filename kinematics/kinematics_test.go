@@ -0,0 +1,82 @@
+package kinematics_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/xa4a/go-roomba/kinematics"
+)
+
+const epsilon = 1e-9
+
+func TestWheelsRoundTrip(t *testing.T) {
+	twist := kinematics.Twist{Linear: 0.2, Angular: 1}
+	w := twist.Wheels()
+	if math.Abs(w.Right-349) > epsilon || math.Abs(w.Left-51) > epsilon {
+		t.Errorf("unexpected wheel velocities: %+v", w)
+	}
+	back := w.Twist()
+	if math.Abs(back.Linear-twist.Linear) > epsilon ||
+		math.Abs(back.Angular-twist.Angular) > epsilon {
+		t.Errorf("round trip changed twist: %+v -> %+v", twist, back)
+	}
+}
+
+func TestSaturatePreservesCurvature(t *testing.T) {
+	twist := kinematics.Twist{Linear: 0.5, Angular: 2}
+	w := twist.Wheels().Saturate(kinematics.MaxWheelVelocity)
+	if math.Abs(w.Right) > kinematics.MaxWheelVelocity+epsilon ||
+		math.Abs(w.Left) > kinematics.MaxWheelVelocity+epsilon {
+		t.Errorf("wheels not saturated: %+v", w)
+	}
+	got := w.Twist()
+	if math.Abs(got.Linear/got.Angular-twist.Linear/twist.Angular) > epsilon {
+		t.Errorf("curvature changed: %+v -> %+v", twist, got)
+	}
+}
+
+type recordingDriver struct {
+	right, left int16
+}
+
+func (d *recordingDriver) DirectDrive(right, left int16) error {
+	d.right, d.left = right, left
+	return nil
+}
+
+func TestDrive(t *testing.T) {
+	d := &recordingDriver{}
+	kinematics.Drive(d, kinematics.Twist{Linear: 1})
+	if d.right != 500 || d.left != 500 {
+		t.Errorf("expected saturated straight drive, got %d, %d", d.right, d.left)
+	}
+}
+
+func TestIntegrateFullCircle(t *testing.T) {
+	twist := kinematics.Twist{Linear: 0.1, Angular: 0.5}
+	period := time.Duration(2 * math.Pi / twist.Angular * float64(time.Second))
+	start := kinematics.Pose{X: 1, Y: 2, Theta: 0.3}
+	end := start.Integrate(twist, period)
+	if math.Abs(end.X-start.X) > 1e-6 || math.Abs(end.Y-start.Y) > 1e-6 ||
+		math.Abs(end.Theta-start.Theta) > 1e-6 {
+		t.Errorf("full circle didn't return to start: %+v -> %+v", start, end)
+	}
+	half := start.Integrate(twist, period/2)
+	// Diameter of the circle is 2 * 0.1 / 0.5 = 0.4 m.
+	dist := math.Hypot(half.X-start.X, half.Y-start.Y)
+	if math.Abs(dist-0.4) > 1e-6 {
+		t.Errorf("half circle travelled %f m, expected 0.4", dist)
+	}
+}
+
+func TestPredict(t *testing.T) {
+	poses := kinematics.Predict(kinematics.Pose{}, kinematics.Twist{Linear: 0.2},
+		time.Second, 250*time.Millisecond)
+	if len(poses) != 5 {
+		t.Fatalf("expected 5 poses, got %d", len(poses))
+	}
+	if math.Abs(poses[4].X-0.2) > epsilon {
+		t.Errorf("expected to travel 0.2 m, got %+v", poses[4])
+	}
+}
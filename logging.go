@@ -0,0 +1,29 @@
+package roomba
+
+import (
+	"log/slog"
+)
+
+// Keys of the structured fields logged by Roomba and the simulator.
+const (
+	LogOpcode    = "opcode"
+	LogPacketId  = "packet_id"
+	LogDirection = "direction"
+)
+
+// Values of the LogDirection field.
+const (
+	HostToRobot = "host_to_robot"
+	RobotToHost = "robot_to_host"
+)
+
+var discardLogger = slog.New(slog.DiscardHandler)
+
+// logger returns the Logger, or a logger discarding everything if it isn't
+// set.
+func (this *Roomba) logger() *slog.Logger {
+	if this.Logger == nil {
+		return discardLogger
+	}
+	return this.Logger
+}
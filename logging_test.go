@@ -0,0 +1,129 @@
+package roomba_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"github.com/xa4a/go-roomba"
+	"github.com/xa4a/go-roomba/constants"
+	rt "github.com/xa4a/go-roomba/testing"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// records decodes the JSON log records written so far.
+func (b *syncBuffer) records(t *testing.T) []map[string]interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var records []map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(b.buf.Bytes()))
+	for dec.More() {
+		var r map[string]interface{}
+		if err := dec.Decode(&r); err != nil {
+			t.Fatalf("invalid log record: %s", err)
+		}
+		records = append(records, r)
+	}
+	return records
+}
+
+// findRecord returns the first record with the given message and fields.
+func findRecord(records []map[string]interface{}, msg string, fields map[string]interface{}) map[string]interface{} {
+Records:
+	for _, r := range records {
+		if r["msg"] != msg {
+			continue
+		}
+		for k, v := range fields {
+			if r[k] != v {
+				continue Records
+			}
+		}
+		return r
+	}
+	return nil
+}
+
+func jsonLogger(w *syncBuffer) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: slog.LevelDebug}))
+}
+
+func TestLogger(t *testing.T) {
+	t.Parallel()
+	h := rt.NewHarness(t)
+	clientLog, simLog := &syncBuffer{}, &syncBuffer{}
+	h.Roomba.Logger = jsonLogger(clientLog)
+	h.Sim.SetLogger(jsonLogger(simLog))
+
+	h.Roomba.Drive(-200, 500)
+	if _, err := h.Roomba.Sensors(constants.SENSOR_OI_MODE); err != nil {
+		t.Fatalf("failed reading sensors: %s", err)
+	}
+
+	records := clientLog.records(t)
+	if r := findRecord(records, "write", map[string]interface{}{
+		roomba.LogOpcode:    137.0,
+		roomba.LogDirection: roomba.HostToRobot,
+		"command":           "Drive",
+	}); r == nil || r["level"] != "DEBUG" {
+		t.Errorf("Drive not logged by the client: %v", records)
+	}
+	if findRecord(records, "read", map[string]interface{}{roomba.LogDirection: roomba.RobotToHost}) == nil {
+		t.Errorf("sensor response not logged by the client: %v", records)
+	}
+
+	records = simLog.records(t)
+	if findRecord(records, "Drive", map[string]interface{}{
+		roomba.LogOpcode: 137.0,
+		"velocity":       -200.0,
+		"radius":         500.0,
+	}) == nil {
+		t.Errorf("Drive not logged by the simulator: %v", records)
+	}
+	if findRecord(records, "sensor value", map[string]interface{}{
+		roomba.LogPacketId: float64(constants.SENSOR_OI_MODE),
+	}) == nil {
+		t.Errorf("sensor request not logged by the simulator: %v", records)
+	}
+}
+
+// Not parallel, as it captures the output of the global logger.
+func TestSilentByDefault(t *testing.T) {
+	buf := &syncBuffer{}
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(buf)
+
+	h := rt.NewHarness(t)
+	h.Roomba.Start()
+	h.Roomba.Safe()
+	h.Roomba.Drive(-200, 500)
+	h.Roomba.QueryList([]byte{constants.SENSOR_DISTANCE, constants.SENSOR_ANGLE})
+	frames, err := h.Roomba.Stream([]byte{constants.SENSOR_DISTANCE})
+	if err != nil {
+		t.Fatalf("failed starting stream: %s", err)
+	}
+	<-frames
+	h.Roomba.PauseStream()
+	for range frames {
+	}
+
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+	if buf.buf.Len() > 0 {
+		t.Errorf("logged by default:\n%s", buf.buf.String())
+	}
+}
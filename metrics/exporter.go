@@ -0,0 +1,317 @@
+/*
+Package metrics exports the sensor data streamed by Roomba.Stream as
+Prometheus metrics.
+
+The Exporter keeps the last frame of a stream of its Packets and serves it on
+scrapes, which never touch the serial link:
+
+	e := metrics.MakeExporter(metrics.Config{
+		ChecksumErrors: r.ChecksumErrors,
+		FramingErrors:  r.FramingErrors,
+	})
+	go e.RunStream(context.Background(), r)
+	http.Handle("/metrics", e.Handler())
+
+Gauges, such as roomba_battery_charge_mah, are only exported while the last
+frame is recent, so that a stalled stream shows as missing data rather than
+as a robot frozen in its last state; roomba_up tells which. Counters, such as
+roomba_bumps_total, count the events seen in the stream: a bumper held down
+for many frames is a single bump.
+*/
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/xa4a/go-roomba/clock"
+	"github.com/xa4a/go-roomba/constants"
+	"github.com/xa4a/go-roomba/oi"
+)
+
+// Packets are the packets whose stream the Exporter expects, in this order.
+var Packets = []byte{
+	constants.SENSOR_BUMP_WHEELS_DROPS,
+	constants.SENSOR_WHEEL_OVERCURRENT,
+	constants.SENSOR_VOLTAGE,
+	constants.SENSOR_CURRENT,
+	constants.SENSOR_TEMPERATURE,
+	constants.SENSOR_BATTERY_CHARGE,
+	constants.SENSOR_BATTERY_CAPACITY,
+}
+
+// RestartDelay is the time RunStream waits before restarting a stream which
+// ended.
+const RestartDelay = time.Second
+
+// DefaultMaxAge is the age of the last frame after which gauges aren't
+// exported, if Config.MaxAge is zero.
+const DefaultMaxAge = 2 * time.Second
+
+// bumpers and motors label the bits of the bumps and wheel overcurrent
+// packets.
+var (
+	bumpers = []label{{1 << 0, "right"}, {1 << 1, "left"}}
+	motors  = []label{
+		{constants.OVERCURRENT_SIDE_BRUSH, "side_brush"},
+		{constants.OVERCURRENT_MAIN_BRUSH, "main_brush"},
+		{constants.OVERCURRENT_RIGHT_WHEEL, "right_wheel"},
+		{constants.OVERCURRENT_LEFT_WHEEL, "left_wheel"},
+	}
+)
+
+type label struct {
+	mask  int
+	value string
+}
+
+// gauges maps packets to the gauges exporting their value.
+var gauges = []struct {
+	packetId byte
+	desc     *prometheus.Desc
+}{
+	{constants.SENSOR_BATTERY_CHARGE, desc("battery_charge_mah", "Charge of the battery in mAh.")},
+	{constants.SENSOR_BATTERY_CAPACITY, desc("battery_capacity_mah", "Estimated capacity of the battery in mAh.")},
+	{constants.SENSOR_TEMPERATURE, desc("battery_temperature_celsius", "Temperature of the battery in degrees Celsius.")},
+	{constants.SENSOR_VOLTAGE, desc("voltage_mv", "Voltage of the battery in mV.")},
+	{constants.SENSOR_CURRENT, desc("current_ma", "Current flowing into the battery in mA, negative while discharging.")},
+}
+
+var (
+	upDesc        = desc("up", "Whether the last stream frame was received within the maximum age.")
+	lastFrameDesc = desc("last_frame_timestamp_seconds",
+		"Time the last stream frame was received, in seconds since the epoch.")
+	framesDesc         = desc("stream_frames_total", "Stream frames received.")
+	checksumErrorsDesc = desc("stream_checksum_errors_total", "Stream frames dropped for a wrong checksum.")
+	framingErrorsDesc  = desc("stream_framing_errors_total", "Losses of sync of the stream reader, which skipped data.")
+	restartsDesc       = desc("stream_restarts_total", "Restarts of the stream after it ended.")
+	bumpsDesc          = desc("bumps_total", "Bumper presses.", "bumper")
+	overcurrentDesc    = desc("wheel_overcurrent_total", "Overcurrents of the wheel and brush motors.", "motor")
+)
+
+func desc(name, help string, labels ...string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName("roomba", "", name), help, labels, nil)
+}
+
+// Config configures an Exporter.
+type Config struct {
+	// Gauges aren't exported when the last frame is older than MaxAge,
+	// DefaultMaxAge if zero.
+	MaxAge time.Duration
+
+	// ChecksumErrors returns the number of frames dropped by the stream
+	// reader, usually Roomba.ChecksumErrors. The counter isn't exported if
+	// it is nil.
+	ChecksumErrors func() uint64
+
+	// FramingErrors returns the number of times the stream reader lost
+	// sync, usually Roomba.FramingErrors. The counter isn't exported if it
+	// is nil.
+	FramingErrors func() uint64
+
+	// Clock giving the time of frames, the real clock if nil.
+	Clock clock.Clock
+}
+
+// Exporter is a Prometheus collector of the frames of a stream of Packets.
+// Should be constructed with MakeExporter() function.
+type Exporter struct {
+	config Config
+
+	mu          sync.Mutex
+	last        map[byte]oi.Packet // Packets of the last frame.
+	received    time.Time          // Time of the last frame.
+	frames      uint64
+	restarts    uint64
+	bumps       []uint64 // Rising edges of each of bumpers.
+	overcurrent []uint64 // Rising edges of each of motors.
+}
+
+// MakeExporter creates an Exporter, which exports no gauges until the first
+// frame.
+func MakeExporter(config Config) *Exporter {
+	if config.MaxAge == 0 {
+		config.MaxAge = DefaultMaxAge
+	}
+	config.Clock = clock.OrReal(config.Clock)
+	return &Exporter{
+		config:      config,
+		bumps:       make([]uint64, len(bumpers)),
+		overcurrent: make([]uint64, len(motors)),
+	}
+}
+
+// Run updates the metrics with the frames received from frames, as returned
+// by Roomba.Stream, until the channel is closed.
+func (e *Exporter) Run(frames <-chan [][]byte) error {
+	for frame := range frames {
+		if err := e.Update(frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Streamer is the part of the roomba.Roomba API used by RunStream.
+type Streamer interface {
+	Stream(packet_ids []byte) (<-chan [][]byte, error)
+	PauseStream()
+}
+
+// RunStream streams Packets from r and updates the metrics with the frames
+// until ctx is done, returning its error. Whenever the stream ends or fails to
+// start, as when the link to the robot drops, it is restarted after
+// RestartDelay. It also returns the error of Update for a frame of other
+// packets.
+func (e *Exporter) RunStream(ctx context.Context, r Streamer) error {
+	for {
+		if frames, err := r.Stream(Packets); err == nil {
+			if err := e.runUntil(ctx, r, frames); err != nil {
+				return err
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-e.config.Clock.After(RestartDelay):
+		}
+		e.mu.Lock()
+		e.restarts++
+		e.mu.Unlock()
+	}
+}
+
+// runUntil is like Run, and pauses the stream when ctx is done, returning
+// its error.
+func (e *Exporter) runUntil(ctx context.Context, r Streamer, frames <-chan [][]byte) error {
+	for {
+		select {
+		case <-ctx.Done():
+			r.PauseStream()
+			// Frames are sent until the pause is read.
+			for range frames {
+			}
+			return ctx.Err()
+		case frame, ok := <-frames:
+			if !ok {
+				return nil
+			}
+			if err := e.Update(frame); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Update updates the metrics with a frame received now.
+func (e *Exporter) Update(frame [][]byte) error {
+	if len(frame) != len(Packets) {
+		return fmt.Errorf("frame of %d packets, expected %d", len(frame), len(Packets))
+	}
+	packets := make(map[byte]oi.Packet, len(Packets))
+	for i, id := range Packets {
+		if len(frame[i]) != int(constants.SENSOR_PACKET_LENGTH[id]) {
+			return fmt.Errorf("packet %d of %d bytes", id, len(frame[i]))
+		}
+		packets[id] = oi.Packet{ID: id, Data: frame[i]}
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	countEdges(e.bumps, bumpers, e.last, packets, constants.SENSOR_BUMP_WHEELS_DROPS)
+	countEdges(e.overcurrent, motors, e.last, packets, constants.SENSOR_WHEEL_OVERCURRENT)
+	e.last = packets
+	e.received = e.config.Clock.Now()
+	e.frames++
+	return nil
+}
+
+// countEdges increments the counts of the labeled bits of a packet which are
+// set in next but weren't in last. Bits set in the first frame aren't counted,
+// as the event may be long past.
+func countEdges(counts []uint64, labels []label, last, next map[byte]oi.Packet, packetId byte) {
+	if last == nil {
+		return
+	}
+	rising := next[packetId].Value() &^ last[packetId].Value()
+	for i, l := range labels {
+		if rising&l.mask != 0 {
+			counts[i]++
+		}
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
+	for _, g := range gauges {
+		ch <- g.desc
+	}
+	ch <- upDesc
+	ch <- lastFrameDesc
+	ch <- framesDesc
+	ch <- restartsDesc
+	ch <- bumpsDesc
+	ch <- overcurrentDesc
+	if e.config.ChecksumErrors != nil {
+		ch <- checksumErrorsDesc
+	}
+	if e.config.FramingErrors != nil {
+		ch <- framingErrorsDesc
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	up := e.last != nil && e.config.Clock.Now().Sub(e.received) <= e.config.MaxAge
+	if up {
+		for _, g := range gauges {
+			ch <- prometheus.MustNewConstMetric(g.desc, prometheus.GaugeValue, float64(e.last[g.packetId].Value()))
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, boolValue(up))
+	if e.last != nil {
+		ch <- prometheus.MustNewConstMetric(lastFrameDesc, prometheus.GaugeValue,
+			float64(e.received.UnixNano())/1e9)
+	}
+	ch <- prometheus.MustNewConstMetric(framesDesc, prometheus.CounterValue, float64(e.frames))
+	ch <- prometheus.MustNewConstMetric(restartsDesc, prometheus.CounterValue, float64(e.restarts))
+	// Counters are exported from the start, so that the first event is an
+	// increase.
+	for i, bumper := range bumpers {
+		ch <- prometheus.MustNewConstMetric(bumpsDesc, prometheus.CounterValue, float64(e.bumps[i]), bumper.value)
+	}
+	for i, motor := range motors {
+		ch <- prometheus.MustNewConstMetric(overcurrentDesc, prometheus.CounterValue,
+			float64(e.overcurrent[i]), motor.value)
+	}
+	if e.config.ChecksumErrors != nil {
+		ch <- prometheus.MustNewConstMetric(checksumErrorsDesc, prometheus.CounterValue,
+			float64(e.config.ChecksumErrors()))
+	}
+	if e.config.FramingErrors != nil {
+		ch <- prometheus.MustNewConstMetric(framingErrorsDesc, prometheus.CounterValue,
+			float64(e.config.FramingErrors()))
+	}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Handler returns a handler serving the metrics of the Exporter alone in the
+// Prometheus text format.
+func (e *Exporter) Handler() http.Handler {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(e)
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,199 @@
+package metrics_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xa4a/go-roomba"
+	"github.com/xa4a/go-roomba/clock"
+	"github.com/xa4a/go-roomba/constants"
+	"github.com/xa4a/go-roomba/metrics"
+	"github.com/xa4a/go-roomba/sim"
+)
+
+// scrape returns the samples served by the handler, by name with labels.
+func scrape(t *testing.T, e *metrics.Exporter) map[string]string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	e.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if w.Code != 200 {
+		t.Fatalf("scrape failed with %d: %s", w.Code, w.Body)
+	}
+	samples := map[string]string{}
+	s := bufio.NewScanner(w.Body)
+	for s.Scan() {
+		line := s.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndex(line, " ")
+		samples[line[:i]] = line[i+1:]
+	}
+	return samples
+}
+
+// frame returns a frame of metrics.Packets.
+func frame(bumps, overcurrent byte, current int16, temperature int8, charge uint16) [][]byte {
+	return [][]byte{
+		{bumps},
+		{overcurrent},
+		{0x3a, 0x98}, // 15000 mV.
+		{byte(uint16(current) >> 8), byte(current)},
+		{byte(temperature)},
+		{byte(charge >> 8), byte(charge)},
+		{0x0b, 0xb8}, // 3000 mAh.
+	}
+}
+
+func TestExporter(t *testing.T) {
+	clk := clock.MakeVirtual(time.Unix(1000, 0))
+	var checksumErrors uint64 = 3
+	e := metrics.MakeExporter(metrics.Config{
+		Clock:          clk,
+		ChecksumErrors: func() uint64 { return checksumErrors },
+	})
+
+	samples := scrape(t, e)
+	if samples["roomba_up"] != "0" || samples["roomba_battery_charge_mah"] != "" {
+		t.Errorf("gauges exported before the first frame: %v", samples)
+	}
+	if samples[`roomba_bumps_total{bumper="left"}`] != "0" || samples["roomba_stream_checksum_errors_total"] != "3" {
+		t.Errorf("counters not exported before the first frame: %v", samples)
+	}
+
+	// The right bumper is held from the start. The left one is held for
+	// two frames, then the right one is pressed again, and a wheel
+	// overcurrents.
+	for _, f := range [][][]byte{
+		frame(1, 0, -1200, 31, 2400),
+		frame(0, 0, -1200, 31, 2400),
+		frame(2, 0, -1200, 31, 2400),
+		frame(2, 0, -1100, 31, 2399),
+		frame(1, constants.OVERCURRENT_RIGHT_WHEEL, -1500, -2, 2398),
+	} {
+		if err := e.Update(f); err != nil {
+			t.Fatalf("failed updating: %s", err)
+		}
+	}
+	samples = scrape(t, e)
+	for name, want := range map[string]string{
+		"roomba_up":                                           "1",
+		"roomba_battery_charge_mah":                           "2398",
+		"roomba_battery_capacity_mah":                         "3000",
+		"roomba_battery_temperature_celsius":                  "-2",
+		"roomba_current_ma":                                   "-1500",
+		"roomba_voltage_mv":                                   "15000",
+		"roomba_last_frame_timestamp_seconds":                 "1000",
+		"roomba_stream_frames_total":                          "5",
+		`roomba_bumps_total{bumper="left"}`:                   "1",
+		`roomba_bumps_total{bumper="right"}`:                  "1",
+		`roomba_wheel_overcurrent_total{motor="right_wheel"}`: "1",
+		`roomba_wheel_overcurrent_total{motor="left_wheel"}`:  "0",
+	} {
+		if got := samples[name]; got != want {
+			t.Errorf("got %s %q, want %q", name, got, want)
+		}
+	}
+
+	// A stalled stream exports no gauges.
+	clk.Advance(metrics.DefaultMaxAge + time.Millisecond)
+	samples = scrape(t, e)
+	if samples["roomba_up"] != "0" || samples["roomba_current_ma"] != "" {
+		t.Errorf("gauges exported for a stale frame: %v", samples)
+	}
+	if samples["roomba_stream_frames_total"] != "5" {
+		t.Errorf("counters not exported for a stale frame: %v", samples)
+	}
+
+	if err := e.Update([][]byte{{0}}); err == nil {
+		t.Errorf("frame of other packets accepted")
+	}
+}
+
+func TestExportStream(t *testing.T) {
+	roombaSim, socket := sim.MakeRoombaSim()
+	defer roombaSim.Stop()
+	r := &roomba.Roomba{S: socket, StreamPaused: make(chan bool, 1)}
+	r.Start()
+	want, err := r.Sensors(constants.SENSOR_BATTERY_CHARGE)
+	if err != nil {
+		t.Fatalf("error querying battery charge: %s", err)
+	}
+	stream, err := r.Stream(metrics.Packets)
+	if err != nil {
+		t.Fatalf("failed starting stream: %s", err)
+	}
+	e := metrics.MakeExporter(metrics.Config{ChecksumErrors: r.ChecksumErrors})
+	done := make(chan error)
+	go func() { done <- e.Run(stream) }()
+
+	deadline := time.Now().Add(time.Second)
+	for scrape(t, e)["roomba_up"] != "1" {
+		if time.Now().After(deadline) {
+			t.Fatalf("no frame exported")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	samples := scrape(t, e)
+	if got := samples["roomba_battery_charge_mah"]; got != strconv.Itoa(int(binary.BigEndian.Uint16(want))) {
+		t.Errorf("got battery charge %s, want %v", got, want)
+	}
+	r.PauseStream()
+	if err := <-done; err != nil {
+		t.Errorf("run failed: %s", err)
+	}
+}
+
+// endingStreamer starts streams of a single frame.
+type endingStreamer struct{}
+
+func (endingStreamer) Stream(packet_ids []byte) (<-chan [][]byte, error) {
+	frames := make(chan [][]byte, 1)
+	frames <- frame(0, 0, -1200, 31, 2400)
+	close(frames)
+	return frames, nil
+}
+
+func (endingStreamer) PauseStream() {}
+
+func TestRestartStream(t *testing.T) {
+	clk := clock.MakeVirtual(time.Unix(1000, 0))
+	e := metrics.MakeExporter(metrics.Config{
+		Clock:         clk,
+		FramingErrors: func() uint64 { return 2 },
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- e.RunStream(ctx, endingStreamer{}) }()
+
+	// Waiting to restart the first stream.
+	<-clk.Blocked(1)
+	samples := scrape(t, e)
+	if samples["roomba_stream_frames_total"] != "1" || samples["roomba_stream_restarts_total"] != "0" {
+		t.Errorf("unexpected samples after the first stream: %v", samples)
+	}
+	clk.Advance(metrics.RestartDelay)
+	<-clk.Blocked(1)
+	samples = scrape(t, e)
+	for name, want := range map[string]string{
+		"roomba_stream_frames_total":          "2",
+		"roomba_stream_restarts_total":        "1",
+		"roomba_stream_framing_errors_total":  "2",
+		"roomba_stream_checksum_errors_total": "",
+	} {
+		if got := samples[name]; got != want {
+			t.Errorf("got %s %q, want %q", name, got, want)
+		}
+	}
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("got error %v, want context.Canceled", err)
+	}
+}
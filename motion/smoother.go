@@ -0,0 +1,251 @@
+/*
+Package motion implements a velocity smoother for Roomba drive commands.
+
+A Smoother sits between the caller and a Roomba. Drive and DirectDrive
+requests only set a target; a background loop ramps the actual wheel
+velocities towards that target at a fixed rate, never exceeding the
+configured acceleration and jerk limits. EmergencyStop bypasses the ramp and
+halts the wheels immediately.
+*/
+package motion
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/xa4a/go-roomba"
+	"github.com/xa4a/go-roomba/clock"
+	"github.com/xa4a/go-roomba/constants"
+	"github.com/xa4a/go-roomba/kinematics"
+)
+
+// Driver is the part of the roomba.Roomba API used by the Smoother.
+type Driver interface {
+	DirectDrive(right, left int16) error
+}
+
+// Limits configures the maximum rate of change of the robot's velocity. Zero
+// acceleration means the corresponding velocity changes instantly, zero jerk
+// means acceleration changes instantly.
+type Limits struct {
+	LinearAccel  float64 // mm/s^2
+	LinearJerk   float64 // mm/s^3
+	AngularAccel float64 // rad/s^2
+	AngularJerk  float64 // rad/s^3
+}
+
+// DefaultLimits are conservative limits that keep a Roomba from wheelieing
+// when starting at full speed.
+var DefaultLimits = Limits{
+	LinearAccel:  500,
+	LinearJerk:   2000,
+	AngularAccel: 4,
+	AngularJerk:  16,
+}
+
+// DefaultPeriod matches the rate at which Roomba updates its sensors.
+const DefaultPeriod = 15 * time.Millisecond
+
+// axis tracks velocity and acceleration along one degree of freedom.
+type axis struct {
+	vel, acc float64
+}
+
+// step advances the axis by dt seconds towards the target velocity.
+func (a *axis) step(target, dt, maxAcc, maxJerk float64) {
+	diff := target - a.vel
+	if maxAcc <= 0 {
+		a.vel, a.acc = target, 0
+		return
+	}
+	want := clamp(diff/dt, -maxAcc, maxAcc)
+	if maxJerk > 0 {
+		// Ease off early so that acceleration reaches zero at the same
+		// time as the velocity reaches the target.
+		brake := math.Sqrt(2 * maxJerk * math.Abs(diff))
+		want = clamp(want, -brake, brake)
+		want = clamp(want, a.acc-maxJerk*dt, a.acc+maxJerk*dt)
+	}
+	a.acc = want
+	a.vel += a.acc * dt
+	if (target-a.vel)*diff <= 0 {
+		// Reached or overshot the target.
+		a.vel, a.acc = target, 0
+	}
+}
+
+func (a *axis) settled(target float64) bool {
+	return a.vel == target && a.acc == 0
+}
+
+func clamp(v, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, v))
+}
+
+// Smoother ramps drive commands sent to a Driver. Should be constructed with
+// MakeSmoother() function.
+type Smoother struct {
+	Limits Limits
+	Period time.Duration
+
+	d     Driver
+	clock clock.Clock
+
+	mu                   sync.Mutex
+	linTarget, angTarget float64 // mm/s, rad/s
+	lin, ang             axis
+	lastRight, lastLeft  int16
+	sentOnce             bool
+	err                  error // Error which stopped the control loop.
+	quit                 chan struct{}
+	done                 chan struct{}
+}
+
+// MakeSmoother creates a Smoother for the given driver and starts its
+// control loop. Close() must be called to stop the loop. The loop also stops
+// when the driver fails, see Err().
+func MakeSmoother(d Driver, limits Limits, period time.Duration) *Smoother {
+	return MakeSmootherClock(d, limits, period, clock.Real)
+}
+
+// MakeSmootherClock is like MakeSmoother, with the control loop running on
+// the given clock.
+func MakeSmootherClock(d Driver, limits Limits, period time.Duration, c clock.Clock) *Smoother {
+	s := &Smoother{
+		Limits: limits,
+		Period: period,
+		d:      d,
+		clock:  c,
+		quit:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go s.loop()
+	return s
+}
+
+func (s *Smoother) loop() {
+	defer close(s.done)
+	ticker := s.clock.NewTicker(s.Period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.quit:
+			return
+		case <-ticker.C():
+			if err := s.tick(s.Period.Seconds()); err != nil {
+				s.mu.Lock()
+				s.err = err
+				s.mu.Unlock()
+				return
+			}
+		}
+	}
+}
+
+// tick advances the ramp by dt seconds and sends the resulting command.
+func (s *Smoother) tick(dt float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sentOnce && s.lin.settled(s.linTarget) && s.ang.settled(s.angTarget) {
+		return nil
+	}
+	s.lin.step(s.linTarget, dt, s.Limits.LinearAccel, s.Limits.LinearJerk)
+	s.ang.step(s.angTarget, dt, s.Limits.AngularAccel, s.Limits.AngularJerk)
+	right, left := wheels(s.lin.vel, s.ang.vel)
+	if s.sentOnce && right == s.lastRight && left == s.lastLeft {
+		return nil
+	}
+	return s.send(right, left)
+}
+
+// send must be called with s.mu held.
+func (s *Smoother) send(right, left int16) error {
+	s.lastRight, s.lastLeft, s.sentOnce = right, left, true
+	return s.d.DirectDrive(right, left)
+}
+
+// wheels converts linear (mm/s) and angular (rad/s) velocities to wheel
+// velocities, saturated to the range accepted by DirectDrive.
+func wheels(lin, ang float64) (right, left int16) {
+	return kinematics.Twist{Linear: lin / 1000, Angular: ang}.Wheels().Command()
+}
+
+// setTarget returns the error which stopped the control loop, as the target
+// would never be reached.
+func (s *Smoother) setTarget(lin, ang float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.linTarget, s.angTarget = lin, ang
+	return s.err
+}
+
+// Drive sets the target velocity (mm/s) and turning radius (mm) using the
+// same conventions as roomba.Roomba.Drive, including the special radius
+// values for driving straight and turning in place.
+func (s *Smoother) Drive(velocity, radius int16) error {
+	if !(-500 <= velocity && velocity <= 500) {
+		return fmt.Errorf("%w: velocity %d", roomba.ErrInvalidArgument, velocity)
+	}
+	v := float64(velocity)
+	switch radius {
+	case constants.DRIVE_STRAIGHT, -32768:
+		return s.setTarget(v, 0)
+	case constants.DRIVE_SPIN_CCW:
+		return s.setTarget(0, v/(constants.WHEEL_SEPARATION/2))
+	case constants.DRIVE_SPIN_CW:
+		return s.setTarget(0, -v/(constants.WHEEL_SEPARATION/2))
+	}
+	if err := roomba.CheckArcRadius(radius); err != nil {
+		return err
+	}
+	return s.setTarget(v, v/float64(radius))
+}
+
+// DirectDrive sets the target velocities (mm/s) of the right and left wheels.
+func (s *Smoother) DirectDrive(right, left int16) error {
+	if !(-500 <= right && right <= 500) ||
+		!(-500 <= left && left <= 500) {
+		return fmt.Errorf("%w: wheel velocities %d and %d", roomba.ErrInvalidArgument, right, left)
+	}
+	r, l := float64(right), float64(left)
+	return s.setTarget((r+l)/2, (r-l)/constants.WHEEL_SEPARATION)
+}
+
+// Stop brings the robot to a halt, respecting the deceleration limits.
+func (s *Smoother) Stop() error {
+	return s.setTarget(0, 0)
+}
+
+// EmergencyStop halts the wheels immediately, ignoring the limits. It does
+// not wait for the next tick of the control loop.
+func (s *Smoother) EmergencyStop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.linTarget, s.angTarget = 0, 0
+	s.lin, s.ang = axis{}, axis{}
+	return s.send(0, 0)
+}
+
+// Err returns the error of the driver which stopped the control loop, if any.
+// Drive, DirectDrive and Stop return it too once the loop stopped;
+// EmergencyStop still sends its command.
+func (s *Smoother) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Done returns a channel which is closed when the control loop stops, after
+// Close() or a failure of the driver.
+func (s *Smoother) Done() <-chan struct{} {
+	return s.done
+}
+
+// Close stops the control loop. The robot keeps its last commanded velocity;
+// call Stop() or EmergencyStop() before closing to halt it.
+func (s *Smoother) Close() {
+	close(s.quit)
+	<-s.done
+}
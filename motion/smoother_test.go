@@ -0,0 +1,132 @@
+package motion
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/xa4a/go-roomba"
+)
+
+type recordingDriver struct {
+	commands [][2]int16
+}
+
+func (d *recordingDriver) DirectDrive(right, left int16) error {
+	d.commands = append(d.commands, [2]int16{right, left})
+	return nil
+}
+
+func makeTestSmoother(limits Limits) (*Smoother, *recordingDriver) {
+	d := &recordingDriver{}
+	return &Smoother{Limits: limits, Period: DefaultPeriod, d: d}, d
+}
+
+func TestRampRespectsLimits(t *testing.T) {
+	limits := Limits{LinearAccel: 500, LinearJerk: 2000}
+	s, d := makeTestSmoother(limits)
+	dt := DefaultPeriod.Seconds()
+	if err := s.Drive(500, 32767); err != nil {
+		t.Fatalf("drive failed: %s", err)
+	}
+	for i := 0; i < 200; i++ {
+		s.tick(dt)
+	}
+	if len(d.commands) < 2 {
+		t.Fatalf("expected intermediate commands, got %v", d.commands)
+	}
+	prevVel, prevAcc := 0.0, 0.0
+	for i, c := range d.commands {
+		if c[0] != c[1] {
+			t.Errorf("command %d turns while driving straight: %v", i, c)
+		}
+		vel := float64(c[0])
+		acc := (vel - prevVel) / dt
+		// Allow for rounding to whole mm/s.
+		if math.Abs(acc) > limits.LinearAccel+1/dt {
+			t.Errorf("command %d exceeds acceleration limit: %f", i, acc)
+		}
+		if math.Abs(acc-prevAcc) > limits.LinearJerk*dt+2/dt {
+			t.Errorf("command %d exceeds jerk limit: %f -> %f", i, prevAcc, acc)
+		}
+		prevVel, prevAcc = vel, acc
+	}
+	last := d.commands[len(d.commands)-1]
+	if last != [2]int16{500, 500} {
+		t.Errorf("didn't reach target velocity, last command %v", last)
+	}
+}
+
+func TestSpinInPlace(t *testing.T) {
+	s, d := makeTestSmoother(Limits{})
+	s.Drive(200, 1)
+	s.tick(DefaultPeriod.Seconds())
+	if got := d.commands[len(d.commands)-1]; got != [2]int16{200, -200} {
+		t.Errorf("expected counter-clockwise spin, got %v", got)
+	}
+}
+
+func TestEmergencyStop(t *testing.T) {
+	s, d := makeTestSmoother(DefaultLimits)
+	s.DirectDrive(300, 300)
+	for i := 0; i < 100; i++ {
+		s.tick(DefaultPeriod.Seconds())
+	}
+	n := len(d.commands)
+	s.EmergencyStop()
+	if len(d.commands) != n+1 || d.commands[n] != [2]int16{0, 0} {
+		t.Fatalf("emergency stop didn't send an immediate stop: %v", d.commands[n:])
+	}
+	s.tick(DefaultPeriod.Seconds())
+	if len(d.commands) != n+1 {
+		t.Errorf("unexpected commands after emergency stop: %v", d.commands[n+1:])
+	}
+}
+
+func TestInvalidArguments(t *testing.T) {
+	s, _ := makeTestSmoother(DefaultLimits)
+	for name, err := range map[string]error{
+		"radius out of range": s.Drive(100, 3000),
+		// Not driving straight, unlike DRIVE_STRAIGHT.
+		"radius 0":       s.Drive(100, 0),
+		"velocity":       s.Drive(600, 500),
+		"wheel velocity": s.DirectDrive(100, -501),
+	} {
+		if !errors.Is(err, roomba.ErrInvalidArgument) {
+			t.Errorf("%s: got error %v, want roomba.ErrInvalidArgument", name, err)
+		}
+	}
+}
+
+type failingDriver struct {
+	calls int
+}
+
+func (d *failingDriver) DirectDrive(right, left int16) error {
+	d.calls++
+	return errors.New("link down")
+}
+
+func TestDriverError(t *testing.T) {
+	d := &failingDriver{}
+	s := MakeSmoother(d, DefaultLimits, time.Millisecond)
+	defer s.Close()
+	if err := s.DirectDrive(100, 100); err != nil {
+		t.Fatalf("drive failed before the first tick: %s", err)
+	}
+	select {
+	case <-s.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("control loop didn't stop on driver error")
+	}
+	if s.Err() == nil {
+		t.Errorf("driver error not returned by Err()")
+	}
+	if d.calls != 1 {
+		t.Errorf("got %d commands after the driver failed, want 1", d.calls)
+	}
+	if err := s.Stop(); err == nil {
+		t.Errorf("stop succeeded after the control loop stopped")
+	}
+}
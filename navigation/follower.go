@@ -0,0 +1,182 @@
+package navigation
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/xa4a/go-roomba/clock"
+	"github.com/xa4a/go-roomba/kinematics"
+)
+
+// ErrTimeout is returned by Follow when the path isn't completed in time.
+var ErrTimeout = errors.New("timed out following path")
+
+// Point is a waypoint in the odometry frame, in meters.
+type Point struct {
+	X, Y float64
+}
+
+// Progress describes the state of the follower after each control step.
+type Progress struct {
+	Pose     kinematics.Pose
+	Waypoint int     // Index of the waypoint being approached.
+	Distance float64 // Distance (m) to that waypoint.
+}
+
+// Config configures a Follower. Zero fields take the value from
+// DefaultConfig.
+type Config struct {
+	MaxSpeed  float64       // Maximum linear speed, m/s.
+	MaxTurn   float64       // Maximum angular speed, rad/s.
+	Lookahead float64       // Pure pursuit lookahead distance, m.
+	Tolerance float64       // Distance at which a waypoint counts as reached, m.
+	Period    time.Duration // Control loop period.
+	Timeout   time.Duration // Time limit for the whole path, zero for none.
+
+	// Progress, if not nil, is called after every control step.
+	Progress func(Progress)
+
+	// Clock times the control loop and the timeout, nil for the wall
+	// clock. With a virtual clock, the follower waits on the clock once
+	// per control step.
+	Clock clock.Clock
+}
+
+// DefaultConfig is a reasonable configuration for indoor driving.
+var DefaultConfig = Config{
+	MaxSpeed:  0.3,
+	MaxTurn:   2,
+	Lookahead: 0.15,
+	Tolerance: 0.03,
+	Period:    15 * time.Millisecond,
+}
+
+// Heading error above which the robot turns in place before moving on.
+const spinThreshold = math.Pi / 3
+
+// Follower steers the robot through waypoints with a pure pursuit controller.
+// Should be constructed with MakeFollower() function.
+type Follower struct {
+	Config Config
+
+	d   kinematics.DirectDriver
+	src PoseSource
+}
+
+// MakeFollower creates a Follower driving d and reading poses from src.
+func MakeFollower(d kinematics.DirectDriver, src PoseSource, config Config) *Follower {
+	def := DefaultConfig
+	if config.MaxSpeed == 0 {
+		config.MaxSpeed = def.MaxSpeed
+	}
+	if config.MaxTurn == 0 {
+		config.MaxTurn = def.MaxTurn
+	}
+	if config.Lookahead == 0 {
+		config.Lookahead = def.Lookahead
+	}
+	if config.Tolerance == 0 {
+		config.Tolerance = def.Tolerance
+	}
+	if config.Period == 0 {
+		config.Period = def.Period
+	}
+	config.Clock = clock.OrReal(config.Clock)
+	return &Follower{Config: config, d: d, src: src}
+}
+
+// Follow drives through the waypoints in order and stops the robot when the
+// last one is reached, the timeout expires, the context is cancelled or an
+// error occurs. Every waypoint has to be passed within the tolerance.
+func (f *Follower) Follow(ctx context.Context, path []Point) (err error) {
+	defer func() {
+		if stopErr := f.d.DirectDrive(0, 0); err == nil {
+			err = stopErr
+		}
+	}()
+	clk := f.Config.Clock
+	deadline := clk.Now().Add(f.Config.Timeout)
+
+	pose, err := f.src.Pose()
+	if err != nil {
+		return err
+	}
+	from := Point{pose.X, pose.Y}
+	for i := 0; i < len(path); {
+		dist := math.Hypot(path[i].X-pose.X, path[i].Y-pose.Y)
+		if dist <= f.Config.Tolerance {
+			from = path[i]
+			i++
+			continue
+		}
+		twist := f.steer(pose, from, path[i], i == len(path)-1)
+		if err := kinematics.Drive(f.d, twist); err != nil {
+			return err
+		}
+		if f.Config.Progress != nil {
+			f.Config.Progress(Progress{Pose: pose, Waypoint: i, Distance: dist})
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-clk.After(f.Config.Period):
+		}
+		if f.Config.Timeout > 0 && !clk.Now().Before(deadline) {
+			return ErrTimeout
+		}
+		if pose, err = f.src.Pose(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// steer computes the twist that brings the robot at pose onto the segment
+// from-to.
+func (f *Follower) steer(pose kinematics.Pose, from, to Point, last bool) kinematics.Twist {
+	target := lookahead(pose, from, to, f.Config.Lookahead)
+	dx, dy := target.X-pose.X, target.Y-pose.Y
+	heading := kinematics.NormalizeAngle(math.Atan2(dy, dx) - pose.Theta)
+	if math.Abs(heading) > spinThreshold {
+		return kinematics.Twist{Angular: math.Copysign(f.Config.MaxTurn, heading)}
+	}
+
+	speed := f.Config.MaxSpeed
+	if last {
+		// Slow down when approaching the end of the path.
+		remaining := math.Hypot(to.X-pose.X, to.Y-pose.Y)
+		speed = math.Min(speed, math.Max(2*remaining, f.Config.MaxSpeed/5))
+	}
+	// Pure pursuit: follow the arc through the lookahead point.
+	lateral := -math.Sin(pose.Theta)*dx + math.Cos(pose.Theta)*dy
+	curvature := 2 * lateral / (dx*dx + dy*dy)
+	if turn := math.Abs(speed * curvature); turn > f.Config.MaxTurn {
+		speed *= f.Config.MaxTurn / turn
+	}
+	return kinematics.Twist{Linear: speed, Angular: speed * curvature}
+}
+
+// lookahead returns the point on segment from-to at distance l from the
+// robot, or to if it is closer than l.
+func lookahead(pose kinematics.Pose, from, to Point, l float64) Point {
+	if math.Hypot(to.X-pose.X, to.Y-pose.Y) <= l {
+		return to
+	}
+	// Intersect the segment with the circle of radius l around the robot,
+	// taking the intersection closest to the end of the segment.
+	dx, dy := to.X-from.X, to.Y-from.Y
+	fx, fy := from.X-pose.X, from.Y-pose.Y
+	a := dx*dx + dy*dy
+	b := 2 * (fx*dx + fy*dy)
+	c := fx*fx + fy*fy - l*l
+	disc := b*b - 4*a*c
+	if a == 0 || disc < 0 {
+		// The robot is off the segment by more than l, head for its end.
+		return to
+	}
+	t := (-b + math.Sqrt(disc)) / (2 * a)
+	t = math.Max(0, math.Min(1, t))
+	return Point{from.X + t*dx, from.Y + t*dy}
+}
@@ -0,0 +1,71 @@
+package navigation_test
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/xa4a/go-roomba"
+	"github.com/xa4a/go-roomba/clock"
+	"github.com/xa4a/go-roomba/navigation"
+	"github.com/xa4a/go-roomba/sim"
+)
+
+func TestFollowSquare(t *testing.T) {
+	clk := clock.MakeVirtual(time.Unix(0, 0))
+	roombaSim, socket := sim.MakeRoombaSimClock(clk)
+	defer roombaSim.Stop()
+	r := &roomba.Roomba{S: socket, StreamPaused: make(chan bool, 1)}
+	r.Start()
+	r.Safe()
+
+	square := []navigation.Point{{0.3, 0}, {0.3, 0.3}, {0, 0.3}, {0, 0}}
+	const tolerance = 0.05
+
+	// Closest distance of the simulated robot to each corner.
+	closest := make([]float64, len(square))
+	for i := range closest {
+		closest[i] = math.Inf(1)
+	}
+	config := navigation.DefaultConfig
+	config.Timeout = 30 * time.Second
+	config.Clock = clk
+	config.Progress = func(p navigation.Progress) {
+		pose := roombaSim.Pose()
+		for i, c := range square {
+			closest[i] = math.Min(closest[i], math.Hypot(c.X-pose.X, c.Y-pose.Y))
+		}
+	}
+
+	odometry := &navigation.Odometry{}
+	f := navigation.MakeFollower(r, odometry.Polled(r), config)
+	done := make(chan error)
+	go func() { done <- f.Follow(context.Background(), square) }()
+	// The follower completes each control step before waiting on the
+	// clock, so stepping the clock only while it waits makes the run
+	// deterministic.
+	for following := true; following; {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("failed following path: %s", err)
+			}
+			following = false
+		case <-clk.Blocked(1):
+			clk.Advance(config.Period)
+		}
+	}
+
+	end := roombaSim.Pose()
+	if d := math.Hypot(end.X, end.Y); d > tolerance {
+		t.Errorf("robot stopped %f m away from the end of the path: %+v", d, end)
+	}
+	// The last corner is checked above as progress isn't reported once
+	// the path is complete.
+	for i, d := range closest[:len(square)-1] {
+		if d > tolerance {
+			t.Errorf("robot passed corner %d at %f m", i, d)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+/*
+Package navigation drives Roomba along paths using dead reckoning.
+
+Odometry integrates the distance and angle packets reported by the robot
+into a pose estimate, and Follower steers the robot through a list of
+waypoints using that estimate.
+*/
+package navigation
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/xa4a/go-roomba/constants"
+	"github.com/xa4a/go-roomba/kinematics"
+)
+
+// OdometryPackets is the list of sensor packets Odometry needs, in the order
+// expected by Track and Poll.
+var OdometryPackets = []byte{constants.SENSOR_DISTANCE, constants.SENSOR_ANGLE}
+
+// PoseSource provides the current pose estimate of the robot.
+type PoseSource interface {
+	Pose() (kinematics.Pose, error)
+}
+
+// SensorQuerier is the part of the roomba.Roomba API used for polling
+// odometry.
+type SensorQuerier interface {
+	QueryList(packet_ids []byte) ([][]byte, error)
+}
+
+// Odometry tracks the pose of the robot by integrating the distance and angle
+// it reports. The zero value starts at the origin, facing along the X axis.
+type Odometry struct {
+	mu   sync.Mutex
+	pose kinematics.Pose
+}
+
+// Pose returns the current pose estimate. It never fails.
+func (o *Odometry) Pose() (kinematics.Pose, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.pose, nil
+}
+
+// Reset sets the current pose estimate.
+func (o *Odometry) Reset(p kinematics.Pose) {
+	o.mu.Lock()
+	o.pose = p
+	o.mu.Unlock()
+}
+
+// Update advances the pose by the distance (mm) and angle (degrees) reported
+// by the SENSOR_DISTANCE and SENSOR_ANGLE packets.
+func (o *Odometry) Update(distance, angle int16) {
+	o.mu.Lock()
+	o.pose = o.pose.Advance(float64(distance)/1000, float64(angle)*math.Pi/180)
+	o.mu.Unlock()
+}
+
+// update decodes the values of OdometryPackets and updates the pose.
+func (o *Odometry) update(packets [][]byte) error {
+	if len(packets) != len(OdometryPackets) {
+		return fmt.Errorf("expected %d odometry packets, got %d",
+			len(OdometryPackets), len(packets))
+	}
+	for _, p := range packets {
+		if len(p) != 2 {
+			return fmt.Errorf("invalid odometry packet: %v", p)
+		}
+	}
+	o.Update(int16(binary.BigEndian.Uint16(packets[0])),
+		int16(binary.BigEndian.Uint16(packets[1])))
+	return nil
+}
+
+// Track updates the pose from stream frames until the channel is closed. The
+// stream must have been started with OdometryPackets, e.g.:
+//
+//	frames, _ := r.Stream(navigation.OdometryPackets)
+//	go odometry.Track(frames)
+func (o *Odometry) Track(frames <-chan [][]byte) error {
+	for frame := range frames {
+		if err := o.update(frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Poll queries the robot for the distance and angle travelled since the last
+// query and updates the pose.
+func (o *Odometry) Poll(q SensorQuerier) error {
+	packets, err := q.QueryList(OdometryPackets)
+	if err != nil {
+		return err
+	}
+	return o.update(packets)
+}
+
+// Polled returns a PoseSource that polls the robot every time the pose is
+// requested. It is an alternative to Track when streaming isn't available.
+func (o *Odometry) Polled(q SensorQuerier) PoseSource {
+	return polledOdometry{o, q}
+}
+
+type polledOdometry struct {
+	o *Odometry
+	q SensorQuerier
+}
+
+func (p polledOdometry) Pose() (kinematics.Pose, error) {
+	if err := p.o.Poll(p.q); err != nil {
+		return kinematics.Pose{}, err
+	}
+	return p.o.Pose()
+}
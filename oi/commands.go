@@ -0,0 +1,292 @@
+/*
+Package oi decodes Open Interface traffic, for understanding captured serial
+communication with a robot.
+
+Host to robot bytes are decoded into commands with DecodeCommands. Robot to
+host bytes are sensor responses and stream frames, and responses can only be
+split knowing which sensors were requested, so a Decoder fed with both
+directions decodes them:
+
+	d := oi.MakeDecoder()
+	for _, cmd := range d.Host(hostBytes) {
+		fmt.Println(cmd) // Drive velocity=-200 mm/s radius=500 mm
+	}
+	for _, msg := range d.Robot(robotBytes) {
+		fmt.Println(msg) // frame distance=12 mm angle=-3 deg
+	}
+*/
+package oi
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/xa4a/go-roomba/constants"
+)
+
+// Command is a command sent to the robot.
+type Command struct {
+	Opcode byte
+	Args   []byte
+}
+
+// Arg is a decoded command argument.
+type Arg struct {
+	Name  string
+	Value int
+	Unit  string
+	// Meaning of the value, e.g. "straight" for a Drive radius, if any.
+	Note string
+}
+
+func (a Arg) String() string {
+	s := fmt.Sprintf("%s=%d", a.Name, a.Value)
+	if a.Unit != "" {
+		s += " " + a.Unit
+	}
+	if a.Note != "" {
+		s += " (" + a.Note + ")"
+	}
+	return s
+}
+
+// argSpec describes a fixed size argument of a command.
+type argSpec struct {
+	name   string
+	size   int
+	signed bool
+	unit   string
+	note   func(int) string
+}
+
+var commandArgs = map[string][]argSpec{
+	"Start":     {},
+	"Baud":      {{name: "code", size: 1}},
+	"Safe":      {},
+	"Full":      {},
+	"Clean":     {},
+	"Max":       {},
+	"Spot":      {},
+	"Seek_dock": {},
+	"Schedule":  scheduleArgs(),
+	"SetDayTime": {
+		{name: "day", size: 1, note: dayName},
+		{name: "hour", size: 1, unit: "h"},
+		{name: "minute", size: 1, unit: "min"},
+	},
+	"Power": {},
+	"Drive": {
+		{name: "velocity", size: 2, signed: true, unit: "mm/s"},
+		{name: "radius", size: 2, signed: true, unit: "mm", note: radiusNote},
+	},
+	"DirectDrive": {
+		{name: "right", size: 2, signed: true, unit: "mm/s"},
+		{name: "left", size: 2, signed: true, unit: "mm/s"},
+	},
+	"DrivePwm": {
+		{name: "right", size: 2, signed: true, unit: "pwm"},
+		{name: "left", size: 2, signed: true, unit: "pwm"},
+	},
+	"Motors": {{name: "motors", size: 1, note: bitNames("side_brush", "vacuum", "main_brush")}},
+	"PwmMotors": {
+		{name: "main_brush", size: 1, signed: true, unit: "pwm"},
+		{name: "side_brush", size: 1, signed: true, unit: "pwm"},
+		{name: "vacuum", size: 1, unit: "pwm"},
+	},
+	"LEDs": {
+		{name: "leds", size: 1, note: bitNames("debris", "spot", "dock", "check_robot")},
+		{name: "power_color", size: 1},
+		{name: "power_intensity", size: 1},
+	},
+	"Play":         {{name: "song", size: 1}},
+	"Sensors":      {{name: "packet", size: 1, note: packetName}},
+	"ResumeStream": {{name: "resume", size: 1}},
+}
+
+func scheduleArgs() []argSpec {
+	args := []argSpec{{name: "days", size: 1, note: bitNames(days[:]...)}}
+	for _, day := range days {
+		args = append(args,
+			argSpec{name: day + "_hour", size: 1, unit: "h"},
+			argSpec{name: day + "_minute", size: 1, unit: "min"})
+	}
+	return args
+}
+
+var days = [...]string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+func dayName(v int) string {
+	if v < len(days) {
+		return days[v]
+	}
+	return ""
+}
+
+func radiusNote(v int) string {
+	switch v {
+	case constants.DRIVE_STRAIGHT, -32768:
+		return "straight"
+	case constants.DRIVE_SPIN_CW:
+		return "spin cw"
+	case constants.DRIVE_SPIN_CCW:
+		return "spin ccw"
+	}
+	return ""
+}
+
+// bitNames returns a note function listing the names of the set bits,
+// starting from the lowest one.
+func bitNames(names ...string) func(int) string {
+	return func(v int) string {
+		var set []string
+		for i, name := range names {
+			if v&(1<<uint(i)) != 0 {
+				set = append(set, name)
+			}
+		}
+		return strings.Join(set, "|")
+	}
+}
+
+// flag names a bit by its mask.
+type flag struct {
+	mask int
+	name string
+}
+
+// flagNames is like bitNames, for bits given by their masks.
+func flagNames(flags ...flag) func(int) string {
+	return func(v int) string {
+		var set []string
+		for _, f := range flags {
+			if v&f.mask != 0 {
+				set = append(set, f.name)
+			}
+		}
+		return strings.Join(set, "|")
+	}
+}
+
+// argsLength returns the number of argument bytes of the command starting at
+// data[0], or false if data is too short to tell.
+func argsLength(data []byte) (int, bool) {
+	switch Name(data[0]) {
+	case "QueryList", "Stream":
+		if len(data) < 2 {
+			return 0, false
+		}
+		return 1 + int(data[1]), true
+	case "Song":
+		if len(data) < 3 {
+			return 0, false
+		}
+		return 2 + 2*int(data[2]), true
+	}
+	n := 0
+	for _, spec := range commandArgs[Name(data[0])] {
+		n += spec.size
+	}
+	// Unknown opcodes are taken for commands without arguments.
+	return n, true
+}
+
+// DecodeCommands splits data into complete commands. It returns the commands
+// and the number of bytes they take; the rest of data is an incomplete
+// command.
+func DecodeCommands(data []byte) ([]Command, int) {
+	var cmds []Command
+	n := 0
+	for n < len(data) {
+		size, ok := argsLength(data[n:])
+		if !ok || n+1+size > len(data) {
+			break
+		}
+		cmds = append(cmds, Command{Opcode: data[n], Args: data[n+1 : n+1+size]})
+		n += 1 + size
+	}
+	return cmds, n
+}
+
+// Name returns the name of the opcode in constants.OpCodes.
+func Name(opcode byte) string {
+	for name, code := range constants.OpCodes {
+		if code == opcode {
+			return name
+		}
+	}
+	return fmt.Sprintf("Unknown%d", opcode)
+}
+
+// Name returns the name of the command in constants.OpCodes.
+func (c Command) Name() string {
+	return Name(c.Opcode)
+}
+
+// Decode returns the arguments of the command with their units.
+func (c Command) Decode() []Arg {
+	var args []Arg
+	switch c.Name() {
+	case "QueryList", "Stream":
+		if len(c.Args) == 0 {
+			return nil
+		}
+		args = append(args, Arg{Name: "count", Value: int(c.Args[0])})
+		for _, id := range c.Args[1:] {
+			args = append(args, Arg{Name: "packet", Value: int(id), Note: packetName(int(id))})
+		}
+		return args
+	case "Song":
+		if len(c.Args) < 2 {
+			return nil
+		}
+		args = append(args, Arg{Name: "song", Value: int(c.Args[0])},
+			Arg{Name: "length", Value: int(c.Args[1])})
+		for i := 2; i+1 < len(c.Args); i += 2 {
+			args = append(args, Arg{Name: "note", Value: int(c.Args[i])},
+				Arg{Name: "duration", Value: int(c.Args[i+1]), Unit: "1/64 s"})
+		}
+		return args
+	}
+	data := c.Args
+	for _, spec := range commandArgs[c.Name()] {
+		if len(data) < spec.size {
+			break
+		}
+		arg := Arg{Name: spec.name, Value: decodeValue(data[:spec.size], spec.signed), Unit: spec.unit}
+		if spec.note != nil {
+			arg.Note = spec.note(arg.Value)
+		}
+		args = append(args, arg)
+		data = data[spec.size:]
+	}
+	return args
+}
+
+// String formats the command with its decoded arguments, e.g.
+// "Drive velocity=-200 mm/s radius=500 mm".
+func (c Command) String() string {
+	s := []string{c.Name()}
+	for _, arg := range c.Decode() {
+		s = append(s, arg.String())
+	}
+	return strings.Join(s, " ")
+}
+
+// decodeValue decodes a big-endian value of 1 or 2 bytes.
+func decodeValue(data []byte, signed bool) int {
+	switch len(data) {
+	case 1:
+		if signed {
+			return int(int8(data[0]))
+		}
+		return int(data[0])
+	case 2:
+		v := binary.BigEndian.Uint16(data)
+		if signed {
+			return int(int16(v))
+		}
+		return int(v)
+	}
+	return 0
+}
@@ -0,0 +1,195 @@
+package oi
+
+import (
+	"fmt"
+	"strings"
+)
+
+// streamHeader starts every stream frame.
+const streamHeader = 19
+
+// MessageKind is the kind of data sent by the robot.
+type MessageKind int
+
+const (
+	// MessageResponse is the response to a Sensors or QueryList command.
+	MessageResponse MessageKind = iota
+	// MessageFrame is a stream frame.
+	MessageFrame
+	// MessageUnknown is data which doesn't decode as either.
+	MessageUnknown
+)
+
+func (k MessageKind) String() string {
+	switch k {
+	case MessageResponse:
+		return "response"
+	case MessageFrame:
+		return "frame"
+	case MessageUnknown:
+		return "unknown"
+	}
+	return fmt.Sprintf("MessageKind(%d)", int(k))
+}
+
+// Message is a piece of data sent by the robot.
+type Message struct {
+	Kind MessageKind
+	// Packets of the message, with group packets split into their members.
+	Packets []Packet
+	// The bytes of the message, as received.
+	Raw []byte
+	// Whether the checksum of a frame matches.
+	ChecksumOK bool
+}
+
+// String formats the message with its packets, e.g.
+// "frame distance=12 mm angle=-3 deg".
+func (m Message) String() string {
+	s := []string{m.Kind.String()}
+	switch {
+	case m.Kind == MessageUnknown:
+		s = append(s, fmt.Sprintf("[% x]", m.Raw))
+	case m.Kind == MessageFrame && !m.ChecksumOK:
+		s = append(s, "(bad checksum)")
+	}
+	for _, p := range m.Packets {
+		s = append(s, p.String())
+	}
+	return strings.Join(s, " ")
+}
+
+// Decoder decodes both directions of the communication with a robot. Bytes
+// sent by the host need to be passed in before the robot's responses to
+// them. Should be constructed with MakeDecoder() function.
+type Decoder struct {
+	host  []byte
+	robot []byte
+	// Packet IDs of the Sensors and QueryList commands awaiting responses.
+	requests [][]byte
+}
+
+// MakeDecoder creates a Decoder.
+func MakeDecoder() *Decoder {
+	return &Decoder{}
+}
+
+// Host decodes data sent by the host, returning the completed commands.
+// Incomplete commands are kept until the rest of their bytes arrive.
+func (d *Decoder) Host(data []byte) []Command {
+	d.host = append(d.host, data...)
+	cmds, n := DecodeCommands(d.host)
+	d.host = d.host[n:]
+	for _, cmd := range cmds {
+		switch cmd.Name() {
+		case "Sensors":
+			d.requests = append(d.requests, cmd.Args)
+		case "QueryList":
+			d.requests = append(d.requests, cmd.Args[1:])
+		}
+	}
+	return cmds
+}
+
+// Incomplete returns the bytes of an incomplete command sent by the host.
+func (d *Decoder) Incomplete() []byte {
+	return d.host
+}
+
+// Robot decodes data sent by the robot, returning the completed messages.
+// Incomplete messages are kept until the rest of their bytes arrive.
+func (d *Decoder) Robot(data []byte) []Message {
+	d.robot = append(d.robot, data...)
+	return d.decodeRobot(false)
+}
+
+// Flush decodes the bytes of incomplete messages sent by the robot, at the
+// end of the data.
+func (d *Decoder) Flush() []Message {
+	return d.decodeRobot(true)
+}
+
+func (d *Decoder) decodeRobot(final bool) []Message {
+	var msgs []Message
+	for len(d.robot) > 0 {
+		if d.robot[0] == streamHeader {
+			msg, n, complete := decodeFrame(d.robot)
+			if n > 0 {
+				msgs = append(msgs, msg)
+				d.robot = d.robot[n:]
+				continue
+			}
+			if !complete && !final {
+				// Wait for the rest of the frame, it can't be told from a
+				// response yet.
+				break
+			}
+		}
+		if len(d.requests) > 0 {
+			msg, n := decodeResponse(d.robot, d.requests[0])
+			if n == 0 && !final {
+				break
+			}
+			if n > 0 {
+				msgs = append(msgs, msg)
+				d.robot = d.robot[n:]
+				d.requests = d.requests[1:]
+				continue
+			}
+		}
+		// Skip to the next possible frame.
+		n := 1
+		for n < len(d.robot) && d.robot[n] != streamHeader {
+			n++
+		}
+		msgs = append(msgs, Message{Kind: MessageUnknown, Raw: d.robot[:n]})
+		d.robot = d.robot[n:]
+	}
+	return msgs
+}
+
+// decodeFrame decodes the stream frame at the start of data. It returns the
+// frame and its length, or 0 and whether data was long enough to tell that
+// it doesn't start with a frame.
+func decodeFrame(data []byte) (Message, int, bool) {
+	if len(data) < 2 {
+		return Message{}, 0, false
+	}
+	size := 3 + int(data[1])
+	if len(data) < size {
+		return Message{}, 0, false
+	}
+	msg := Message{Kind: MessageFrame, Raw: data[:size]}
+	body := data[2 : size-1]
+	for len(body) > 0 {
+		n, ok := packetLength(body[0])
+		if !ok || len(body) < 1+n {
+			return Message{}, 0, true
+		}
+		msg.Packets = append(msg.Packets, Packet{ID: body[0], Data: body[1 : 1+n]}.Members()...)
+		body = body[1+n:]
+	}
+	var sum byte
+	for _, b := range msg.Raw {
+		sum += b
+	}
+	msg.ChecksumOK = sum == 0
+	return msg, size, true
+}
+
+// decodeResponse decodes the response to a request of the given packets at
+// the start of data. It returns 0 if data is too short.
+func decodeResponse(data []byte, ids []byte) (Message, int) {
+	msg := Message{Kind: MessageResponse}
+	n := 0
+	for _, id := range ids {
+		size, _ := packetLength(id)
+		if len(data) < n+size {
+			return Message{}, 0
+		}
+		msg.Packets = append(msg.Packets, Packet{ID: id, Data: data[n : n+size]}.Members()...)
+		n += size
+	}
+	msg.Raw = data[:n]
+	return msg, n
+}
@@ -0,0 +1,130 @@
+package oi_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/xa4a/go-roomba"
+	"github.com/xa4a/go-roomba/constants"
+	"github.com/xa4a/go-roomba/oi"
+	"github.com/xa4a/go-roomba/sim"
+)
+
+func TestDecodeCommands(t *testing.T) {
+	data := []byte{
+		128, 131,
+		137, 255, 56, 127, 255,
+		149, 2, 19, 20,
+		140, 0, 1, 60, 32,
+		142, 35,
+		145, 0, // Incomplete.
+	}
+	cmds, n := oi.DecodeCommands(data)
+	if n != len(data)-2 {
+		t.Errorf("decoded %d bytes, want %d", n, len(data)-2)
+	}
+	var got []string
+	for _, cmd := range cmds {
+		got = append(got, cmd.String())
+	}
+	want := []string{
+		"Start",
+		"Safe",
+		"Drive velocity=-200 mm/s radius=32767 mm (straight)",
+		"QueryList count=2 packet=19 (distance) packet=20 (angle)",
+		"Song song=0 length=1 note=60 duration=32 1/64 s",
+		"Sensors packet=35 (oi_mode)",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got commands\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestDecodeFrame(t *testing.T) {
+	d := oi.MakeDecoder()
+	// The example from the OI specification, split in two.
+	msgs := d.Robot([]byte{19, 5, 29, 2, 25})
+	if len(msgs) != 0 {
+		t.Errorf("decoded an incomplete frame: %v", msgs)
+	}
+	// Then with a wrong checksum, and one leaving out the header.
+	msgs = d.Robot([]byte{13, 0, 163, 19, 5, 29, 2, 25, 13, 0, 164, 19, 5, 29, 2, 25, 13, 0, 182})
+	want := []string{
+		"frame cliff_front_left_signal=537 virtual_wall=0",
+		"frame (bad checksum) cliff_front_left_signal=537 virtual_wall=0",
+		"frame (bad checksum) cliff_front_left_signal=537 virtual_wall=0",
+	}
+	if len(msgs) != len(want) {
+		t.Fatalf("got messages %v, want %q", msgs, want)
+	}
+	for i, msg := range msgs {
+		if msg.String() != want[i] {
+			t.Errorf("got %q, want %q", msg, want[i])
+		}
+	}
+}
+
+func TestDecodeGroupResponse(t *testing.T) {
+	d := oi.MakeDecoder()
+	d.Host([]byte{142, 3})
+	msgs := d.Robot([]byte{2, 0x3a, 0x98, 0xfc, 0x18, 25, 0x0b, 0xb8, 0x0b, 0xb8})
+	want := "response charging=2 (full) voltage=15000 mV current=-1000 mA " +
+		"temperature=25 °C battery_charge=3000 mAh battery_capacity=3000 mAh"
+	if len(msgs) != 1 || msgs[0].String() != want {
+		t.Errorf("got messages %v, want %q", msgs, want)
+	}
+}
+
+func TestDecodeSimulatorTraffic(t *testing.T) {
+	roombaSim, socket := sim.MakeRoombaSim()
+	defer roombaSim.Stop()
+	r := &roomba.Roomba{S: socket, StreamPaused: make(chan bool, 1)}
+	r.Start()
+	r.Safe()
+	r.Drive(100, 500)
+	if _, err := r.Sensors(constants.SENSOR_OI_MODE); err != nil {
+		t.Fatalf("failed reading sensors: %s", err)
+	}
+	if _, err := r.QueryList([]byte{constants.SENSOR_DISTANCE, constants.SENSOR_VOLTAGE}); err != nil {
+		t.Fatalf("failed reading sensors: %s", err)
+	}
+	frames, err := r.Stream([]byte{constants.SENSOR_BUMP_WHEELS_DROPS, constants.SENSOR_REQUESTED_VELOCITY})
+	if err != nil {
+		t.Fatalf("failed starting stream: %s", err)
+	}
+	<-frames
+	<-frames
+	r.PauseStream()
+	for range frames {
+	}
+
+	d := oi.MakeDecoder()
+	var names []string
+	for _, cmd := range d.Host(roombaSim.Received()) {
+		names = append(names, cmd.Name())
+	}
+	wantNames := []string{"Start", "Safe", "Drive", "Sensors", "QueryList", "Stream", "ResumeStream"}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("got commands %v, want %v", names, wantNames)
+	}
+
+	msgs := append(d.Robot(roombaSim.Sent()), d.Flush()...)
+	if len(msgs) < 4 {
+		t.Fatalf("got %d messages, want at least 4: %v", len(msgs), msgs)
+	}
+	if got, want := msgs[0].String(), "response oi_mode=2 (safe)"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if msgs[1].Kind != oi.MessageResponse || len(msgs[1].Packets) != 2 {
+		t.Errorf("unexpected QueryList response %v", msgs[1])
+	}
+	for _, msg := range msgs[2:] {
+		if msg.Kind != oi.MessageFrame || !msg.ChecksumOK {
+			t.Errorf("unexpected stream message %v", msg)
+			continue
+		}
+		if v := msg.Packets[1]; v.Value() != 100 || v.Unit() != "mm/s" {
+			t.Errorf("got %v, want requested velocity of 100 mm/s", v)
+		}
+	}
+}
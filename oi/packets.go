@@ -0,0 +1,193 @@
+package oi
+
+import (
+	"fmt"
+
+	"github.com/xa4a/go-roomba/constants"
+)
+
+// Packet is a sensor packet sent by the robot.
+type Packet struct {
+	ID   byte
+	Data []byte
+}
+
+// sensorInfo describes how to decode a sensor packet.
+type sensorInfo struct {
+	name   string
+	signed bool
+	unit   string
+	note   func(int) string
+}
+
+var sensors = map[byte]sensorInfo{
+	constants.SENSOR_BUMP_WHEELS_DROPS: {name: "bump_wheels_drops",
+		note: bitNames("bump_right", "bump_left", "wheel_drop_right", "wheel_drop_left")},
+	constants.SENSOR_WALL:              {name: "wall"},
+	constants.SENSOR_CLIFF_LEFT:        {name: "cliff_left"},
+	constants.SENSOR_CLIFF_FRONT_LEFT:  {name: "cliff_front_left"},
+	constants.SENSOR_CLIFF_FRONT_RIGHT: {name: "cliff_front_right"},
+	constants.SENSOR_CLIFF_RIGHT:       {name: "cliff_right"},
+	constants.SENSOR_VIRTUAL_WALL:      {name: "virtual_wall"},
+	constants.SENSOR_WHEEL_OVERCURRENT: {name: "wheel_overcurrent",
+		note: flagNames(
+			flag{constants.OVERCURRENT_SIDE_BRUSH, "side_brush"},
+			flag{constants.OVERCURRENT_MAIN_BRUSH, "main_brush"},
+			flag{constants.OVERCURRENT_RIGHT_WHEEL, "right_wheel"},
+			flag{constants.OVERCURRENT_LEFT_WHEEL, "left_wheel"})},
+	constants.SENSOR_DIRT_DETECT: {name: "dirt_detect"},
+	constants.SENSOR_IR_OMNI:     {name: "ir_omni"},
+	constants.SENSOR_IR_LEFT:     {name: "ir_left"},
+	constants.SENSOR_IR_RIGHT:    {name: "ir_right"},
+	constants.SENSOR_BUTTONS: {name: "buttons",
+		note: bitNames("clean", "spot", "dock", "minute", "hour", "day", "schedule", "clock")},
+	constants.SENSOR_DISTANCE: {name: "distance", signed: true, unit: "mm"},
+	constants.SENSOR_ANGLE:    {name: "angle", signed: true, unit: "deg"},
+	constants.SENSOR_CHARGING: {name: "charging", note: enumNames(
+		"not_charging", "reconditioning", "full", "trickle", "waiting", "fault")},
+	constants.SENSOR_VOLTAGE:                  {name: "voltage", unit: "mV"},
+	constants.SENSOR_CURRENT:                  {name: "current", signed: true, unit: "mA"},
+	constants.SENSOR_TEMPERATURE:              {name: "temperature", signed: true, unit: "°C"},
+	constants.SENSOR_BATTERY_CHARGE:           {name: "battery_charge", unit: "mAh"},
+	constants.SENSOR_BATTERY_CAPACITY:         {name: "battery_capacity", unit: "mAh"},
+	constants.SENSOR_WALL_SIGNAL:              {name: "wall_signal"},
+	constants.SENSOR_CLIFF_LEFT_SIGNAL:        {name: "cliff_left_signal"},
+	constants.SENSOR_CLIFF_FRONT_LEFT_SIGNAL:  {name: "cliff_front_left_signal"},
+	constants.SENSOR_CLIFF_FRONT_RIGHT_SIGNAL: {name: "cliff_front_right_signal"},
+	constants.SENSOR_CLIFF_RIGHT_SIGNAL:       {name: "cliff_right_signal"},
+	constants.SENSOR_CHARGING_SOURCE: {name: "charging_source",
+		note: bitNames("internal", "home_base")},
+	constants.SENSOR_OI_MODE: {name: "oi_mode",
+		note: enumNames("off", "passive", "safe", "full")},
+	constants.SENSOR_SONG_NUMBER:              {name: "song_number"},
+	constants.SENSOR_SONG_PLAYING:             {name: "song_playing"},
+	constants.SENSOR_NUM_STREAM_PACKETS:       {name: "num_stream_packets"},
+	constants.SENSOR_REQUESTED_VELOCITY:       {name: "requested_velocity", signed: true, unit: "mm/s"},
+	constants.SENSOR_REQUESTED_RADIUS:         {name: "requested_radius", signed: true, unit: "mm", note: radiusNote},
+	constants.SENSOR_REQUESTED_RIGHT_VELOCITY: {name: "requested_right_velocity", signed: true, unit: "mm/s"},
+	constants.SENSOR_REQUESTED_LEFT_VELOCITY:  {name: "requested_left_velocity", signed: true, unit: "mm/s"},
+	constants.SENSOR_LEFT_ENCODER_COUNTS:      {name: "left_encoder_counts", unit: "counts"},
+	constants.SENSOR_RIGHT_ENCODER_COUNTS:     {name: "right_encoder_counts", unit: "counts"},
+}
+
+// groups are the first and last packet IDs of the group packets that only
+// contain packets known to constants.SENSOR_PACKET_LENGTH. Other groups are
+// left undecoded.
+var groups = map[byte][2]byte{
+	0: {7, 26},
+	1: {7, 16},
+	2: {17, 20},
+	3: {21, 26},
+	4: {27, 34},
+	5: {35, 42},
+	6: {7, 42},
+}
+
+// Lengths of the unused packets within groups. SENSOR_PACKET_LENGTH lists
+// them as 3 bytes, which is not what they take in a group.
+var unusedLengths = map[byte]int{16: 1, 32: 1, 33: 2}
+
+func enumNames(names ...string) func(int) string {
+	return func(v int) string {
+		if v < len(names) {
+			return names[v]
+		}
+		return ""
+	}
+}
+
+func packetName(id int) string {
+	if info, ok := sensors[byte(id)]; ok {
+		return info.name
+	}
+	if _, ok := groups[byte(id)]; ok || id == constants.SENSOR_ALL {
+		return fmt.Sprintf("group_%d", id)
+	}
+	return ""
+}
+
+// packetLength returns the number of bytes of the packet with the given ID.
+func packetLength(id byte) (int, bool) {
+	n, ok := constants.SENSOR_PACKET_LENGTH[id]
+	return int(n), ok
+}
+
+// Members decodes the members of a group packet, e.g. the packets of the
+// response to Roomba.Sensors(0). Packets which aren't groups, or which can't
+// be split, are returned as they are.
+func (p Packet) Members() []Packet {
+	r, ok := groups[p.ID]
+	if !ok {
+		return []Packet{p}
+	}
+	var members []Packet
+	data := p.Data
+	for id := r[0]; id <= r[1]; id++ {
+		n, unused := unusedLengths[id]
+		if !unused {
+			n, _ = packetLength(id)
+		}
+		if len(data) < n {
+			return []Packet{p}
+		}
+		if !unused {
+			members = append(members, Packet{ID: id, Data: data[:n]})
+		}
+		data = data[n:]
+	}
+	return members
+}
+
+// Name returns the name of the packet, e.g. "distance".
+func (p Packet) Name() string {
+	if name := packetName(int(p.ID)); name != "" {
+		return name
+	}
+	return fmt.Sprintf("packet_%d", p.ID)
+}
+
+// PacketId returns the ID of the packet with the given name, as returned by
+// Packet.Name.
+func PacketId(name string) (byte, bool) {
+	for id := 0; id < 256; id++ {
+		if (Packet{ID: byte(id)}).Name() == name {
+			return byte(id), true
+		}
+	}
+	return 0, false
+}
+
+// Value returns the decoded value of a single sensor packet.
+func (p Packet) Value() int {
+	return decodeValue(p.Data, sensors[p.ID].signed)
+}
+
+// Unit returns the unit of Value, if any.
+func (p Packet) Unit() string {
+	return sensors[p.ID].unit
+}
+
+// Note returns the meaning of Value for flags and states, e.g. "safe" for the
+// oi_mode packet, if any.
+func (p Packet) Note() string {
+	if note := sensors[p.ID].note; note != nil {
+		return note(p.Value())
+	}
+	return ""
+}
+
+// Known reports whether the packet is a sensor packet which Value decodes.
+// Group packets and unused packets aren't.
+func (p Packet) Known() bool {
+	_, ok := sensors[p.ID]
+	return ok
+}
+
+// String formats the packet with its value and unit, e.g. "distance=12 mm".
+// Packets which aren't known sensors are formatted as bytes.
+func (p Packet) String() string {
+	if !p.Known() {
+		return fmt.Sprintf("%s=[% x]", p.Name(), p.Data)
+	}
+	return Arg{Name: p.Name(), Value: p.Value(), Unit: p.Unit(), Note: p.Note()}.String()
+}
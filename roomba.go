@@ -3,11 +3,67 @@
 package roomba
 
 import (
+	"errors"
 	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
 )
 
 type Roomba struct {
 	PortName     string
 	S            io.ReadWriter
 	StreamPaused chan bool
+
+	// Logger receives the traffic at Debug level and errors, with the
+	// LogOpcode, LogPacketId and LogDirection fields. Nothing is logged if
+	// it is nil.
+	Logger *slog.Logger
+
+	writeMu        sync.Mutex    // Keeps the bytes of each command together.
+	checksumErrors atomic.Uint64 // Stream frames dropped by ReadStream.
+	framingErrors  atomic.Uint64 // Losses of sync of ReadStream.
 }
+
+// ErrInvalidArgument is wrapped by the errors of commands called with
+// arguments the Open Interface doesn't accept. Nothing is sent to the robot
+// then.
+var ErrInvalidArgument = errors.New("invalid argument")
+
+// Robot is the API of a Roomba, implemented by Roomba for a robot on a
+// serial link and by rpc.Client for one served by an rpc.Server, so that code
+// can control either.
+type Robot interface {
+	Start() error
+	Passive() error
+	Safe() error
+	Full() error
+	Control() error
+	Clean() error
+	Max() error
+	Spot() error
+	SeekDock() error
+	Power() error
+
+	Drive(velocity, radius int16) error
+	DriveStraight(velocity int16) error
+	SpinCW(velocity int16) error
+	SpinCCW(velocity int16) error
+	DriveArc(velocity, radius int16) error
+	DriveTwist(linear, angular float64) error
+	Stop() error
+	DirectDrive(right, left int16) error
+	DrivePWM(right, left int16) error
+
+	Motors(side_brush, vacuum, main_brush bool) error
+	LEDs(check_robot, dock, spot, debris bool, power_color, power_intensity byte) error
+	Song(song_number byte, notes []Note) error
+	Play(song_number byte) error
+
+	Sensors(packet_id byte) ([]byte, error)
+	QueryList(packet_ids []byte) ([][]byte, error)
+	Stream(packet_ids []byte) (<-chan [][]byte, error)
+	PauseStream()
+}
+
+var _ Robot = (*Roomba)(nil)
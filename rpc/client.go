@@ -0,0 +1,247 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/xa4a/go-roomba"
+	"github.com/xa4a/go-roomba/constants"
+	"github.com/xa4a/go-roomba/rpc/roombapb"
+)
+
+// DefaultTimeout is the default Client.Timeout.
+const DefaultTimeout = 5 * time.Second
+
+// Client controls a robot served by a Server, and implements roomba.Robot.
+// Should be constructed with MakeClient() function.
+type Client struct {
+	// Timeout of each call but StreamSensors, none if zero.
+	Timeout time.Duration
+
+	c roombapb.RoombaClient
+
+	mu     sync.Mutex
+	cancel context.CancelFunc // Ends the current stream.
+}
+
+var _ roomba.Robot = (*Client)(nil)
+
+// MakeClient creates a Client of the Roomba service on the connection conn.
+func MakeClient(conn grpc.ClientConnInterface) *Client {
+	return &Client{Timeout: DefaultTimeout, c: roombapb.NewRoombaClient(conn)}
+}
+
+func (c *Client) callContext() (context.Context, context.CancelFunc) {
+	if c.Timeout == 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), c.Timeout)
+}
+
+// remoteError is an error status of a call, which wraps the roomba error
+// its code stands for.
+type remoteError struct {
+	s   *status.Status
+	err error
+}
+
+func (e remoteError) Error() string              { return e.s.Message() }
+func (e remoteError) Unwrap() error              { return e.err }
+func (e remoteError) GRPCStatus() *status.Status { return e.s }
+
+// callError maps the status codes of the Server's errors back to the roomba
+// errors, so that callers check them alike for local and remote robots.
+func callError(err error) error {
+	s, _ := status.FromError(err)
+	switch s.Code() {
+	case codes.InvalidArgument:
+		return remoteError{s, roomba.ErrInvalidArgument}
+	case codes.FailedPrecondition:
+		return remoteError{s, roomba.ErrStreaming}
+	case codes.DeadlineExceeded:
+		return remoteError{s, roomba.ErrTimeout}
+	}
+	return err
+}
+
+// call runs an RPC without results.
+func call[Req any](c *Client, rpc func(context.Context, Req, ...grpc.CallOption) (*emptypb.Empty, error), req Req) error {
+	ctx, cancel := c.callContext()
+	defer cancel()
+	if _, err := rpc(ctx, req); err != nil {
+		return callError(err)
+	}
+	return nil
+}
+
+func (c *Client) Start() error    { return call(c, c.c.Start, &emptypb.Empty{}) }
+func (c *Client) Passive() error  { return call(c, c.c.Passive, &emptypb.Empty{}) }
+func (c *Client) Safe() error     { return call(c, c.c.Safe, &emptypb.Empty{}) }
+func (c *Client) Full() error     { return call(c, c.c.Full, &emptypb.Empty{}) }
+func (c *Client) Control() error  { return call(c, c.c.Control, &emptypb.Empty{}) }
+func (c *Client) Clean() error    { return call(c, c.c.Clean, &emptypb.Empty{}) }
+func (c *Client) Max() error      { return call(c, c.c.Max, &emptypb.Empty{}) }
+func (c *Client) Spot() error     { return call(c, c.c.Spot, &emptypb.Empty{}) }
+func (c *Client) SeekDock() error { return call(c, c.c.SeekDock, &emptypb.Empty{}) }
+func (c *Client) Power() error    { return call(c, c.c.Power, &emptypb.Empty{}) }
+func (c *Client) Stop() error     { return call(c, c.c.Stop, &emptypb.Empty{}) }
+
+func (c *Client) Drive(velocity, radius int16) error {
+	return call(c, c.c.Drive, &roombapb.DriveRequest{Velocity: int32(velocity), Radius: int32(radius)})
+}
+
+// DriveStraight, SpinCW, SpinCCW and DriveArc send the Drive commands
+// roomba.Roomba sends.
+
+func (c *Client) DriveStraight(velocity int16) error {
+	return c.Drive(velocity, constants.DRIVE_STRAIGHT)
+}
+
+func (c *Client) SpinCW(velocity int16) error {
+	return c.Drive(velocity, constants.DRIVE_SPIN_CW)
+}
+
+func (c *Client) SpinCCW(velocity int16) error {
+	return c.Drive(velocity, constants.DRIVE_SPIN_CCW)
+}
+
+func (c *Client) DriveArc(velocity, radius int16) error {
+	if err := roomba.CheckArcRadius(radius); err != nil {
+		return err
+	}
+	return c.Drive(velocity, radius)
+}
+
+func (c *Client) DriveTwist(linear, angular float64) error {
+	return call(c, c.c.DriveTwist, &roombapb.DriveTwistRequest{Linear: linear, Angular: angular})
+}
+
+func (c *Client) DirectDrive(right, left int16) error {
+	return call(c, c.c.DirectDrive, &roombapb.WheelsRequest{Right: int32(right), Left: int32(left)})
+}
+
+func (c *Client) DrivePWM(right, left int16) error {
+	return call(c, c.c.DrivePWM, &roombapb.WheelsRequest{Right: int32(right), Left: int32(left)})
+}
+
+func (c *Client) Motors(side_brush, vacuum, main_brush bool) error {
+	return call(c, c.c.Motors, &roombapb.MotorsRequest{SideBrush: side_brush, Vacuum: vacuum, MainBrush: main_brush})
+}
+
+func (c *Client) LEDs(check_robot, dock, spot, debris bool, power_color, power_intensity byte) error {
+	return call(c, c.c.LEDs, &roombapb.LEDsRequest{CheckRobot: check_robot, Dock: dock, Spot: spot, Debris: debris,
+		PowerColor: uint32(power_color), PowerIntensity: uint32(power_intensity)})
+}
+
+func (c *Client) Song(song_number byte, notes []roomba.Note) error {
+	req := &roombapb.SongRequest{SongNumber: uint32(song_number)}
+	for _, n := range notes {
+		req.Notes = append(req.Notes, &roombapb.Note{Number: uint32(n.Number), Duration: uint32(n.Duration)})
+	}
+	return call(c, c.c.Song, req)
+}
+
+func (c *Client) Play(song_number byte) error {
+	return call(c, c.c.Play, &roombapb.PlayRequest{SongNumber: uint32(song_number)})
+}
+
+func sensorsRequest(packet_ids []byte) *roombapb.SensorsRequest {
+	req := &roombapb.SensorsRequest{PacketIds: make([]uint32, len(packet_ids))}
+	for i, id := range packet_ids {
+		req.PacketIds[i] = uint32(id)
+	}
+	return req
+}
+
+// frame returns the data of the packets of a response.
+func frame(res *roombapb.SensorsResponse, count int) ([][]byte, error) {
+	if len(res.Packets) != count {
+		return nil, fmt.Errorf("received %d packets, requested %d", len(res.Packets), count)
+	}
+	data := make([][]byte, count)
+	for i, p := range res.Packets {
+		data[i] = p.Data
+	}
+	return data, nil
+}
+
+// Sensors queries a single packet with QuerySensors.
+func (c *Client) Sensors(packet_id byte) ([]byte, error) {
+	data, err := c.QueryList([]byte{packet_id})
+	if err != nil {
+		return nil, err
+	}
+	return data[0], nil
+}
+
+func (c *Client) QueryList(packet_ids []byte) ([][]byte, error) {
+	ctx, cancel := c.callContext()
+	defer cancel()
+	res, err := c.c.QuerySensors(ctx, sensorsRequest(packet_ids))
+	if err != nil {
+		return nil, callError(err)
+	}
+	return frame(res, len(packet_ids))
+}
+
+// Stream starts a StreamSensors call. Like with roomba.Roomba, the channel is
+// closed when the stream is paused or fails.
+func (c *Client) Stream(packet_ids []byte) (<-chan [][]byte, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := c.c.StreamSensors(ctx, sensorsRequest(packet_ids))
+	if err == nil {
+		// The server sends the header once the stream started, so that
+		// errors are returned here. Failed calls end without a header.
+		var header metadata.MD
+		if header, err = stream.Header(); err == nil && header == nil {
+			_, err = stream.Recv()
+		}
+	}
+	if err != nil {
+		cancel()
+		return nil, callError(err)
+	}
+	c.mu.Lock()
+	c.cancel = cancel
+	c.mu.Unlock()
+
+	out := make(chan [][]byte)
+	go func() {
+		defer close(out)
+		defer cancel()
+		for {
+			res, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			data, err := frame(res, len(packet_ids))
+			if err != nil {
+				return
+			}
+			select {
+			case out <- data:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// PauseStream ends the stream started by the last call of Stream.
+func (c *Client) PauseStream() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cancel != nil {
+		c.cancel()
+		c.cancel = nil
+	}
+}
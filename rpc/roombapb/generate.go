@@ -0,0 +1,5 @@
+// Package roombapb contains the protocol buffers and gRPC code generated from
+// roomba.proto.
+package roombapb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative roomba.proto
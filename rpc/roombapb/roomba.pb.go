@@ -0,0 +1,833 @@
+// The Roomba service mirrors the API of roomba.Roomba in the
+// github.com/xa4a/go-roomba Go package, for controlling a robot from other
+// processes and languages. See the Go package for the effect of each command.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        (unknown)
+// source: roomba.proto
+
+package roombapb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// DriveRequest is the argument of Drive, velocity in mm/s and radius in mm,
+// including the special cases of the radius.
+type DriveRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Velocity      int32                  `protobuf:"zigzag32,1,opt,name=velocity,proto3" json:"velocity,omitempty"`
+	Radius        int32                  `protobuf:"zigzag32,2,opt,name=radius,proto3" json:"radius,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DriveRequest) Reset() {
+	*x = DriveRequest{}
+	mi := &file_roomba_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DriveRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DriveRequest) ProtoMessage() {}
+
+func (x *DriveRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_roomba_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DriveRequest.ProtoReflect.Descriptor instead.
+func (*DriveRequest) Descriptor() ([]byte, []int) {
+	return file_roomba_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *DriveRequest) GetVelocity() int32 {
+	if x != nil {
+		return x.Velocity
+	}
+	return 0
+}
+
+func (x *DriveRequest) GetRadius() int32 {
+	if x != nil {
+		return x.Radius
+	}
+	return 0
+}
+
+// DriveTwistRequest is the argument of DriveTwist.
+type DriveTwistRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Linear        float64                `protobuf:"fixed64,1,opt,name=linear,proto3" json:"linear,omitempty"`   // m/s
+	Angular       float64                `protobuf:"fixed64,2,opt,name=angular,proto3" json:"angular,omitempty"` // rad/s, counter-clockwise positive.
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DriveTwistRequest) Reset() {
+	*x = DriveTwistRequest{}
+	mi := &file_roomba_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DriveTwistRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DriveTwistRequest) ProtoMessage() {}
+
+func (x *DriveTwistRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_roomba_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DriveTwistRequest.ProtoReflect.Descriptor instead.
+func (*DriveTwistRequest) Descriptor() ([]byte, []int) {
+	return file_roomba_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *DriveTwistRequest) GetLinear() float64 {
+	if x != nil {
+		return x.Linear
+	}
+	return 0
+}
+
+func (x *DriveTwistRequest) GetAngular() float64 {
+	if x != nil {
+		return x.Angular
+	}
+	return 0
+}
+
+// WheelsRequest is the argument of DirectDrive, in mm/s, and DrivePWM.
+type WheelsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Right         int32                  `protobuf:"zigzag32,1,opt,name=right,proto3" json:"right,omitempty"`
+	Left          int32                  `protobuf:"zigzag32,2,opt,name=left,proto3" json:"left,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WheelsRequest) Reset() {
+	*x = WheelsRequest{}
+	mi := &file_roomba_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WheelsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WheelsRequest) ProtoMessage() {}
+
+func (x *WheelsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_roomba_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WheelsRequest.ProtoReflect.Descriptor instead.
+func (*WheelsRequest) Descriptor() ([]byte, []int) {
+	return file_roomba_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *WheelsRequest) GetRight() int32 {
+	if x != nil {
+		return x.Right
+	}
+	return 0
+}
+
+func (x *WheelsRequest) GetLeft() int32 {
+	if x != nil {
+		return x.Left
+	}
+	return 0
+}
+
+type MotorsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SideBrush     bool                   `protobuf:"varint,1,opt,name=side_brush,json=sideBrush,proto3" json:"side_brush,omitempty"`
+	Vacuum        bool                   `protobuf:"varint,2,opt,name=vacuum,proto3" json:"vacuum,omitempty"`
+	MainBrush     bool                   `protobuf:"varint,3,opt,name=main_brush,json=mainBrush,proto3" json:"main_brush,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MotorsRequest) Reset() {
+	*x = MotorsRequest{}
+	mi := &file_roomba_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MotorsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MotorsRequest) ProtoMessage() {}
+
+func (x *MotorsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_roomba_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MotorsRequest.ProtoReflect.Descriptor instead.
+func (*MotorsRequest) Descriptor() ([]byte, []int) {
+	return file_roomba_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *MotorsRequest) GetSideBrush() bool {
+	if x != nil {
+		return x.SideBrush
+	}
+	return false
+}
+
+func (x *MotorsRequest) GetVacuum() bool {
+	if x != nil {
+		return x.Vacuum
+	}
+	return false
+}
+
+func (x *MotorsRequest) GetMainBrush() bool {
+	if x != nil {
+		return x.MainBrush
+	}
+	return false
+}
+
+type LEDsRequest struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	CheckRobot     bool                   `protobuf:"varint,1,opt,name=check_robot,json=checkRobot,proto3" json:"check_robot,omitempty"`
+	Dock           bool                   `protobuf:"varint,2,opt,name=dock,proto3" json:"dock,omitempty"`
+	Spot           bool                   `protobuf:"varint,3,opt,name=spot,proto3" json:"spot,omitempty"`
+	Debris         bool                   `protobuf:"varint,4,opt,name=debris,proto3" json:"debris,omitempty"`
+	PowerColor     uint32                 `protobuf:"varint,5,opt,name=power_color,json=powerColor,proto3" json:"power_color,omitempty"`             // 0 = green, 255 = red.
+	PowerIntensity uint32                 `protobuf:"varint,6,opt,name=power_intensity,json=powerIntensity,proto3" json:"power_intensity,omitempty"` // 0 = off, 255 = full intensity.
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *LEDsRequest) Reset() {
+	*x = LEDsRequest{}
+	mi := &file_roomba_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LEDsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LEDsRequest) ProtoMessage() {}
+
+func (x *LEDsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_roomba_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LEDsRequest.ProtoReflect.Descriptor instead.
+func (*LEDsRequest) Descriptor() ([]byte, []int) {
+	return file_roomba_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *LEDsRequest) GetCheckRobot() bool {
+	if x != nil {
+		return x.CheckRobot
+	}
+	return false
+}
+
+func (x *LEDsRequest) GetDock() bool {
+	if x != nil {
+		return x.Dock
+	}
+	return false
+}
+
+func (x *LEDsRequest) GetSpot() bool {
+	if x != nil {
+		return x.Spot
+	}
+	return false
+}
+
+func (x *LEDsRequest) GetDebris() bool {
+	if x != nil {
+		return x.Debris
+	}
+	return false
+}
+
+func (x *LEDsRequest) GetPowerColor() uint32 {
+	if x != nil {
+		return x.PowerColor
+	}
+	return 0
+}
+
+func (x *LEDsRequest) GetPowerIntensity() uint32 {
+	if x != nil {
+		return x.PowerIntensity
+	}
+	return 0
+}
+
+type Note struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Number        uint32                 `protobuf:"varint,1,opt,name=number,proto3" json:"number,omitempty"`     // MIDI note number (31 - 127), other values are rests.
+	Duration      uint32                 `protobuf:"varint,2,opt,name=duration,proto3" json:"duration,omitempty"` // In 1/64ths of a second.
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Note) Reset() {
+	*x = Note{}
+	mi := &file_roomba_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Note) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Note) ProtoMessage() {}
+
+func (x *Note) ProtoReflect() protoreflect.Message {
+	mi := &file_roomba_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Note.ProtoReflect.Descriptor instead.
+func (*Note) Descriptor() ([]byte, []int) {
+	return file_roomba_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Note) GetNumber() uint32 {
+	if x != nil {
+		return x.Number
+	}
+	return 0
+}
+
+func (x *Note) GetDuration() uint32 {
+	if x != nil {
+		return x.Duration
+	}
+	return 0
+}
+
+type SongRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SongNumber    uint32                 `protobuf:"varint,1,opt,name=song_number,json=songNumber,proto3" json:"song_number,omitempty"` // 0 - 4.
+	Notes         []*Note                `protobuf:"bytes,2,rep,name=notes,proto3" json:"notes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SongRequest) Reset() {
+	*x = SongRequest{}
+	mi := &file_roomba_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SongRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SongRequest) ProtoMessage() {}
+
+func (x *SongRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_roomba_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SongRequest.ProtoReflect.Descriptor instead.
+func (*SongRequest) Descriptor() ([]byte, []int) {
+	return file_roomba_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *SongRequest) GetSongNumber() uint32 {
+	if x != nil {
+		return x.SongNumber
+	}
+	return 0
+}
+
+func (x *SongRequest) GetNotes() []*Note {
+	if x != nil {
+		return x.Notes
+	}
+	return nil
+}
+
+type PlayRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SongNumber    uint32                 `protobuf:"varint,1,opt,name=song_number,json=songNumber,proto3" json:"song_number,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PlayRequest) Reset() {
+	*x = PlayRequest{}
+	mi := &file_roomba_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PlayRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PlayRequest) ProtoMessage() {}
+
+func (x *PlayRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_roomba_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PlayRequest.ProtoReflect.Descriptor instead.
+func (*PlayRequest) Descriptor() ([]byte, []int) {
+	return file_roomba_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *PlayRequest) GetSongNumber() uint32 {
+	if x != nil {
+		return x.SongNumber
+	}
+	return 0
+}
+
+type SensorsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// IDs of single sensor or group packets.
+	PacketIds     []uint32 `protobuf:"varint,1,rep,packed,name=packet_ids,json=packetIds,proto3" json:"packet_ids,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SensorsRequest) Reset() {
+	*x = SensorsRequest{}
+	mi := &file_roomba_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SensorsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SensorsRequest) ProtoMessage() {}
+
+func (x *SensorsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_roomba_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SensorsRequest.ProtoReflect.Descriptor instead.
+func (*SensorsRequest) Descriptor() ([]byte, []int) {
+	return file_roomba_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *SensorsRequest) GetPacketIds() []uint32 {
+	if x != nil {
+		return x.PacketIds
+	}
+	return nil
+}
+
+// Packet is a sensor packet sent by the robot.
+type Packet struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    uint32                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Data  []byte                 `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"` // As sent by the robot.
+	// The decoded value of single sensor packets, as by the oi Go package,
+	// e.g. "oi_mode", 2, "" and "safe". Only data is set for group packets.
+	Name          string `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	Value         int32  `protobuf:"zigzag32,4,opt,name=value,proto3" json:"value,omitempty"`
+	Unit          string `protobuf:"bytes,5,opt,name=unit,proto3" json:"unit,omitempty"`
+	Note          string `protobuf:"bytes,6,opt,name=note,proto3" json:"note,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Packet) Reset() {
+	*x = Packet{}
+	mi := &file_roomba_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Packet) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Packet) ProtoMessage() {}
+
+func (x *Packet) ProtoReflect() protoreflect.Message {
+	mi := &file_roomba_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Packet.ProtoReflect.Descriptor instead.
+func (*Packet) Descriptor() ([]byte, []int) {
+	return file_roomba_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Packet) GetId() uint32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Packet) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *Packet) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Packet) GetValue() int32 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
+func (x *Packet) GetUnit() string {
+	if x != nil {
+		return x.Unit
+	}
+	return ""
+}
+
+func (x *Packet) GetNote() string {
+	if x != nil {
+		return x.Note
+	}
+	return ""
+}
+
+type SensorsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Packets       []*Packet              `protobuf:"bytes,1,rep,name=packets,proto3" json:"packets,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SensorsResponse) Reset() {
+	*x = SensorsResponse{}
+	mi := &file_roomba_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SensorsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SensorsResponse) ProtoMessage() {}
+
+func (x *SensorsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_roomba_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SensorsResponse.ProtoReflect.Descriptor instead.
+func (*SensorsResponse) Descriptor() ([]byte, []int) {
+	return file_roomba_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *SensorsResponse) GetPackets() []*Packet {
+	if x != nil {
+		return x.Packets
+	}
+	return nil
+}
+
+var File_roomba_proto protoreflect.FileDescriptor
+
+const file_roomba_proto_rawDesc = "" +
+	"\n" +
+	"\froomba.proto\x12\x06roomba\x1a\x1bgoogle/protobuf/empty.proto\"B\n" +
+	"\fDriveRequest\x12\x1a\n" +
+	"\bvelocity\x18\x01 \x01(\x11R\bvelocity\x12\x16\n" +
+	"\x06radius\x18\x02 \x01(\x11R\x06radius\"E\n" +
+	"\x11DriveTwistRequest\x12\x16\n" +
+	"\x06linear\x18\x01 \x01(\x01R\x06linear\x12\x18\n" +
+	"\aangular\x18\x02 \x01(\x01R\aangular\"9\n" +
+	"\rWheelsRequest\x12\x14\n" +
+	"\x05right\x18\x01 \x01(\x11R\x05right\x12\x12\n" +
+	"\x04left\x18\x02 \x01(\x11R\x04left\"e\n" +
+	"\rMotorsRequest\x12\x1d\n" +
+	"\n" +
+	"side_brush\x18\x01 \x01(\bR\tsideBrush\x12\x16\n" +
+	"\x06vacuum\x18\x02 \x01(\bR\x06vacuum\x12\x1d\n" +
+	"\n" +
+	"main_brush\x18\x03 \x01(\bR\tmainBrush\"\xb8\x01\n" +
+	"\vLEDsRequest\x12\x1f\n" +
+	"\vcheck_robot\x18\x01 \x01(\bR\n" +
+	"checkRobot\x12\x12\n" +
+	"\x04dock\x18\x02 \x01(\bR\x04dock\x12\x12\n" +
+	"\x04spot\x18\x03 \x01(\bR\x04spot\x12\x16\n" +
+	"\x06debris\x18\x04 \x01(\bR\x06debris\x12\x1f\n" +
+	"\vpower_color\x18\x05 \x01(\rR\n" +
+	"powerColor\x12'\n" +
+	"\x0fpower_intensity\x18\x06 \x01(\rR\x0epowerIntensity\":\n" +
+	"\x04Note\x12\x16\n" +
+	"\x06number\x18\x01 \x01(\rR\x06number\x12\x1a\n" +
+	"\bduration\x18\x02 \x01(\rR\bduration\"R\n" +
+	"\vSongRequest\x12\x1f\n" +
+	"\vsong_number\x18\x01 \x01(\rR\n" +
+	"songNumber\x12\"\n" +
+	"\x05notes\x18\x02 \x03(\v2\f.roomba.NoteR\x05notes\".\n" +
+	"\vPlayRequest\x12\x1f\n" +
+	"\vsong_number\x18\x01 \x01(\rR\n" +
+	"songNumber\"/\n" +
+	"\x0eSensorsRequest\x12\x1d\n" +
+	"\n" +
+	"packet_ids\x18\x01 \x03(\rR\tpacketIds\"~\n" +
+	"\x06Packet\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\rR\x02id\x12\x12\n" +
+	"\x04data\x18\x02 \x01(\fR\x04data\x12\x12\n" +
+	"\x04name\x18\x03 \x01(\tR\x04name\x12\x14\n" +
+	"\x05value\x18\x04 \x01(\x11R\x05value\x12\x12\n" +
+	"\x04unit\x18\x05 \x01(\tR\x04unit\x12\x12\n" +
+	"\x04note\x18\x06 \x01(\tR\x04note\";\n" +
+	"\x0fSensorsResponse\x12(\n" +
+	"\apackets\x18\x01 \x03(\v2\x0e.roomba.PacketR\apackets2\xca\t\n" +
+	"\x06Roomba\x127\n" +
+	"\x05Start\x12\x16.google.protobuf.Empty\x1a\x16.google.protobuf.Empty\x129\n" +
+	"\aPassive\x12\x16.google.protobuf.Empty\x1a\x16.google.protobuf.Empty\x126\n" +
+	"\x04Safe\x12\x16.google.protobuf.Empty\x1a\x16.google.protobuf.Empty\x126\n" +
+	"\x04Full\x12\x16.google.protobuf.Empty\x1a\x16.google.protobuf.Empty\x129\n" +
+	"\aControl\x12\x16.google.protobuf.Empty\x1a\x16.google.protobuf.Empty\x127\n" +
+	"\x05Clean\x12\x16.google.protobuf.Empty\x1a\x16.google.protobuf.Empty\x125\n" +
+	"\x03Max\x12\x16.google.protobuf.Empty\x1a\x16.google.protobuf.Empty\x126\n" +
+	"\x04Spot\x12\x16.google.protobuf.Empty\x1a\x16.google.protobuf.Empty\x12:\n" +
+	"\bSeekDock\x12\x16.google.protobuf.Empty\x1a\x16.google.protobuf.Empty\x127\n" +
+	"\x05Power\x12\x16.google.protobuf.Empty\x1a\x16.google.protobuf.Empty\x125\n" +
+	"\x05Drive\x12\x14.roomba.DriveRequest\x1a\x16.google.protobuf.Empty\x12?\n" +
+	"\n" +
+	"DriveTwist\x12\x19.roomba.DriveTwistRequest\x1a\x16.google.protobuf.Empty\x12<\n" +
+	"\vDirectDrive\x12\x15.roomba.WheelsRequest\x1a\x16.google.protobuf.Empty\x129\n" +
+	"\bDrivePWM\x12\x15.roomba.WheelsRequest\x1a\x16.google.protobuf.Empty\x126\n" +
+	"\x04Stop\x12\x16.google.protobuf.Empty\x1a\x16.google.protobuf.Empty\x127\n" +
+	"\x06Motors\x12\x15.roomba.MotorsRequest\x1a\x16.google.protobuf.Empty\x123\n" +
+	"\x04LEDs\x12\x13.roomba.LEDsRequest\x1a\x16.google.protobuf.Empty\x123\n" +
+	"\x04Song\x12\x13.roomba.SongRequest\x1a\x16.google.protobuf.Empty\x123\n" +
+	"\x04Play\x12\x13.roomba.PlayRequest\x1a\x16.google.protobuf.Empty\x12?\n" +
+	"\fQuerySensors\x12\x16.roomba.SensorsRequest\x1a\x17.roomba.SensorsResponse\x12B\n" +
+	"\rStreamSensors\x12\x16.roomba.SensorsRequest\x1a\x17.roomba.SensorsResponse0\x01B(Z&github.com/xa4a/go-roomba/rpc/roombapbb\x06proto3"
+
+var (
+	file_roomba_proto_rawDescOnce sync.Once
+	file_roomba_proto_rawDescData []byte
+)
+
+func file_roomba_proto_rawDescGZIP() []byte {
+	file_roomba_proto_rawDescOnce.Do(func() {
+		file_roomba_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_roomba_proto_rawDesc), len(file_roomba_proto_rawDesc)))
+	})
+	return file_roomba_proto_rawDescData
+}
+
+var file_roomba_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
+var file_roomba_proto_goTypes = []any{
+	(*DriveRequest)(nil),      // 0: roomba.DriveRequest
+	(*DriveTwistRequest)(nil), // 1: roomba.DriveTwistRequest
+	(*WheelsRequest)(nil),     // 2: roomba.WheelsRequest
+	(*MotorsRequest)(nil),     // 3: roomba.MotorsRequest
+	(*LEDsRequest)(nil),       // 4: roomba.LEDsRequest
+	(*Note)(nil),              // 5: roomba.Note
+	(*SongRequest)(nil),       // 6: roomba.SongRequest
+	(*PlayRequest)(nil),       // 7: roomba.PlayRequest
+	(*SensorsRequest)(nil),    // 8: roomba.SensorsRequest
+	(*Packet)(nil),            // 9: roomba.Packet
+	(*SensorsResponse)(nil),   // 10: roomba.SensorsResponse
+	(*emptypb.Empty)(nil),     // 11: google.protobuf.Empty
+}
+var file_roomba_proto_depIdxs = []int32{
+	5,  // 0: roomba.SongRequest.notes:type_name -> roomba.Note
+	9,  // 1: roomba.SensorsResponse.packets:type_name -> roomba.Packet
+	11, // 2: roomba.Roomba.Start:input_type -> google.protobuf.Empty
+	11, // 3: roomba.Roomba.Passive:input_type -> google.protobuf.Empty
+	11, // 4: roomba.Roomba.Safe:input_type -> google.protobuf.Empty
+	11, // 5: roomba.Roomba.Full:input_type -> google.protobuf.Empty
+	11, // 6: roomba.Roomba.Control:input_type -> google.protobuf.Empty
+	11, // 7: roomba.Roomba.Clean:input_type -> google.protobuf.Empty
+	11, // 8: roomba.Roomba.Max:input_type -> google.protobuf.Empty
+	11, // 9: roomba.Roomba.Spot:input_type -> google.protobuf.Empty
+	11, // 10: roomba.Roomba.SeekDock:input_type -> google.protobuf.Empty
+	11, // 11: roomba.Roomba.Power:input_type -> google.protobuf.Empty
+	0,  // 12: roomba.Roomba.Drive:input_type -> roomba.DriveRequest
+	1,  // 13: roomba.Roomba.DriveTwist:input_type -> roomba.DriveTwistRequest
+	2,  // 14: roomba.Roomba.DirectDrive:input_type -> roomba.WheelsRequest
+	2,  // 15: roomba.Roomba.DrivePWM:input_type -> roomba.WheelsRequest
+	11, // 16: roomba.Roomba.Stop:input_type -> google.protobuf.Empty
+	3,  // 17: roomba.Roomba.Motors:input_type -> roomba.MotorsRequest
+	4,  // 18: roomba.Roomba.LEDs:input_type -> roomba.LEDsRequest
+	6,  // 19: roomba.Roomba.Song:input_type -> roomba.SongRequest
+	7,  // 20: roomba.Roomba.Play:input_type -> roomba.PlayRequest
+	8,  // 21: roomba.Roomba.QuerySensors:input_type -> roomba.SensorsRequest
+	8,  // 22: roomba.Roomba.StreamSensors:input_type -> roomba.SensorsRequest
+	11, // 23: roomba.Roomba.Start:output_type -> google.protobuf.Empty
+	11, // 24: roomba.Roomba.Passive:output_type -> google.protobuf.Empty
+	11, // 25: roomba.Roomba.Safe:output_type -> google.protobuf.Empty
+	11, // 26: roomba.Roomba.Full:output_type -> google.protobuf.Empty
+	11, // 27: roomba.Roomba.Control:output_type -> google.protobuf.Empty
+	11, // 28: roomba.Roomba.Clean:output_type -> google.protobuf.Empty
+	11, // 29: roomba.Roomba.Max:output_type -> google.protobuf.Empty
+	11, // 30: roomba.Roomba.Spot:output_type -> google.protobuf.Empty
+	11, // 31: roomba.Roomba.SeekDock:output_type -> google.protobuf.Empty
+	11, // 32: roomba.Roomba.Power:output_type -> google.protobuf.Empty
+	11, // 33: roomba.Roomba.Drive:output_type -> google.protobuf.Empty
+	11, // 34: roomba.Roomba.DriveTwist:output_type -> google.protobuf.Empty
+	11, // 35: roomba.Roomba.DirectDrive:output_type -> google.protobuf.Empty
+	11, // 36: roomba.Roomba.DrivePWM:output_type -> google.protobuf.Empty
+	11, // 37: roomba.Roomba.Stop:output_type -> google.protobuf.Empty
+	11, // 38: roomba.Roomba.Motors:output_type -> google.protobuf.Empty
+	11, // 39: roomba.Roomba.LEDs:output_type -> google.protobuf.Empty
+	11, // 40: roomba.Roomba.Song:output_type -> google.protobuf.Empty
+	11, // 41: roomba.Roomba.Play:output_type -> google.protobuf.Empty
+	10, // 42: roomba.Roomba.QuerySensors:output_type -> roomba.SensorsResponse
+	10, // 43: roomba.Roomba.StreamSensors:output_type -> roomba.SensorsResponse
+	23, // [23:44] is the sub-list for method output_type
+	2,  // [2:23] is the sub-list for method input_type
+	2,  // [2:2] is the sub-list for extension type_name
+	2,  // [2:2] is the sub-list for extension extendee
+	0,  // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_roomba_proto_init() }
+func file_roomba_proto_init() {
+	if File_roomba_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_roomba_proto_rawDesc), len(file_roomba_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   11,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_roomba_proto_goTypes,
+		DependencyIndexes: file_roomba_proto_depIdxs,
+		MessageInfos:      file_roomba_proto_msgTypes,
+	}.Build()
+	File_roomba_proto = out.File
+	file_roomba_proto_goTypes = nil
+	file_roomba_proto_depIdxs = nil
+}
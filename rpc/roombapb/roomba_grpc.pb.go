@@ -0,0 +1,906 @@
+// The Roomba service mirrors the API of roomba.Roomba in the
+// github.com/xa4a/go-roomba Go package, for controlling a robot from other
+// processes and languages. See the Go package for the effect of each command.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: roomba.proto
+
+package roombapb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Roomba_Start_FullMethodName         = "/roomba.Roomba/Start"
+	Roomba_Passive_FullMethodName       = "/roomba.Roomba/Passive"
+	Roomba_Safe_FullMethodName          = "/roomba.Roomba/Safe"
+	Roomba_Full_FullMethodName          = "/roomba.Roomba/Full"
+	Roomba_Control_FullMethodName       = "/roomba.Roomba/Control"
+	Roomba_Clean_FullMethodName         = "/roomba.Roomba/Clean"
+	Roomba_Max_FullMethodName           = "/roomba.Roomba/Max"
+	Roomba_Spot_FullMethodName          = "/roomba.Roomba/Spot"
+	Roomba_SeekDock_FullMethodName      = "/roomba.Roomba/SeekDock"
+	Roomba_Power_FullMethodName         = "/roomba.Roomba/Power"
+	Roomba_Drive_FullMethodName         = "/roomba.Roomba/Drive"
+	Roomba_DriveTwist_FullMethodName    = "/roomba.Roomba/DriveTwist"
+	Roomba_DirectDrive_FullMethodName   = "/roomba.Roomba/DirectDrive"
+	Roomba_DrivePWM_FullMethodName      = "/roomba.Roomba/DrivePWM"
+	Roomba_Stop_FullMethodName          = "/roomba.Roomba/Stop"
+	Roomba_Motors_FullMethodName        = "/roomba.Roomba/Motors"
+	Roomba_LEDs_FullMethodName          = "/roomba.Roomba/LEDs"
+	Roomba_Song_FullMethodName          = "/roomba.Roomba/Song"
+	Roomba_Play_FullMethodName          = "/roomba.Roomba/Play"
+	Roomba_QuerySensors_FullMethodName  = "/roomba.Roomba/QuerySensors"
+	Roomba_StreamSensors_FullMethodName = "/roomba.Roomba/StreamSensors"
+)
+
+// RoombaClient is the client API for Roomba service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type RoombaClient interface {
+	// Mode commands.
+	Start(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	Passive(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	Safe(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	Full(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	Control(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// Cleaning commands, which leave the robot in Passive mode.
+	Clean(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	Max(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	Spot(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	SeekDock(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	Power(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// Drive commands.
+	Drive(ctx context.Context, in *DriveRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	DriveTwist(ctx context.Context, in *DriveTwistRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	DirectDrive(ctx context.Context, in *WheelsRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	DrivePWM(ctx context.Context, in *WheelsRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	Stop(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	Motors(ctx context.Context, in *MotorsRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	LEDs(ctx context.Context, in *LEDsRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	Song(ctx context.Context, in *SongRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	Play(ctx context.Context, in *PlayRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// QuerySensors returns the current value of the requested packets. It fails
+	// with FAILED_PRECONDITION while the sensors are streamed.
+	QuerySensors(ctx context.Context, in *SensorsRequest, opts ...grpc.CallOption) (*SensorsResponse, error)
+	// StreamSensors sends the requested packets every 15 ms, until the call is
+	// cancelled. Only one stream runs at a time, others fail with
+	// FAILED_PRECONDITION.
+	StreamSensors(ctx context.Context, in *SensorsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[SensorsResponse], error)
+}
+
+type roombaClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRoombaClient(cc grpc.ClientConnInterface) RoombaClient {
+	return &roombaClient{cc}
+}
+
+func (c *roombaClient) Start(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Roomba_Start_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *roombaClient) Passive(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Roomba_Passive_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *roombaClient) Safe(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Roomba_Safe_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *roombaClient) Full(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Roomba_Full_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *roombaClient) Control(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Roomba_Control_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *roombaClient) Clean(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Roomba_Clean_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *roombaClient) Max(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Roomba_Max_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *roombaClient) Spot(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Roomba_Spot_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *roombaClient) SeekDock(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Roomba_SeekDock_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *roombaClient) Power(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Roomba_Power_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *roombaClient) Drive(ctx context.Context, in *DriveRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Roomba_Drive_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *roombaClient) DriveTwist(ctx context.Context, in *DriveTwistRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Roomba_DriveTwist_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *roombaClient) DirectDrive(ctx context.Context, in *WheelsRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Roomba_DirectDrive_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *roombaClient) DrivePWM(ctx context.Context, in *WheelsRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Roomba_DrivePWM_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *roombaClient) Stop(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Roomba_Stop_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *roombaClient) Motors(ctx context.Context, in *MotorsRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Roomba_Motors_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *roombaClient) LEDs(ctx context.Context, in *LEDsRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Roomba_LEDs_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *roombaClient) Song(ctx context.Context, in *SongRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Roomba_Song_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *roombaClient) Play(ctx context.Context, in *PlayRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Roomba_Play_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *roombaClient) QuerySensors(ctx context.Context, in *SensorsRequest, opts ...grpc.CallOption) (*SensorsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SensorsResponse)
+	err := c.cc.Invoke(ctx, Roomba_QuerySensors_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *roombaClient) StreamSensors(ctx context.Context, in *SensorsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[SensorsResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Roomba_ServiceDesc.Streams[0], Roomba_StreamSensors_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SensorsRequest, SensorsResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Roomba_StreamSensorsClient = grpc.ServerStreamingClient[SensorsResponse]
+
+// RoombaServer is the server API for Roomba service.
+// All implementations must embed UnimplementedRoombaServer
+// for forward compatibility.
+type RoombaServer interface {
+	// Mode commands.
+	Start(context.Context, *emptypb.Empty) (*emptypb.Empty, error)
+	Passive(context.Context, *emptypb.Empty) (*emptypb.Empty, error)
+	Safe(context.Context, *emptypb.Empty) (*emptypb.Empty, error)
+	Full(context.Context, *emptypb.Empty) (*emptypb.Empty, error)
+	Control(context.Context, *emptypb.Empty) (*emptypb.Empty, error)
+	// Cleaning commands, which leave the robot in Passive mode.
+	Clean(context.Context, *emptypb.Empty) (*emptypb.Empty, error)
+	Max(context.Context, *emptypb.Empty) (*emptypb.Empty, error)
+	Spot(context.Context, *emptypb.Empty) (*emptypb.Empty, error)
+	SeekDock(context.Context, *emptypb.Empty) (*emptypb.Empty, error)
+	Power(context.Context, *emptypb.Empty) (*emptypb.Empty, error)
+	// Drive commands.
+	Drive(context.Context, *DriveRequest) (*emptypb.Empty, error)
+	DriveTwist(context.Context, *DriveTwistRequest) (*emptypb.Empty, error)
+	DirectDrive(context.Context, *WheelsRequest) (*emptypb.Empty, error)
+	DrivePWM(context.Context, *WheelsRequest) (*emptypb.Empty, error)
+	Stop(context.Context, *emptypb.Empty) (*emptypb.Empty, error)
+	Motors(context.Context, *MotorsRequest) (*emptypb.Empty, error)
+	LEDs(context.Context, *LEDsRequest) (*emptypb.Empty, error)
+	Song(context.Context, *SongRequest) (*emptypb.Empty, error)
+	Play(context.Context, *PlayRequest) (*emptypb.Empty, error)
+	// QuerySensors returns the current value of the requested packets. It fails
+	// with FAILED_PRECONDITION while the sensors are streamed.
+	QuerySensors(context.Context, *SensorsRequest) (*SensorsResponse, error)
+	// StreamSensors sends the requested packets every 15 ms, until the call is
+	// cancelled. Only one stream runs at a time, others fail with
+	// FAILED_PRECONDITION.
+	StreamSensors(*SensorsRequest, grpc.ServerStreamingServer[SensorsResponse]) error
+	mustEmbedUnimplementedRoombaServer()
+}
+
+// UnimplementedRoombaServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedRoombaServer struct{}
+
+func (UnimplementedRoombaServer) Start(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Start not implemented")
+}
+func (UnimplementedRoombaServer) Passive(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Passive not implemented")
+}
+func (UnimplementedRoombaServer) Safe(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Safe not implemented")
+}
+func (UnimplementedRoombaServer) Full(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Full not implemented")
+}
+func (UnimplementedRoombaServer) Control(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Control not implemented")
+}
+func (UnimplementedRoombaServer) Clean(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Clean not implemented")
+}
+func (UnimplementedRoombaServer) Max(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Max not implemented")
+}
+func (UnimplementedRoombaServer) Spot(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Spot not implemented")
+}
+func (UnimplementedRoombaServer) SeekDock(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SeekDock not implemented")
+}
+func (UnimplementedRoombaServer) Power(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Power not implemented")
+}
+func (UnimplementedRoombaServer) Drive(context.Context, *DriveRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Drive not implemented")
+}
+func (UnimplementedRoombaServer) DriveTwist(context.Context, *DriveTwistRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DriveTwist not implemented")
+}
+func (UnimplementedRoombaServer) DirectDrive(context.Context, *WheelsRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DirectDrive not implemented")
+}
+func (UnimplementedRoombaServer) DrivePWM(context.Context, *WheelsRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DrivePWM not implemented")
+}
+func (UnimplementedRoombaServer) Stop(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Stop not implemented")
+}
+func (UnimplementedRoombaServer) Motors(context.Context, *MotorsRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Motors not implemented")
+}
+func (UnimplementedRoombaServer) LEDs(context.Context, *LEDsRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LEDs not implemented")
+}
+func (UnimplementedRoombaServer) Song(context.Context, *SongRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Song not implemented")
+}
+func (UnimplementedRoombaServer) Play(context.Context, *PlayRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Play not implemented")
+}
+func (UnimplementedRoombaServer) QuerySensors(context.Context, *SensorsRequest) (*SensorsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method QuerySensors not implemented")
+}
+func (UnimplementedRoombaServer) StreamSensors(*SensorsRequest, grpc.ServerStreamingServer[SensorsResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamSensors not implemented")
+}
+func (UnimplementedRoombaServer) mustEmbedUnimplementedRoombaServer() {}
+func (UnimplementedRoombaServer) testEmbeddedByValue()                {}
+
+// UnsafeRoombaServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RoombaServer will
+// result in compilation errors.
+type UnsafeRoombaServer interface {
+	mustEmbedUnimplementedRoombaServer()
+}
+
+func RegisterRoombaServer(s grpc.ServiceRegistrar, srv RoombaServer) {
+	// If the following call pancis, it indicates UnimplementedRoombaServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Roomba_ServiceDesc, srv)
+}
+
+func _Roomba_Start_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RoombaServer).Start(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Roomba_Start_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RoombaServer).Start(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Roomba_Passive_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RoombaServer).Passive(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Roomba_Passive_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RoombaServer).Passive(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Roomba_Safe_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RoombaServer).Safe(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Roomba_Safe_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RoombaServer).Safe(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Roomba_Full_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RoombaServer).Full(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Roomba_Full_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RoombaServer).Full(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Roomba_Control_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RoombaServer).Control(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Roomba_Control_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RoombaServer).Control(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Roomba_Clean_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RoombaServer).Clean(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Roomba_Clean_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RoombaServer).Clean(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Roomba_Max_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RoombaServer).Max(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Roomba_Max_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RoombaServer).Max(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Roomba_Spot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RoombaServer).Spot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Roomba_Spot_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RoombaServer).Spot(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Roomba_SeekDock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RoombaServer).SeekDock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Roomba_SeekDock_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RoombaServer).SeekDock(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Roomba_Power_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RoombaServer).Power(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Roomba_Power_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RoombaServer).Power(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Roomba_Drive_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DriveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RoombaServer).Drive(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Roomba_Drive_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RoombaServer).Drive(ctx, req.(*DriveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Roomba_DriveTwist_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DriveTwistRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RoombaServer).DriveTwist(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Roomba_DriveTwist_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RoombaServer).DriveTwist(ctx, req.(*DriveTwistRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Roomba_DirectDrive_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WheelsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RoombaServer).DirectDrive(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Roomba_DirectDrive_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RoombaServer).DirectDrive(ctx, req.(*WheelsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Roomba_DrivePWM_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WheelsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RoombaServer).DrivePWM(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Roomba_DrivePWM_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RoombaServer).DrivePWM(ctx, req.(*WheelsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Roomba_Stop_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RoombaServer).Stop(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Roomba_Stop_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RoombaServer).Stop(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Roomba_Motors_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MotorsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RoombaServer).Motors(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Roomba_Motors_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RoombaServer).Motors(ctx, req.(*MotorsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Roomba_LEDs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LEDsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RoombaServer).LEDs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Roomba_LEDs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RoombaServer).LEDs(ctx, req.(*LEDsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Roomba_Song_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SongRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RoombaServer).Song(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Roomba_Song_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RoombaServer).Song(ctx, req.(*SongRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Roomba_Play_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PlayRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RoombaServer).Play(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Roomba_Play_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RoombaServer).Play(ctx, req.(*PlayRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Roomba_QuerySensors_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SensorsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RoombaServer).QuerySensors(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Roomba_QuerySensors_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RoombaServer).QuerySensors(ctx, req.(*SensorsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Roomba_StreamSensors_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SensorsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RoombaServer).StreamSensors(m, &grpc.GenericServerStream[SensorsRequest, SensorsResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Roomba_StreamSensorsServer = grpc.ServerStreamingServer[SensorsResponse]
+
+// Roomba_ServiceDesc is the grpc.ServiceDesc for Roomba service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Roomba_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "roomba.Roomba",
+	HandlerType: (*RoombaServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Start",
+			Handler:    _Roomba_Start_Handler,
+		},
+		{
+			MethodName: "Passive",
+			Handler:    _Roomba_Passive_Handler,
+		},
+		{
+			MethodName: "Safe",
+			Handler:    _Roomba_Safe_Handler,
+		},
+		{
+			MethodName: "Full",
+			Handler:    _Roomba_Full_Handler,
+		},
+		{
+			MethodName: "Control",
+			Handler:    _Roomba_Control_Handler,
+		},
+		{
+			MethodName: "Clean",
+			Handler:    _Roomba_Clean_Handler,
+		},
+		{
+			MethodName: "Max",
+			Handler:    _Roomba_Max_Handler,
+		},
+		{
+			MethodName: "Spot",
+			Handler:    _Roomba_Spot_Handler,
+		},
+		{
+			MethodName: "SeekDock",
+			Handler:    _Roomba_SeekDock_Handler,
+		},
+		{
+			MethodName: "Power",
+			Handler:    _Roomba_Power_Handler,
+		},
+		{
+			MethodName: "Drive",
+			Handler:    _Roomba_Drive_Handler,
+		},
+		{
+			MethodName: "DriveTwist",
+			Handler:    _Roomba_DriveTwist_Handler,
+		},
+		{
+			MethodName: "DirectDrive",
+			Handler:    _Roomba_DirectDrive_Handler,
+		},
+		{
+			MethodName: "DrivePWM",
+			Handler:    _Roomba_DrivePWM_Handler,
+		},
+		{
+			MethodName: "Stop",
+			Handler:    _Roomba_Stop_Handler,
+		},
+		{
+			MethodName: "Motors",
+			Handler:    _Roomba_Motors_Handler,
+		},
+		{
+			MethodName: "LEDs",
+			Handler:    _Roomba_LEDs_Handler,
+		},
+		{
+			MethodName: "Song",
+			Handler:    _Roomba_Song_Handler,
+		},
+		{
+			MethodName: "Play",
+			Handler:    _Roomba_Play_Handler,
+		},
+		{
+			MethodName: "QuerySensors",
+			Handler:    _Roomba_QuerySensors_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamSensors",
+			Handler:       _Roomba_StreamSensors_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "roomba.proto",
+}
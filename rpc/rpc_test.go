@@ -0,0 +1,238 @@
+package rpc_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/xa4a/go-roomba"
+	"github.com/xa4a/go-roomba/constants"
+	"github.com/xa4a/go-roomba/rpc"
+	"github.com/xa4a/go-roomba/rpc/roombapb"
+	rt "github.com/xa4a/go-roomba/testing"
+)
+
+// serve serves the robot r on an in-memory connection and returns a
+// connection to it.
+func serve(t *testing.T, r roomba.Robot) *grpc.ClientConn {
+	lis := bufconn.Listen(1 << 16)
+	s := grpc.NewServer()
+	roombapb.RegisterRoombaServer(s, rpc.MakeServer(r))
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("failed to connect: %s", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestCommands(t *testing.T) {
+	h := rt.NewHarness(t)
+	var r roomba.Robot = rpc.MakeClient(serve(t, h.Roomba))
+	for _, test := range []struct {
+		run  func() error
+		want rt.Command
+	}{
+		{r.Start, rt.Start()},
+		{r.Full, rt.Full()},
+		{r.Safe, rt.Safe()},
+		{func() error { return r.Drive(-200, 500) }, rt.Drive(-200, 500)},
+		{func() error { return r.DriveStraight(100) }, rt.Drive(100, constants.DRIVE_STRAIGHT)},
+		{func() error { return r.SpinCW(50) }, rt.Drive(50, constants.DRIVE_SPIN_CW)},
+		{func() error { return r.DriveArc(100, -300) }, rt.Drive(100, -300)},
+		{func() error { return r.DriveTwist(0, 1) }, rt.Drive(149, constants.DRIVE_SPIN_CCW)},
+		{func() error { return r.DirectDrive(100, -100) }, rt.DirectDrive(100, -100)},
+		{func() error { return r.DrivePWM(-255, 255) }, rt.DrivePWM(-255, 255)},
+		{r.Stop, rt.Drive(0, 0)},
+		{func() error { return r.Motors(false, true, true) },
+			rt.Cmd("Motors", constants.MOTOR_VACUUM|constants.MOTOR_MAIN_BRUSH)},
+		{func() error { return r.LEDs(true, false, false, true, 255, 128) }, rt.Cmd("LEDs", 9, 255, 128)},
+		{func() error { return r.Song(1, []roomba.Note{{Number: 60, Duration: 32}}) }, rt.Cmd("Song", 1, 1, 60, 32)},
+		{func() error { return r.Play(1) }, rt.Cmd("Play", 1)},
+		{r.Clean, rt.Cmd("Clean")},
+		{r.SeekDock, rt.Cmd("Seek_dock")},
+	} {
+		if err := test.run(); err != nil {
+			t.Errorf("%s failed: %s", test.want, err)
+		}
+		h.Expect(test.want)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	h := rt.NewHarness(t)
+	conn := serve(t, h.Roomba)
+	r := rpc.MakeClient(conn)
+	c := roombapb.NewRoombaClient(conn)
+	ctx := context.Background()
+	for name, call := range map[string]func() error{
+		"pwm":         func() error { return r.DrivePWM(300, 0) },
+		"song number": func() error { return r.Song(5, []roomba.Note{{Number: 60, Duration: 32}}) },
+		"packet":      func() error { _, err := r.QueryList([]byte{200}); return err },
+		"packets": func() error {
+			_, err := r.QueryList(bytes.Repeat([]byte{constants.SENSOR_OI_MODE}, roomba.MaxPackets+1))
+			return err
+		},
+	} {
+		if err := call(); !errors.Is(err, roomba.ErrInvalidArgument) || status.Code(err) != codes.InvalidArgument {
+			t.Errorf("%s: got error %v, want roomba.ErrInvalidArgument", name, err)
+		}
+	}
+	for name, call := range map[string]func() error{
+		// Fields wider than the arguments of the commands.
+		"velocity": func() error {
+			_, err := c.Drive(ctx, &roombapb.DriveRequest{Velocity: 40000})
+			return err
+		},
+		"song 257": func() error {
+			_, err := c.Play(ctx, &roombapb.PlayRequest{SongNumber: 257})
+			return err
+		},
+	} {
+		if err := call(); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("%s: got error %v, want InvalidArgument", name, err)
+		}
+	}
+	// Checked by the client, like roomba.Roomba does.
+	if err := r.DriveArc(100, 1); !errors.Is(err, roomba.ErrInvalidArgument) {
+		t.Errorf("arc: got error %v, want roomba.ErrInvalidArgument", err)
+	}
+	// Nothing reached the robot.
+	r.Stop()
+	h.Expect(rt.Drive(0, 0))
+}
+
+// stalledRobot doesn't answer queries until released.
+type stalledRobot struct {
+	roomba.Robot
+	release chan struct{}
+}
+
+func (r stalledRobot) QueryList(packet_ids []byte) ([][]byte, error) {
+	<-r.release
+	return r.Robot.QueryList(packet_ids)
+}
+
+func TestQueryTimeout(t *testing.T) {
+	h := rt.NewHarness(t)
+	stalled := stalledRobot{h.Roomba, make(chan struct{})}
+	r := rpc.MakeClient(serve(t, stalled))
+	r.Timeout = 10 * time.Millisecond
+	if _, err := r.QueryList([]byte{constants.SENSOR_OI_MODE}); !errors.Is(err, roomba.ErrTimeout) {
+		t.Errorf("got error %v, want roomba.ErrTimeout", err)
+	}
+	close(stalled.release)
+}
+
+func TestQuerySensors(t *testing.T) {
+	h := rt.NewHarness(t)
+	conn := serve(t, h.Roomba)
+	r := rpc.MakeClient(conn)
+	mode, err := r.Sensors(constants.SENSOR_OI_MODE)
+	if err != nil {
+		t.Fatalf("error querying mode: %s", err)
+	}
+	if mode[0] != constants.OI_MODE_SAFE {
+		t.Errorf("got mode %v, want safe", mode)
+	}
+	h.Expect(rt.QueryList(constants.SENSOR_OI_MODE))
+
+	// Other languages get decoded values.
+	res, err := roombapb.NewRoombaClient(conn).QuerySensors(context.Background(),
+		&roombapb.SensorsRequest{PacketIds: []uint32{constants.SENSOR_OI_MODE, constants.SENSOR_VOLTAGE}})
+	if err != nil {
+		t.Fatalf("error querying sensors: %s", err)
+	}
+	if p := res.Packets[0]; p.Name != "oi_mode" || p.Value != constants.OI_MODE_SAFE || p.Note != "safe" {
+		t.Errorf("got packet %v", p)
+	}
+	if p := res.Packets[1]; p.Name != "voltage" || p.Unit != "mV" || len(p.Data) != 2 {
+		t.Errorf("got packet %v", p)
+	}
+}
+
+func TestStreamSensors(t *testing.T) {
+	h := rt.NewHarness(t)
+	r := rpc.MakeClient(serve(t, h.Roomba))
+	frames, err := r.Stream([]byte{constants.SENSOR_OI_MODE, constants.SENSOR_VOLTAGE})
+	if err != nil {
+		t.Fatalf("error starting stream: %s", err)
+	}
+	for i := 0; i < 3; i++ {
+		select {
+		case frame := <-frames:
+			if frame[0][0] != constants.OI_MODE_SAFE || len(frame[1]) != 2 {
+				t.Errorf("unexpected frame %v", frame)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("only received %d frames", i)
+		}
+	}
+
+	// The link is busy with the stream.
+	if _, err := r.Stream([]byte{constants.SENSOR_OI_MODE}); !errors.Is(err, roomba.ErrStreaming) {
+		t.Errorf("got error %v starting a second stream, want roomba.ErrStreaming", err)
+	}
+	if _, err := r.QueryList([]byte{constants.SENSOR_OI_MODE}); !errors.Is(err, roomba.ErrStreaming) {
+		t.Errorf("got error %v querying while streaming, want roomba.ErrStreaming", err)
+	}
+
+	r.PauseStream()
+	for range frames {
+	}
+	h.Expect(rt.Stream(constants.SENSOR_OI_MODE, constants.SENSOR_VOLTAGE), rt.ResumeStream(false))
+	// The server ends the stream soon after.
+	deadline := time.Now().Add(time.Second)
+	for {
+		_, err := r.QueryList([]byte{constants.SENSOR_OI_MODE})
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, roomba.ErrStreaming) || time.Now().After(deadline) {
+			t.Fatalf("error querying after the stream: %s", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	h.Expect(rt.QueryList(constants.SENSOR_OI_MODE))
+}
+
+// drive is written against roomba.Robot.
+func drive(r roomba.Robot) error {
+	if err := r.Safe(); err != nil {
+		return err
+	}
+	return r.DriveArc(200, 500)
+}
+
+func TestLocalAndRemote(t *testing.T) {
+	local := rt.NewHarness(t)
+	remote := rt.NewHarness(t)
+	for _, test := range []struct {
+		h *rt.Harness
+		r roomba.Robot
+	}{
+		{local, local.Roomba},
+		{remote, rpc.MakeClient(serve(t, remote.Roomba))},
+	} {
+		if err := drive(test.r); err != nil {
+			t.Errorf("drive failed: %s", err)
+		}
+		test.h.Expect(rt.Safe(), rt.Drive(200, 500))
+	}
+	if !bytes.Equal(local.Sim.Received(), remote.Sim.Received()) {
+		t.Errorf("remote robot received %v, local one %v", remote.Sim.Received(), local.Sim.Received())
+	}
+}
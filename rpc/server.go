@@ -0,0 +1,308 @@
+/*
+Package rpc serves the Roomba API over gRPC, with the service defined in
+roombapb/roomba.proto, so that a fleet backend in any language can control
+robots attached to other hosts.
+
+A Server exposes a robot on the host it is attached to:
+
+	s := grpc.NewServer()
+	roombapb.RegisterRoombaServer(s, rpc.MakeServer(r))
+	s.Serve(listener)
+
+and a Client controls it from another one. Both the Client and roomba.Roomba
+implement roomba.Robot, so code written against the interface drives local
+and remote robots alike:
+
+	conn, _ := grpc.NewClient("robot1:50051", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	var robot roomba.Robot = rpc.MakeClient(conn)
+	robot.Safe()
+	robot.DriveStraight(200)
+
+Errors of the robot's client are returned with INVALID_ARGUMENT for invalid
+arguments, FAILED_PRECONDITION for queries while streaming, DEADLINE_EXCEEDED
+for queries the robot doesn't answer, and UNAVAILABLE for failures of the link
+to the robot. The Client returns the first three as roomba.ErrInvalidArgument,
+roomba.ErrStreaming and roomba.ErrTimeout, for errors.Is to match.
+*/
+package rpc
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/xa4a/go-roomba"
+	"github.com/xa4a/go-roomba/constants"
+	"github.com/xa4a/go-roomba/oi"
+	"github.com/xa4a/go-roomba/rpc/roombapb"
+)
+
+// Server implements the Roomba gRPC service over a robot. Should be
+// constructed with MakeServer() function.
+type Server struct {
+	roombapb.UnimplementedRoombaServer
+
+	r *roomba.Serialized
+}
+
+// MakeServer creates a Server controlling the robot r, which must be started.
+func MakeServer(r roomba.Robot) *Server {
+	return &Server{r: roomba.MakeSerialized(r)}
+}
+
+// command runs a command on the robot and converts its error to a status.
+func (s *Server) command(run func(r roomba.Robot) error) (*emptypb.Empty, error) {
+	if err := run(s.r); err != nil {
+		return nil, statusError(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// statusError returns the status of an error of the robot's client.
+func statusError(err error) error {
+	switch {
+	case errors.Is(err, roomba.ErrInvalidArgument):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, roomba.ErrStreaming):
+		// The responses would be mixed up with the stream.
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, roomba.ErrTimeout):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+	return status.Error(codes.Unavailable, err.Error())
+}
+
+// toInt16 and toByte convert request fields, which are wider than the
+// arguments of the commands.
+func toInt16(name string, v int32) (int16, error) {
+	if v < math.MinInt16 || v > math.MaxInt16 {
+		return 0, status.Errorf(codes.InvalidArgument, "invalid %s: %d", name, v)
+	}
+	return int16(v), nil
+}
+
+func toByte(name string, v uint32) (byte, error) {
+	if v > math.MaxUint8 {
+		return 0, status.Errorf(codes.InvalidArgument, "invalid %s: %d", name, v)
+	}
+	return byte(v), nil
+}
+
+// toInt16Pair converts the two fields of a drive request.
+func toInt16Pair(name1 string, v1 int32, name2 string, v2 int32) (int16, int16, error) {
+	a, err := toInt16(name1, v1)
+	if err != nil {
+		return 0, 0, err
+	}
+	b, err := toInt16(name2, v2)
+	return a, b, err
+}
+
+func (s *Server) Start(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
+	return s.command(roomba.Robot.Start)
+}
+
+func (s *Server) Passive(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
+	return s.command(roomba.Robot.Passive)
+}
+
+func (s *Server) Safe(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
+	return s.command(roomba.Robot.Safe)
+}
+
+func (s *Server) Full(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
+	return s.command(roomba.Robot.Full)
+}
+
+func (s *Server) Control(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
+	return s.command(roomba.Robot.Control)
+}
+
+func (s *Server) Clean(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
+	return s.command(roomba.Robot.Clean)
+}
+
+func (s *Server) Max(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
+	return s.command(roomba.Robot.Max)
+}
+
+func (s *Server) Spot(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
+	return s.command(roomba.Robot.Spot)
+}
+
+func (s *Server) SeekDock(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
+	return s.command(roomba.Robot.SeekDock)
+}
+
+func (s *Server) Power(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
+	return s.command(roomba.Robot.Power)
+}
+
+func (s *Server) Drive(_ context.Context, req *roombapb.DriveRequest) (*emptypb.Empty, error) {
+	velocity, radius, err := toInt16Pair("velocity", req.Velocity, "radius", req.Radius)
+	if err != nil {
+		return nil, err
+	}
+	return s.command(func(r roomba.Robot) error { return r.Drive(velocity, radius) })
+}
+
+func (s *Server) DriveTwist(_ context.Context, req *roombapb.DriveTwistRequest) (*emptypb.Empty, error) {
+	if math.IsNaN(req.Linear) || math.IsNaN(req.Angular) {
+		return nil, status.Error(codes.InvalidArgument, "invalid twist: NaN")
+	}
+	return s.command(func(r roomba.Robot) error { return r.DriveTwist(req.Linear, req.Angular) })
+}
+
+func (s *Server) DirectDrive(_ context.Context, req *roombapb.WheelsRequest) (*emptypb.Empty, error) {
+	right, left, err := toInt16Pair("velocity", req.Right, "velocity", req.Left)
+	if err != nil {
+		return nil, err
+	}
+	return s.command(func(r roomba.Robot) error { return r.DirectDrive(right, left) })
+}
+
+func (s *Server) DrivePWM(_ context.Context, req *roombapb.WheelsRequest) (*emptypb.Empty, error) {
+	right, left, err := toInt16Pair("pwm", req.Right, "pwm", req.Left)
+	if err != nil {
+		return nil, err
+	}
+	return s.command(func(r roomba.Robot) error { return r.DrivePWM(right, left) })
+}
+
+func (s *Server) Stop(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
+	return s.command(roomba.Robot.Stop)
+}
+
+func (s *Server) Motors(_ context.Context, req *roombapb.MotorsRequest) (*emptypb.Empty, error) {
+	return s.command(func(r roomba.Robot) error { return r.Motors(req.SideBrush, req.Vacuum, req.MainBrush) })
+}
+
+func (s *Server) LEDs(_ context.Context, req *roombapb.LEDsRequest) (*emptypb.Empty, error) {
+	color, err := toByte("power color", req.PowerColor)
+	if err != nil {
+		return nil, err
+	}
+	intensity, err := toByte("power intensity", req.PowerIntensity)
+	if err != nil {
+		return nil, err
+	}
+	return s.command(func(r roomba.Robot) error {
+		return r.LEDs(req.CheckRobot, req.Dock, req.Spot, req.Debris, color, intensity)
+	})
+}
+
+func (s *Server) Song(_ context.Context, req *roombapb.SongRequest) (*emptypb.Empty, error) {
+	number, err := toByte("song number", req.SongNumber)
+	if err != nil {
+		return nil, err
+	}
+	notes := make([]roomba.Note, len(req.Notes))
+	for i, n := range req.Notes {
+		if notes[i].Number, err = toByte("note number", n.Number); err != nil {
+			return nil, err
+		}
+		if notes[i].Duration, err = toByte("note duration", n.Duration); err != nil {
+			return nil, err
+		}
+	}
+	return s.command(func(r roomba.Robot) error { return r.Song(number, notes) })
+}
+
+func (s *Server) Play(_ context.Context, req *roombapb.PlayRequest) (*emptypb.Empty, error) {
+	number, err := toByte("song number", req.SongNumber)
+	if err != nil {
+		return nil, err
+	}
+	return s.command(func(r roomba.Robot) error { return r.Play(number) })
+}
+
+// packetIds validates the packets of a request, at most roomba.MaxPackets.
+func packetIds(req *roombapb.SensorsRequest) ([]byte, error) {
+	if len(req.PacketIds) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "invalid packets: none requested")
+	}
+	if len(req.PacketIds) > roomba.MaxPackets {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid packets: %d requested, at most %d",
+			len(req.PacketIds), roomba.MaxPackets)
+	}
+	ids := make([]byte, len(req.PacketIds))
+	for i, id := range req.PacketIds {
+		if _, ok := constants.SENSOR_PACKET_LENGTH[byte(id)]; !ok || id > math.MaxUint8 {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid packet id: %d", id)
+		}
+		ids[i] = byte(id)
+	}
+	return ids, nil
+}
+
+// response converts the data of the packets of a query or stream frame.
+func response(ids []byte, data [][]byte) *roombapb.SensorsResponse {
+	res := &roombapb.SensorsResponse{Packets: make([]*roombapb.Packet, len(ids))}
+	for i, id := range ids {
+		p := oi.Packet{ID: id, Data: data[i]}
+		packet := &roombapb.Packet{Id: uint32(id), Data: data[i]}
+		if p.Known() {
+			packet.Name, packet.Value, packet.Unit, packet.Note = p.Name(), int32(p.Value()), p.Unit(), p.Note()
+		}
+		res.Packets[i] = packet
+	}
+	return res
+}
+
+func (s *Server) QuerySensors(_ context.Context, req *roombapb.SensorsRequest) (*roombapb.SensorsResponse, error) {
+	ids, err := packetIds(req)
+	if err != nil {
+		return nil, err
+	}
+	data, err := s.r.QueryList(ids)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return response(ids, data), nil
+}
+
+func (s *Server) StreamSensors(req *roombapb.SensorsRequest, stream grpc.ServerStreamingServer[roombapb.SensorsResponse]) error {
+	ids, err := packetIds(req)
+	if err != nil {
+		return err
+	}
+	frames, err := s.r.Stream(ids)
+	if err != nil {
+		return statusError(err)
+	}
+
+	// Closed is whether the client of the robot ended the stream.
+	closed := false
+	defer func() {
+		if !closed {
+			s.r.PauseStream()
+			for range frames {
+			}
+		}
+	}()
+
+	// Lets the client know the stream started.
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case frame, ok := <-frames:
+			if !ok {
+				closed = true
+				return status.Error(codes.Unavailable, "stream ended")
+			}
+			if err := stream.Send(response(ids, frame)); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,377 @@
+/*
+Package safety implements a software safety governor for Roomba.
+
+In Full mode the robot ignores its cliff, wheel-drop and charger safety
+features. A Governor restores some of that protection in software: it
+watches the hazard sensors in the sensor stream and, when one triggers,
+stops the robot and refuses drive commands until the hazard clears, but for
+backing away from a bump.
+
+Typical use:
+
+	g := safety.MakeGovernor(r, safety.DefaultConfig)
+	frames, _ := r.Stream(safety.Packets)
+	go g.Watch(frames)
+	r.Full()
+	g.Drive(200, constants.DRIVE_STRAIGHT) // Instead of r.Drive.
+*/
+package safety
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/xa4a/go-roomba/clock"
+	"github.com/xa4a/go-roomba/constants"
+)
+
+// Robot is the part of the roomba.Roomba API used by the Governor.
+type Robot interface {
+	Drive(velocity, radius int16) error
+	DirectDrive(right, left int16) error
+	DrivePWM(right, left int16) error
+	Safe() error
+}
+
+// Packets is the list of sensor packets the Governor watches, in the order
+// expected by Watch and Check.
+var Packets = []byte{
+	constants.SENSOR_BUMP_WHEELS_DROPS,
+	constants.SENSOR_CLIFF_LEFT,
+	constants.SENSOR_CLIFF_FRONT_LEFT,
+	constants.SENSOR_CLIFF_FRONT_RIGHT,
+	constants.SENSOR_CLIFF_RIGHT,
+	constants.SENSOR_WHEEL_OVERCURRENT,
+}
+
+// Bits of the SENSOR_BUMP_WHEELS_DROPS packet.
+const (
+	bumpRight      = 1 << 0
+	bumpLeft       = 1 << 1
+	wheelDropRight = 1 << 2
+	wheelDropLeft  = 1 << 3
+)
+
+// Hazard is a condition the Governor reacts to.
+type Hazard int
+
+const (
+	HazardBump Hazard = iota
+	HazardCliff
+	HazardWheelDrop
+	HazardOvercurrent
+)
+
+func (h Hazard) String() string {
+	switch h {
+	case HazardBump:
+		return "bump"
+	case HazardCliff:
+		return "cliff"
+	case HazardWheelDrop:
+		return "wheel drop"
+	case HazardOvercurrent:
+		return "overcurrent"
+	}
+	return fmt.Sprintf("Hazard(%d)", int(h))
+}
+
+// Policy is the reaction to a hazard. Drive commands are refused while any
+// hazard is present, whatever the policy, but for reversing away from a bump,
+// so that a robot held against a wall or the dock can be backed off.
+type Policy int
+
+const (
+	// PolicyStop stops the wheels.
+	PolicyStop Policy = iota
+	// PolicyBackOff stops, then reverses for Config.BackOffDuration.
+	PolicyBackOff
+	// PolicySafe stops and switches the robot to Safe mode, handing
+	// further protection over to the robot itself.
+	PolicySafe
+)
+
+func (p Policy) String() string {
+	switch p {
+	case PolicyStop:
+		return "stop"
+	case PolicyBackOff:
+		return "back off"
+	case PolicySafe:
+		return "safe"
+	}
+	return fmt.Sprintf("Policy(%d)", int(p))
+}
+
+// Config configures a Governor.
+type Config struct {
+	// Policies maps hazards to policies. Hazards not in the map are
+	// ignored.
+	Policies map[Hazard]Policy
+
+	BackOffVelocity int16 // mm/s, positive.
+	BackOffDuration time.Duration
+
+	// OnEvent, if not nil, is called for every event in addition to
+	// recording it in the event log.
+	OnEvent func(Event)
+
+	// Clock times events and back-offs, nil for the wall clock.
+	Clock clock.Clock
+}
+
+// DefaultConfig stops on bumps and overcurrent, backs off from cliffs and
+// hands control to the robot's own safety features on wheel drop.
+var DefaultConfig = Config{
+	Policies: map[Hazard]Policy{
+		HazardBump:        PolicyStop,
+		HazardCliff:       PolicyBackOff,
+		HazardWheelDrop:   PolicySafe,
+		HazardOvercurrent: PolicyStop,
+	},
+	BackOffVelocity: 100,
+	BackOffDuration: 500 * time.Millisecond,
+}
+
+// Event is an entry of the Governor's event log.
+type Event struct {
+	Time    time.Time
+	Hazard  Hazard
+	Policy  Policy
+	Cleared bool // Set when the hazard went away.
+}
+
+func (e Event) String() string {
+	if e.Cleared {
+		return fmt.Sprintf("%s %s cleared", e.Time.Format(time.RFC3339Nano), e.Hazard)
+	}
+	return fmt.Sprintf("%s %s: %s", e.Time.Format(time.RFC3339Nano), e.Hazard, e.Policy)
+}
+
+// MaxEvents is the number of most recent events kept in the event log.
+const MaxEvents = 1000
+
+// ErrHazard is returned for drive commands refused because of a hazard.
+var ErrHazard = errors.New("drive command refused: hazard detected")
+
+// Governor guards drive commands sent to a Robot. Should be constructed with
+// MakeGovernor() function.
+type Governor struct {
+	r      Robot
+	config Config
+
+	mu        sync.Mutex
+	active    map[Hazard]bool
+	backingUp bool
+	backOffs  int // Back-offs started or cancelled, to ignore stale timers.
+	events    []Event
+}
+
+// MakeGovernor creates a Governor for the given robot.
+func MakeGovernor(r Robot, config Config) *Governor {
+	config.Clock = clock.OrReal(config.Clock)
+	return &Governor{r: r, config: config, active: map[Hazard]bool{}}
+}
+
+// Watch checks stream frames until the channel is closed. The stream must
+// have been started with Packets.
+func (g *Governor) Watch(frames <-chan [][]byte) error {
+	for frame := range frames {
+		if err := g.Check(frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Check inspects the values of Packets and reacts to hazards that appeared
+// since the previous check.
+func (g *Governor) Check(packets [][]byte) error {
+	if len(packets) != len(Packets) {
+		return fmt.Errorf("expected %d safety packets, got %d",
+			len(Packets), len(packets))
+	}
+	for _, p := range packets {
+		if len(p) != 1 {
+			return fmt.Errorf("invalid safety packet: %v", p)
+		}
+	}
+	bumps, overcurrent := packets[0][0], packets[5][0]
+	present := map[Hazard]bool{
+		HazardBump:        bumps&(bumpLeft|bumpRight) != 0,
+		HazardWheelDrop:   bumps&(wheelDropLeft|wheelDropRight) != 0,
+		HazardCliff:       packets[1][0]|packets[2][0]|packets[3][0]|packets[4][0] != 0,
+		HazardOvercurrent: overcurrent&(constants.OVERCURRENT_LEFT_WHEEL|constants.OVERCURRENT_RIGHT_WHEEL) != 0,
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var err error
+	for _, h := range []Hazard{HazardWheelDrop, HazardCliff, HazardOvercurrent, HazardBump} {
+		policy, watched := g.config.Policies[h]
+		if !watched || present[h] == g.active[h] {
+			continue
+		}
+		g.active[h] = present[h]
+		if !present[h] {
+			g.log(Event{Time: g.config.Clock.Now(), Hazard: h, Policy: policy, Cleared: true})
+			continue
+		}
+		g.log(Event{Time: g.config.Clock.Now(), Hazard: h, Policy: policy})
+		if e := g.react(policy); err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// react must be called with g.mu held. A back-off under way already moves the
+// robot away from the hazard, and is only interrupted by PolicySafe.
+func (g *Governor) react(p Policy) error {
+	if g.backingUp && p != PolicySafe {
+		return nil
+	}
+	g.cancelBackOff()
+	if err := g.r.DirectDrive(0, 0); err != nil {
+		return err
+	}
+	switch p {
+	case PolicySafe:
+		return g.r.Safe()
+	case PolicyBackOff:
+		v := -g.config.BackOffVelocity
+		if err := g.r.DirectDrive(v, v); err != nil {
+			return err
+		}
+		g.backingUp = true
+		n := g.backOffs
+		g.config.Clock.AfterFunc(g.config.BackOffDuration, func() { g.endBackOff(n) })
+	}
+	return nil
+}
+
+// cancelBackOff must be called with g.mu held.
+func (g *Governor) cancelBackOff() {
+	g.backingUp = false
+	g.backOffs++
+}
+
+// endBackOff stops the n-th back-off, unless it was cancelled.
+func (g *Governor) endBackOff(n int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if n != g.backOffs {
+		return
+	}
+	g.cancelBackOff()
+	g.r.DirectDrive(0, 0)
+}
+
+// log must be called with g.mu held.
+func (g *Governor) log(e Event) {
+	if len(g.events) == MaxEvents {
+		g.events = append(g.events[:0], g.events[1:]...)
+	}
+	g.events = append(g.events, e)
+	if g.config.OnEvent != nil {
+		g.config.OnEvent(e)
+	}
+}
+
+// Events returns a copy of the event log, the last MaxEvents events.
+func (g *Governor) Events() []Event {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]Event(nil), g.events...)
+}
+
+// Tripped reports whether drive commands are currently refused, but for
+// reversing away from a bump.
+func (g *Governor) Tripped() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.tripped()
+}
+
+// tripped must be called with g.mu held.
+func (g *Governor) tripped() bool {
+	if g.backingUp {
+		return true
+	}
+	for _, a := range g.active {
+		if a {
+			return true
+		}
+	}
+	return false
+}
+
+// guard runs a command moving the wheels at the given velocities unless a
+// hazard is present. Commands that stop the robot are always let through,
+// and cancel a back-off under way. While bumps are the only hazard, commands
+// reversing both wheels are let through too, as the bumper is in front.
+func (g *Governor) guard(right, left float64, cmd func() error) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	stopping := right == 0 && left == 0
+	if stopping {
+		g.cancelBackOff()
+		return cmd()
+	}
+	if g.backingUp {
+		return ErrHazard
+	}
+	reversing := right <= 0 && left <= 0
+	for h, a := range g.active {
+		if a && !(h == HazardBump && reversing) {
+			return ErrHazard
+		}
+	}
+	return cmd()
+}
+
+// driveWheels returns the wheel velocities of a Drive command.
+func driveWheels(velocity, radius int16) (right, left float64) {
+	v := float64(velocity)
+	switch {
+	case velocity == 0:
+		return 0, 0
+	case radius == constants.DRIVE_STRAIGHT || radius == -32768:
+		return v, v
+	case radius == constants.DRIVE_SPIN_CCW:
+		return v, -v
+	case radius == constants.DRIVE_SPIN_CW:
+		return -v, v
+	}
+	r := float64(radius)
+	return v * (r + constants.WHEEL_SEPARATION/2) / r, v * (r - constants.WHEEL_SEPARATION/2) / r
+}
+
+// Drive sends a Drive command unless a hazard is present.
+func (g *Governor) Drive(velocity, radius int16) error {
+	right, left := driveWheels(velocity, radius)
+	return g.guard(right, left, func() error {
+		return g.r.Drive(velocity, radius)
+	})
+}
+
+// DirectDrive sends a DirectDrive command unless a hazard is present.
+func (g *Governor) DirectDrive(right, left int16) error {
+	return g.guard(float64(right), float64(left), func() error {
+		return g.r.DirectDrive(right, left)
+	})
+}
+
+// DrivePWM sends a DrivePWM command unless a hazard is present.
+func (g *Governor) DrivePWM(right, left int16) error {
+	return g.guard(float64(right), float64(left), func() error {
+		return g.r.DrivePWM(right, left)
+	})
+}
+
+// Stop stops the robot, even while a hazard is present.
+func (g *Governor) Stop() error {
+	return g.DirectDrive(0, 0)
+}
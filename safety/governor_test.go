@@ -0,0 +1,220 @@
+package safety_test
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xa4a/go-roomba/clock"
+	"github.com/xa4a/go-roomba/safety"
+)
+
+type recordingRobot struct {
+	mu       sync.Mutex
+	commands []string
+}
+
+func (r *recordingRobot) record(format string, args ...interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commands = append(r.commands, fmt.Sprintf(format, args...))
+	return nil
+}
+
+func (r *recordingRobot) Drive(velocity, radius int16) error {
+	return r.record("Drive(%d, %d)", velocity, radius)
+}
+
+func (r *recordingRobot) DirectDrive(right, left int16) error {
+	return r.record("DirectDrive(%d, %d)", right, left)
+}
+
+func (r *recordingRobot) DrivePWM(right, left int16) error {
+	return r.record("DrivePWM(%d, %d)", right, left)
+}
+
+func (r *recordingRobot) Safe() error {
+	return r.record("Safe()")
+}
+
+func (r *recordingRobot) take() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c := r.commands
+	r.commands = nil
+	return c
+}
+
+// frame builds a frame of safety.Packets values.
+func frame(bumps, cliff, overcurrent byte) [][]byte {
+	return [][]byte{{bumps}, {cliff}, {0}, {0}, {0}, {overcurrent}}
+}
+
+func TestBumpStopsAndRefusesCommands(t *testing.T) {
+	r := &recordingRobot{}
+	g := safety.MakeGovernor(r, safety.DefaultConfig)
+
+	if err := g.Drive(200, 500); err != nil {
+		t.Fatalf("drive refused without hazard: %s", err)
+	}
+	g.Check(frame(1, 0, 0))
+	if err := g.DirectDrive(100, 100); err != safety.ErrHazard {
+		t.Errorf("expected ErrHazard, got %v", err)
+	}
+	if err := g.Stop(); err != nil {
+		t.Errorf("stop refused: %s", err)
+	}
+	g.Check(frame(0, 0, 0))
+	if err := g.DrivePWM(100, 100); err != nil {
+		t.Errorf("drive refused after hazard cleared: %s", err)
+	}
+
+	expected := []string{"Drive(200, 500)", "DirectDrive(0, 0)",
+		"DirectDrive(0, 0)", "DrivePWM(100, 100)"}
+	if got := r.take(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected commands %v, got %v", expected, got)
+	}
+	events := g.Events()
+	if len(events) != 2 || events[0].Hazard != safety.HazardBump ||
+		events[0].Cleared || !events[1].Cleared {
+		t.Errorf("unexpected events: %v", events)
+	}
+}
+
+func TestBumpLetsReversingThrough(t *testing.T) {
+	r := &recordingRobot{}
+	g := safety.MakeGovernor(r, safety.DefaultConfig)
+	g.Check(frame(1, 0, 0))
+	r.take()
+
+	// Backing away from the wall.
+	for _, drive := range []func() error{
+		func() error { return g.Drive(-100, 32767) },
+		func() error { return g.Drive(-100, 500) },
+		func() error { return g.DirectDrive(-100, 0) },
+	} {
+		if err := drive(); err != nil {
+			t.Errorf("reversing refused on a bump: %s", err)
+		}
+	}
+	// Anything moving a wheel forward.
+	for _, drive := range []func() error{
+		func() error { return g.Drive(100, 32767) },
+		func() error { return g.Drive(-100, 1) },
+		func() error { return g.Drive(-100, 50) },
+		func() error { return g.DrivePWM(-100, 10) },
+	} {
+		if err := drive(); err != safety.ErrHazard {
+			t.Errorf("expected ErrHazard, got %v", err)
+		}
+	}
+	expected := []string{"Drive(-100, 32767)", "Drive(-100, 500)", "DirectDrive(-100, 0)"}
+	if got := r.take(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected commands %v, got %v", expected, got)
+	}
+
+	// Other hazards refuse reversing.
+	g.Check(frame(1|4, 0, 0))
+	if err := g.Drive(-100, 32767); err != safety.ErrHazard {
+		t.Errorf("expected ErrHazard on wheel drop, got %v", err)
+	}
+}
+
+func TestEventLogIsCapped(t *testing.T) {
+	r := &recordingRobot{}
+	g := safety.MakeGovernor(r, safety.DefaultConfig)
+	for i := 0; i < safety.MaxEvents; i++ {
+		g.Check(frame(1, 0, 0))
+		g.Check(frame(0, 0, 0))
+	}
+	events := g.Events()
+	if len(events) != safety.MaxEvents {
+		t.Fatalf("got %d events, want %d", len(events), safety.MaxEvents)
+	}
+	if last := events[len(events)-1]; !last.Cleared {
+		t.Errorf("the last event %s was dropped", last)
+	}
+}
+
+func TestCliffBacksOff(t *testing.T) {
+	r := &recordingRobot{}
+	config := safety.DefaultConfig
+	config.BackOffDuration = 10 * time.Millisecond
+	g := safety.MakeGovernor(r, config)
+
+	g.Check(frame(0, 1, 0))
+	g.Check(frame(0, 0, 0))
+	if !g.Tripped() {
+		t.Errorf("governor not tripped while backing off")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if g.Tripped() {
+		t.Errorf("governor still tripped after backing off")
+	}
+	expected := []string{"DirectDrive(0, 0)", "DirectDrive(-100, -100)", "DirectDrive(0, 0)"}
+	if got := r.take(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected commands %v, got %v", expected, got)
+	}
+}
+
+func TestWheelDropSwitchesToSafe(t *testing.T) {
+	r := &recordingRobot{}
+	g := safety.MakeGovernor(r, safety.DefaultConfig)
+	g.Check(frame(4, 0, 0))
+	expected := []string{"DirectDrive(0, 0)", "Safe()"}
+	if got := r.take(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected commands %v, got %v", expected, got)
+	}
+}
+
+func TestCliffAndBumpBackOff(t *testing.T) {
+	r := &recordingRobot{}
+	clk := clock.MakeVirtual(time.Unix(0, 0))
+	config := safety.DefaultConfig
+	config.Clock = clk
+	g := safety.MakeGovernor(r, config)
+
+	// The bump doesn't stop the back-off from the cliff.
+	g.Check(frame(1, 1, 0))
+	expected := []string{"DirectDrive(0, 0)", "DirectDrive(-100, -100)"}
+	if got := r.take(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected commands %v, got %v", expected, got)
+	}
+	clk.Advance(config.BackOffDuration)
+	g.Check(frame(0, 0, 0))
+	if g.Tripped() {
+		t.Errorf("governor still tripped after backing off")
+	}
+	if err := g.Drive(200, 500); err != nil {
+		t.Errorf("drive refused after backing off: %s", err)
+	}
+	expected = []string{"DirectDrive(0, 0)", "Drive(200, 500)"}
+	if got := r.take(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected commands %v, got %v", expected, got)
+	}
+}
+
+func TestStopCancelsBackOff(t *testing.T) {
+	r := &recordingRobot{}
+	clk := clock.MakeVirtual(time.Unix(0, 0))
+	config := safety.DefaultConfig
+	config.Clock = clk
+	g := safety.MakeGovernor(r, config)
+
+	g.Check(frame(0, 1, 0))
+	if err := g.Stop(); err != nil {
+		t.Errorf("stop refused while backing off: %s", err)
+	}
+	g.Check(frame(0, 0, 0))
+	if err := g.Drive(100, 500); err != nil {
+		t.Errorf("drive refused after the back-off was cancelled: %s", err)
+	}
+	// The cancelled back-off doesn't stop the robot when it times out.
+	clk.Advance(config.BackOffDuration)
+	expected := []string{"DirectDrive(0, 0)", "DirectDrive(-100, -100)", "DirectDrive(0, 0)", "Drive(100, 500)"}
+	if got := r.take(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected commands %v, got %v", expected, got)
+	}
+}
@@ -5,11 +5,12 @@ package roomba
 import (
 	"bytes"
 	"encoding/binary"
-	"errors"
 	"fmt"
 	"log"
 
 	"github.com/tarm/goserial"
+
+	"github.com/xa4a/go-roomba/oi"
 )
 
 // Packs the given data as big endian bytes.
@@ -27,32 +28,32 @@ func Pack(data []interface{}) []byte {
 // Configures and opens the given serial port.
 func (this *Roomba) Open(baud uint) error {
 	if baud != 115200 && baud != 19200 {
-		return errors.New(fmt.Sprintf("invalid baud rate: %d. Must be one of 115200, 19200", baud))
+		return fmt.Errorf("%w: baud rate %d. Must be one of 115200, 19200", ErrInvalidArgument, baud)
 	}
 
 	c := &serial.Config{Name: this.PortName, Baud: int(baud)}
 	port, err := serial.OpenPort(c)
 
 	if err != nil {
-		log.Printf("failed to open serial port: %s", this.PortName)
+		this.logger().Error("failed to open serial port", "port", this.PortName, "err", err)
 		return err
 	}
 	this.S = port
-	log.Printf("opened serial port: %s", this.PortName)
+	this.logger().Info("opened serial port", "port", this.PortName, "baud", baud)
 	return nil
 }
 
-// Writes the given opcode byte and a sequence of data bytes to the serial port.
+// Writes the given opcode byte and a sequence of data bytes to the serial port,
+// in a single write so that commands sent concurrently don't interleave.
 func (this *Roomba) Write(opcode byte, p []byte) error {
-	log.Printf("Writing opcode: %v, data %v", opcode, p)
-	n, err := this.S.Write([]byte{opcode})
-	if n != 1 || err != nil {
-		return fmt.Errorf("failed writing opcode %d to serial interface",
-			opcode)
-	}
-	n, err = this.S.Write(p)
-	if n != len(p) || err != nil {
-		return fmt.Errorf("failed writing command to serial interface: % d", p)
+	this.logger().Debug("write", LogDirection, HostToRobot, LogOpcode, opcode,
+		"command", oi.Name(opcode), "data", p)
+	command := append([]byte{opcode}, p...)
+	this.writeMu.Lock()
+	defer this.writeMu.Unlock()
+	n, err := this.S.Write(command)
+	if n != len(command) || err != nil {
+		return fmt.Errorf("failed writing command to serial interface: % d", command)
 	}
 	return nil
 }
@@ -64,5 +65,9 @@ func (this *Roomba) WriteByte(opcode byte) error {
 
 // Reads bytes from the serial port.
 func (this *Roomba) Read(p []byte) (n int, err error) {
-	return this.S.Read(p)
+	n, err = this.S.Read(p)
+	if n > 0 {
+		this.logger().Debug("read", LogDirection, RobotToHost, "data", p[:n])
+	}
+	return n, err
 }
@@ -0,0 +1,251 @@
+package roomba
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/xa4a/go-roomba/clock"
+)
+
+// DefaultQueryTimeout is the default Serialized.QueryTimeout.
+const DefaultQueryTimeout = time.Second
+
+// ErrTimeout is returned by the queries of a Serialized robot which didn't
+// complete within its QueryTimeout.
+var ErrTimeout = errors.New("timed out waiting for the robot")
+
+// ErrStreaming is returned by the queries and streams of a Serialized robot
+// while it streams, as the robot's responses would be mixed up with the
+// stream.
+var ErrStreaming = errors.New("sensors are being streamed")
+
+// Serialized makes a Robot safe for concurrent use, as by servers handling
+// several clients. Should be constructed with MakeSerialized() function.
+//
+// Commands are sent one at a time. They don't wait for queries, which only
+// read the robot's responses, so that a stop goes through while a query is
+// pending. Queries and streams wait for each other, and a query that timed
+// out keeps the robot's output until its response arrives.
+type Serialized struct {
+	// QueryTimeout bounds the time Sensors and QueryList wait for other
+	// queries and for the response, and Stream waits for queries, none if
+	// zero.
+	QueryTimeout time.Duration
+
+	// Clock times QueryTimeout, the real clock if nil.
+	Clock clock.Clock
+
+	r        Robot
+	commands sync.Mutex
+	output   chan struct{} // Holds a token while a query or stream reads.
+
+	mu        sync.Mutex
+	streaming bool
+	pause     chan struct{} // Closed to pause the stream.
+}
+
+var _ Robot = (*Serialized)(nil)
+
+// MakeSerialized creates a Serialized robot controlling r.
+func MakeSerialized(r Robot) *Serialized {
+	return &Serialized{QueryTimeout: DefaultQueryTimeout, r: r, output: make(chan struct{}, 1)}
+}
+
+// command sends a command to the robot.
+func (s *Serialized) command(f func() error) error {
+	s.commands.Lock()
+	defer s.commands.Unlock()
+	return f()
+}
+
+func (s *Serialized) Start() error    { return s.command(s.r.Start) }
+func (s *Serialized) Passive() error  { return s.command(s.r.Passive) }
+func (s *Serialized) Safe() error     { return s.command(s.r.Safe) }
+func (s *Serialized) Full() error     { return s.command(s.r.Full) }
+func (s *Serialized) Control() error  { return s.command(s.r.Control) }
+func (s *Serialized) Clean() error    { return s.command(s.r.Clean) }
+func (s *Serialized) Max() error      { return s.command(s.r.Max) }
+func (s *Serialized) Spot() error     { return s.command(s.r.Spot) }
+func (s *Serialized) SeekDock() error { return s.command(s.r.SeekDock) }
+func (s *Serialized) Power() error    { return s.command(s.r.Power) }
+func (s *Serialized) Stop() error     { return s.command(s.r.Stop) }
+
+func (s *Serialized) Drive(velocity, radius int16) error {
+	return s.command(func() error { return s.r.Drive(velocity, radius) })
+}
+
+func (s *Serialized) DriveStraight(velocity int16) error {
+	return s.command(func() error { return s.r.DriveStraight(velocity) })
+}
+
+func (s *Serialized) SpinCW(velocity int16) error {
+	return s.command(func() error { return s.r.SpinCW(velocity) })
+}
+
+func (s *Serialized) SpinCCW(velocity int16) error {
+	return s.command(func() error { return s.r.SpinCCW(velocity) })
+}
+
+func (s *Serialized) DriveArc(velocity, radius int16) error {
+	return s.command(func() error { return s.r.DriveArc(velocity, radius) })
+}
+
+func (s *Serialized) DriveTwist(linear, angular float64) error {
+	return s.command(func() error { return s.r.DriveTwist(linear, angular) })
+}
+
+func (s *Serialized) DirectDrive(right, left int16) error {
+	return s.command(func() error { return s.r.DirectDrive(right, left) })
+}
+
+func (s *Serialized) DrivePWM(right, left int16) error {
+	return s.command(func() error { return s.r.DrivePWM(right, left) })
+}
+
+func (s *Serialized) Motors(side_brush, vacuum, main_brush bool) error {
+	return s.command(func() error { return s.r.Motors(side_brush, vacuum, main_brush) })
+}
+
+func (s *Serialized) LEDs(check_robot, dock, spot, debris bool, power_color, power_intensity byte) error {
+	return s.command(func() error {
+		return s.r.LEDs(check_robot, dock, spot, debris, power_color, power_intensity)
+	})
+}
+
+func (s *Serialized) Song(song_number byte, notes []Note) error {
+	return s.command(func() error { return s.r.Song(song_number, notes) })
+}
+
+func (s *Serialized) Play(song_number byte) error {
+	return s.command(func() error { return s.r.Play(song_number) })
+}
+
+// timeout returns a channel closed once QueryTimeout passed, never if it is
+// zero, and a function stopping the timer.
+func (s *Serialized) timeout() (<-chan struct{}, func()) {
+	if s.QueryTimeout == 0 {
+		return nil, func() {}
+	}
+	c := make(chan struct{})
+	t := clock.OrReal(s.Clock).AfterFunc(s.QueryTimeout, func() { close(c) })
+	return c, func() { t.Stop() }
+}
+
+// acquire waits until the robot's output is free, unless it streams.
+func (s *Serialized) acquire(timeout <-chan struct{}) error {
+	s.mu.Lock()
+	streaming := s.streaming
+	s.mu.Unlock()
+	if streaming {
+		return ErrStreaming
+	}
+	select {
+	case s.output <- struct{}{}:
+		return nil
+	case <-timeout:
+		return ErrTimeout
+	}
+}
+
+func (s *Serialized) release() {
+	<-s.output
+}
+
+// query runs a query, returning ErrTimeout if it doesn't complete within
+// QueryTimeout.
+func query[T any](s *Serialized, f func() (T, error)) (T, error) {
+	var zero T
+	timeout, stop := s.timeout()
+	defer stop()
+	if err := s.acquire(timeout); err != nil {
+		return zero, err
+	}
+	type result struct {
+		value T
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer s.release()
+		value, err := f()
+		done <- result{value, err}
+	}()
+	select {
+	case res := <-done:
+		return res.value, res.err
+	case <-timeout:
+		return zero, ErrTimeout
+	}
+}
+
+func (s *Serialized) Sensors(packet_id byte) ([]byte, error) {
+	return query(s, func() ([]byte, error) { return s.r.Sensors(packet_id) })
+}
+
+func (s *Serialized) QueryList(packet_ids []byte) ([][]byte, error) {
+	return query(s, func() ([][]byte, error) { return s.r.QueryList(packet_ids) })
+}
+
+// Stream starts a stream, unless one is running. Queries fail with
+// ErrStreaming until it ends.
+func (s *Serialized) Stream(packet_ids []byte) (<-chan [][]byte, error) {
+	timeout, stop := s.timeout()
+	err := s.acquire(timeout)
+	stop()
+	if err != nil {
+		return nil, err
+	}
+	frames, err := s.r.Stream(packet_ids)
+	if err != nil {
+		s.release()
+		return nil, err
+	}
+	pause := make(chan struct{})
+	s.mu.Lock()
+	s.streaming, s.pause = true, pause
+	s.mu.Unlock()
+	out := make(chan [][]byte)
+	go s.relay(frames, pause, out)
+	return out, nil
+}
+
+// relay forwards the frames of the stream until it ends or is paused.
+func (s *Serialized) relay(frames <-chan [][]byte, pause <-chan struct{}, out chan<- [][]byte) {
+	defer func() {
+		s.mu.Lock()
+		s.streaming, s.pause = false, nil
+		s.mu.Unlock()
+		s.release()
+		close(out)
+	}()
+	for {
+		select {
+		case frame, ok := <-frames:
+			if !ok {
+				return
+			}
+			select {
+			case out <- frame:
+				continue
+			case <-pause:
+			}
+		case <-pause:
+		}
+		s.r.PauseStream()
+		// Frames are sent until the pause is read.
+		for range frames {
+		}
+		return
+	}
+}
+
+// PauseStream ends the running stream, if any.
+func (s *Serialized) PauseStream() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pause != nil {
+		close(s.pause)
+		s.pause = nil
+	}
+}
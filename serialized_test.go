@@ -0,0 +1,117 @@
+package roomba_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/xa4a/go-roomba"
+	"github.com/xa4a/go-roomba/clock"
+	"github.com/xa4a/go-roomba/constants"
+	rt "github.com/xa4a/go-roomba/testing"
+)
+
+// stalledRobot holds queries until released.
+type stalledRobot struct {
+	roomba.Robot
+	started chan struct{}
+	release chan struct{}
+}
+
+func (r stalledRobot) QueryList(packet_ids []byte) ([][]byte, error) {
+	r.started <- struct{}{}
+	<-r.release
+	return r.Robot.QueryList(packet_ids)
+}
+
+func stall(r roomba.Robot) stalledRobot {
+	return stalledRobot{r, make(chan struct{}), make(chan struct{})}
+}
+
+func TestSerializedStopDuringQuery(t *testing.T) {
+	t.Parallel()
+	h := rt.NewHarness(t)
+	stalled := stall(h.Roomba)
+	r := roomba.MakeSerialized(stalled)
+	r.QueryTimeout = 0
+
+	done := make(chan error)
+	go func() {
+		_, err := r.QueryList([]byte{constants.SENSOR_OI_MODE})
+		done <- err
+	}()
+	<-stalled.started
+	if err := r.Stop(); err != nil {
+		t.Errorf("stop failed: %s", err)
+	}
+	h.Expect(rt.Drive(0, 0))
+
+	close(stalled.release)
+	if err := <-done; err != nil {
+		t.Errorf("query failed: %s", err)
+	}
+	h.Expect(rt.QueryList(constants.SENSOR_OI_MODE))
+}
+
+func TestSerializedQueryTimeout(t *testing.T) {
+	t.Parallel()
+	h := rt.NewHarness(t)
+	stalled := stall(h.Roomba)
+	clk := clock.MakeVirtual(time.Unix(1000, 0))
+	r := roomba.MakeSerialized(stalled)
+	r.Clock = clk
+
+	done := make(chan error)
+	go func() {
+		_, err := r.QueryList([]byte{constants.SENSOR_OI_MODE})
+		done <- err
+	}()
+	<-stalled.started
+	clk.Advance(roomba.DefaultQueryTimeout)
+	if err := <-done; !errors.Is(err, roomba.ErrTimeout) {
+		t.Errorf("got error %v, want roomba.ErrTimeout", err)
+	}
+
+	// The next query waits for the response of the stalled one.
+	go func() {
+		_, err := r.QueryList([]byte{constants.SENSOR_OI_MODE})
+		done <- err
+	}()
+	close(stalled.release)
+	<-stalled.started
+	if err := <-done; err != nil {
+		t.Errorf("query failed: %s", err)
+	}
+	h.Expect(rt.QueryList(constants.SENSOR_OI_MODE), rt.QueryList(constants.SENSOR_OI_MODE))
+}
+
+func TestSerializedStream(t *testing.T) {
+	t.Parallel()
+	h := rt.NewHarness(t)
+	r := roomba.MakeSerialized(h.Roomba)
+	frames, err := r.Stream([]byte{constants.SENSOR_OI_MODE})
+	if err != nil {
+		t.Fatalf("error starting stream: %s", err)
+	}
+	<-frames
+
+	if _, err := r.Stream([]byte{constants.SENSOR_OI_MODE}); !errors.Is(err, roomba.ErrStreaming) {
+		t.Errorf("got error %v starting a second stream, want roomba.ErrStreaming", err)
+	}
+	if _, err := r.QueryList([]byte{constants.SENSOR_OI_MODE}); !errors.Is(err, roomba.ErrStreaming) {
+		t.Errorf("got error %v querying while streaming, want roomba.ErrStreaming", err)
+	}
+	// Commands go through.
+	if err := r.Stop(); err != nil {
+		t.Errorf("stop failed: %s", err)
+	}
+
+	r.PauseStream()
+	for range frames {
+	}
+	if _, err := r.QueryList([]byte{constants.SENSOR_OI_MODE}); err != nil {
+		t.Errorf("error querying after the stream: %s", err)
+	}
+	h.Expect(rt.Stream(constants.SENSOR_OI_MODE), rt.Drive(0, 0), rt.ResumeStream(false),
+		rt.QueryList(constants.SENSOR_OI_MODE))
+}
@@ -0,0 +1,171 @@
+package sim
+
+import (
+	"math"
+	"time"
+
+	"github.com/xa4a/go-roomba/constants"
+	"github.com/xa4a/go-roomba/kinematics"
+)
+
+// Battery model parameters, roughly those of a Roomba 500 NiMH pack.
+const (
+	DefaultBatteryCapacity = 3000.0 // mAh
+
+	// Current drawn by the electronics, by the drive wheels per mm/s of
+	// wheel speed, and by the cleaning motors.
+	idleCurrent      = 200.0 // mA
+	wheelCurrent     = 0.6   // mA per mm/s, per wheel
+	sideBrushCurrent = 100.0 // mA
+	vacuumCurrent    = 600.0 // mA
+	mainBrushCurrent = 400.0 // mA
+	// Charging currents.
+	fullChargeCurrent  = 1500.0 // mA
+	trickleCurrent     = 60.0   // mA
+	reconditionCurrent = 300.0  // mA
+
+	// Open circuit voltage of an empty and a full battery, and the internal
+	// resistance causing the voltage to sag under load.
+	emptyVoltage       = 13800.0 // mV
+	fullVoltage        = 16800.0 // mV
+	internalResistance = 0.15    // Ohm, i.e. mV per mA.
+
+	ambientTemperature = 25.0 // Celsius
+	// Battery heating per mA^2 of current, in Celsius per second, and the
+	// time constant of cooling down to the ambient temperature.
+	heatingRate = 1e-8
+	coolingTime = 600.0 // s
+	// Charging pauses above maxChargeTemperature until the battery cools
+	// down to resumeChargeTemperature.
+	maxChargeTemperature    = 45.0
+	resumeChargeTemperature = 40.0
+
+	// A battery charged below reconditionLevel of its capacity is charged
+	// slowly until it reaches reconditionEnd.
+	reconditionLevel = 0.05
+	reconditionEnd   = 0.1
+	// Above trickleLevel the battery is trickle charged.
+	trickleLevel = 0.95
+	// Time the robot waits on the dock before it starts charging.
+	dockSettleTime = 2 * time.Second
+)
+
+// Load is what draws current from the battery.
+type Load struct {
+	Wheels kinematics.WheelVelocities // mm/s
+	Motors byte                       // Bits of constants.MOTOR_*.
+}
+
+// Battery models Roomba's battery: drain depending on the load, voltage sag,
+// temperature and charging on the home base. It is advanced in simulated time
+// with Step().
+type Battery struct {
+	Capacity    float64 // mAh
+	Charge      float64 // mAh
+	Current     float64 // mA, negative when discharging.
+	Voltage     float64 // mV
+	Temperature float64 // Celsius
+
+	State  byte // One of constants.CHARGING_*.
+	Source byte // Bits of constants.CHARGING_SOURCE_*.
+
+	docked time.Duration // Time spent on the dock.
+}
+
+// MakeBattery creates a battery with the given capacity and charge, at the
+// ambient temperature.
+func MakeBattery(capacity, charge float64) Battery {
+	b := Battery{Capacity: capacity, Charge: charge, Temperature: ambientTemperature}
+	b.Voltage = b.openCircuitVoltage()
+	return b
+}
+
+// Step advances the model by dt. docked tells whether the robot sits on the
+// home base, and canCharge whether its mode allows charging.
+func (b *Battery) Step(dt time.Duration, load Load, docked, canCharge bool) {
+	if dt <= 0 {
+		return
+	}
+	b.Source = 0
+	if docked {
+		b.Source = constants.CHARGING_SOURCE_HOME_BASE
+		b.docked += dt
+	} else {
+		b.docked = 0
+	}
+
+	if docked && canCharge {
+		b.State = b.chargingState()
+		switch b.State {
+		case constants.CHARGING_RECONDITIONING:
+			b.Current = reconditionCurrent
+		case constants.CHARGING_FULL:
+			b.Current = fullChargeCurrent
+		case constants.CHARGING_TRICKLE:
+			b.Current = trickleCurrent
+		default:
+			// The dock powers the robot while it isn't charging.
+			b.Current = 0
+		}
+	} else {
+		b.State = constants.CHARGING_NOT_CHARGING
+		b.Current = -load.current()
+	}
+
+	s := dt.Seconds()
+	b.Charge = math.Max(0, math.Min(b.Capacity, b.Charge+b.Current*s/3600))
+	b.Temperature += heatingRate*b.Current*b.Current*s -
+		(b.Temperature-ambientTemperature)*s/coolingTime
+	b.Voltage = b.openCircuitVoltage() + b.Current*internalResistance
+}
+
+// chargingState returns the charging state on the dock.
+func (b *Battery) chargingState() byte {
+	level := b.Level()
+	switch {
+	case b.docked < dockSettleTime:
+		return constants.CHARGING_WAITING
+	case b.Temperature > maxChargeTemperature:
+		return constants.CHARGING_WAITING
+	case b.State == constants.CHARGING_WAITING && b.Temperature > resumeChargeTemperature:
+		return constants.CHARGING_WAITING
+	case level < reconditionLevel,
+		b.State == constants.CHARGING_RECONDITIONING && level < reconditionEnd:
+		return constants.CHARGING_RECONDITIONING
+	case level < trickleLevel:
+		return constants.CHARGING_FULL
+	}
+	return constants.CHARGING_TRICKLE
+}
+
+// Level returns the charge as a fraction of the capacity.
+func (b *Battery) Level() float64 {
+	if b.Capacity <= 0 {
+		return 0
+	}
+	return b.Charge / b.Capacity
+}
+
+// Empty reports whether the battery is depleted.
+func (b *Battery) Empty() bool {
+	return b.Charge <= 0
+}
+
+func (b *Battery) openCircuitVoltage() float64 {
+	return emptyVoltage + (fullVoltage-emptyVoltage)*b.Level()
+}
+
+// current returns the current drawn by the load, in mA.
+func (l Load) current() float64 {
+	i := idleCurrent + wheelCurrent*(math.Abs(l.Wheels.Right)+math.Abs(l.Wheels.Left))
+	if l.Motors&constants.MOTOR_SIDE_BRUSH != 0 {
+		i += sideBrushCurrent
+	}
+	if l.Motors&constants.MOTOR_VACUUM != 0 {
+		i += vacuumCurrent
+	}
+	if l.Motors&constants.MOTOR_MAIN_BRUSH != 0 {
+		i += mainBrushCurrent
+	}
+	return i
+}
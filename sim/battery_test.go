@@ -0,0 +1,141 @@
+package sim
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/xa4a/go-roomba"
+	"github.com/xa4a/go-roomba/clock"
+	"github.com/xa4a/go-roomba/constants"
+	"github.com/xa4a/go-roomba/kinematics"
+)
+
+func TestBatteryDrain(t *testing.T) {
+	idle := MakeBattery(3000, 2000)
+	driving := idle
+	cleaning := idle
+	for i := 0; i < 60; i++ {
+		idle.Step(time.Second, Load{}, false, true)
+		driving.Step(time.Second, Load{Wheels: kinematics.WheelVelocities{Right: 500, Left: 500}}, false, true)
+		cleaning.Step(time.Second, Load{
+			Motors: constants.MOTOR_SIDE_BRUSH | constants.MOTOR_VACUUM | constants.MOTOR_MAIN_BRUSH,
+		}, false, true)
+	}
+
+	// 200 mA for a minute.
+	if used := 2000 - idle.Charge; math.Abs(used-200.0/60) > 1e-9 {
+		t.Errorf("idle robot used %f mAh in a minute", used)
+	}
+	if idle.Current != -200 || driving.Current != -800 || cleaning.Current != -1300 {
+		t.Errorf("unexpected currents: idle %f, driving %f, cleaning %f",
+			idle.Current, driving.Current, cleaning.Current)
+	}
+	if !(driving.Voltage < idle.Voltage) {
+		t.Errorf("voltage didn't sag under load: %f vs idle %f", driving.Voltage, idle.Voltage)
+	}
+	if !(cleaning.Temperature > driving.Temperature && driving.Temperature > idle.Temperature) {
+		t.Errorf("unexpected temperatures: idle %f, driving %f, cleaning %f",
+			idle.Temperature, driving.Temperature, cleaning.Temperature)
+	}
+	if idle.State != constants.CHARGING_NOT_CHARGING || idle.Source != 0 {
+		t.Errorf("charging off the dock: state %d, source %d", idle.State, idle.Source)
+	}
+}
+
+func TestBatteryCharging(t *testing.T) {
+	b := MakeBattery(3000, 100)
+	var states []byte
+	for i := 0; i < 6*3600; i++ {
+		b.Step(time.Second, Load{}, true, true)
+		if len(states) == 0 || states[len(states)-1] != b.State {
+			states = append(states, b.State)
+		}
+		if b.Source != constants.CHARGING_SOURCE_HOME_BASE {
+			t.Fatalf("unexpected charging source %d", b.Source)
+		}
+	}
+	want := []byte{
+		constants.CHARGING_WAITING,
+		constants.CHARGING_RECONDITIONING,
+		constants.CHARGING_FULL,
+		constants.CHARGING_TRICKLE,
+	}
+	if string(states) != string(want) {
+		t.Errorf("charging states %v, want %v", states, want)
+	}
+	if b.Charge != b.Capacity {
+		t.Errorf("battery not full after 6 hours: %f", b.Charge)
+	}
+}
+
+func TestBatteryChargingTemperature(t *testing.T) {
+	b := MakeBattery(3000, 1000)
+	b.Temperature = 50
+	b.Step(3*time.Second, Load{}, true, true)
+	if b.State != constants.CHARGING_WAITING {
+		t.Fatalf("charging a hot battery, state %d", b.State)
+	}
+	for b.Temperature > resumeChargeTemperature {
+		b.Step(time.Second, Load{}, true, true)
+		if b.State != constants.CHARGING_WAITING && b.Temperature > resumeChargeTemperature {
+			t.Fatalf("charging resumed at %f C", b.Temperature)
+		}
+	}
+	b.Step(time.Second, Load{}, true, true)
+	if b.State != constants.CHARGING_FULL {
+		t.Errorf("charging didn't resume at %f C, state %d", b.Temperature, b.State)
+	}
+}
+
+func TestSimCharging(t *testing.T) {
+	clk := clock.MakeVirtual(time.Unix(0, 0))
+	sim, socket := MakeRoombaSimClock(clk)
+	defer sim.Stop()
+	w := loadTestWorld(t)
+	sim.SetWorld(w)
+	sim.SetPose(w.HomeBase.DockingPose())
+	r := &roomba.Roomba{S: socket, StreamPaused: make(chan bool, 1)}
+	r.Start()
+
+	clk.Advance(3 * time.Second)
+	values, err := r.QueryList([]byte{
+		constants.SENSOR_CHARGING, constants.SENSOR_CHARGING_SOURCE, constants.SENSOR_CURRENT})
+	if err != nil {
+		t.Fatalf("error querying charging state: %s", err)
+	}
+	if values[0][0] != constants.CHARGING_FULL ||
+		values[1][0] != constants.CHARGING_SOURCE_HOME_BASE ||
+		int16(values[2][0])<<8|int16(values[2][1]) != fullChargeCurrent {
+		t.Errorf("not charging on the dock: %v", values)
+	}
+
+	// No charging in Safe mode.
+	r.Safe()
+	clk.Advance(PhysicsStep)
+	state, _ := r.Sensors(constants.SENSOR_CHARGING)
+	if state[0] != constants.CHARGING_NOT_CHARGING {
+		t.Errorf("charging in safe mode, state %d", state[0])
+	}
+}
+
+func TestSimBatteryEmpty(t *testing.T) {
+	clk := clock.MakeVirtual(time.Unix(0, 0))
+	sim, socket := MakeRoombaSimClock(clk)
+	defer sim.Stop()
+	sim.SetBattery(MakeBattery(3000, 1))
+	r := &roomba.Roomba{S: socket, StreamPaused: make(chan bool, 1)}
+	r.Start()
+	r.Safe()
+	r.DriveStraight(500)
+
+	// 1 mAh lasts 4.5 s at 800 mA.
+	clk.Advance(4 * time.Second)
+	if mode := sim.Mode(); mode != constants.OI_MODE_SAFE {
+		t.Fatalf("robot turned off early, mode %d", mode)
+	}
+	clk.Advance(time.Second)
+	if mode := sim.Mode(); mode != constants.OI_MODE_OFF {
+		t.Errorf("robot still on with an empty battery, mode %d", mode)
+	}
+}
@@ -0,0 +1,201 @@
+package sim
+
+import (
+	"io"
+	"log/slog"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/xa4a/go-roomba/constants"
+)
+
+// FaultConfig configures the faults injected by InjectFaults. Rates are
+// probabilities between 0 and 1; zero values disable the fault. The same
+// seed and the same traffic give the same faults.
+type FaultConfig struct {
+	Seed int64
+
+	// Per byte, in both directions: the byte is lost, or one of its bits
+	// is flipped.
+	DropRate    float64
+	BitFlipRate float64
+
+	// Every chunk of data read from the robot is delayed by Latency plus
+	// a random duration up to Jitter.
+	Latency time.Duration
+	Jitter  time.Duration
+
+	// Per chunk of data read from the robot, the probability that only a
+	// random prefix of it, at least one byte, arrives.
+	TruncateRate float64
+
+	// Per read, the probability that it stalls for StallDuration.
+	StallRate     float64
+	StallDuration time.Duration
+
+	// Per SENSOR_WHEEL_OVERCURRENT value, the probability that a drive
+	// wheel overcurrent bit is set although the wheel is fine.
+	OvercurrentRate float64
+
+	// Per command, the probability that the robot suddenly falls asleep
+	// and drops to Off mode, losing the command.
+	SleepRate float64
+}
+
+// FaultyReadWriter injects faults into the connection to a simulator. Should
+// be constructed with InjectFaults() function.
+type FaultyReadWriter struct {
+	rw     io.ReadWriter
+	sim    *RoombaSimulator
+	config FaultConfig
+
+	readMu    sync.Mutex
+	readRand  *rand.Rand
+	stallNext time.Duration
+
+	writeMu   sync.Mutex
+	writeRand *rand.Rand
+}
+
+// InjectFaults wraps rw, the connection to sim returned by MakeRoombaSim, in
+// a layer injecting the configured faults. Faults that happen in the robot
+// itself, overcurrent and sleep, are injected into sim.
+func InjectFaults(sim *RoombaSimulator, rw io.ReadWriter, config FaultConfig) *FaultyReadWriter {
+	sim.mu.Lock()
+	sim.faults = &config
+	sim.faultRand = rand.New(rand.NewSource(config.Seed))
+	sim.mu.Unlock()
+	return &FaultyReadWriter{
+		rw:        rw,
+		sim:       sim,
+		config:    config,
+		readRand:  rand.New(rand.NewSource(config.Seed + 1)),
+		writeRand: rand.New(rand.NewSource(config.Seed + 2)),
+	}
+}
+
+// Write sends p to the robot with dropped and corrupted bytes. Like a serial
+// line, it reports all bytes as written.
+func (f *FaultyReadWriter) Write(p []byte) (int, error) {
+	f.writeMu.Lock()
+	data := corrupt(append([]byte(nil), p...), f.writeRand, f.config.DropRate, f.config.BitFlipRate, f.sim.logger())
+	f.writeMu.Unlock()
+	if len(data) == 0 {
+		return len(p), nil
+	}
+	if _, err := f.rw.Write(data); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Read reads data from the robot, delayed, truncated and corrupted.
+func (f *FaultyReadWriter) Read(p []byte) (int, error) {
+	f.readMu.Lock()
+	defer f.readMu.Unlock()
+	for {
+		n, err := f.rw.Read(p)
+		if n == 0 {
+			return 0, err
+		}
+		r := f.readRand
+		delay := f.config.Latency
+		if f.config.Jitter > 0 {
+			delay += time.Duration(r.Int63n(int64(f.config.Jitter)))
+		}
+		if f.config.StallRate > 0 && r.Float64() < f.config.StallRate {
+			delay += f.config.StallDuration
+		}
+		delay += f.stallNext
+		f.stallNext = 0
+		if delay > 0 {
+			f.sim.clock.Sleep(delay)
+		}
+
+		data := p[:n]
+		if n > 1 && f.config.TruncateRate > 0 && r.Float64() < f.config.TruncateRate {
+			data = data[:1+r.Intn(n-1)]
+			f.sim.logger().Info("fault: truncated read", "bytes", n, "kept", len(data))
+		}
+		data = corrupt(data, r, f.config.DropRate, f.config.BitFlipRate, f.sim.logger())
+		if len(data) > 0 || err != nil {
+			// Corrupt works in place, so the data is already in p.
+			return len(data), err
+		}
+		// Everything was lost, wait for more.
+	}
+}
+
+// StallNextRead makes the next read wait for d before returning.
+func (f *FaultyReadWriter) StallNextRead(d time.Duration) {
+	f.readMu.Lock()
+	f.stallNext = d
+	f.readMu.Unlock()
+}
+
+// corrupt drops bytes and flips bits of data in place and returns what is
+// left of it.
+func corrupt(data []byte, r *rand.Rand, dropRate, flipRate float64, logger *slog.Logger) []byte {
+	if dropRate <= 0 && flipRate <= 0 {
+		return data
+	}
+	out := data[:0]
+	for _, b := range data {
+		if dropRate > 0 && r.Float64() < dropRate {
+			logger.Info("fault: dropped byte", "byte", b)
+			continue
+		}
+		if flipRate > 0 && r.Float64() < flipRate {
+			flipped := b ^ 1<<uint(r.Intn(8))
+			logger.Info("fault: flipped bits", "byte", b, "flipped", flipped)
+			b = flipped
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+// overcurrentBits returns the value of the SENSOR_WHEEL_OVERCURRENT packet.
+// Must be called with sim.mu held.
+func (sim *RoombaSimulator) overcurrentBits() byte {
+	bits := sim.overcurrent
+	if sim.faults != nil && sim.faults.OvercurrentRate > 0 &&
+		sim.faultRand.Float64() < sim.faults.OvercurrentRate {
+		spurious := byte(constants.OVERCURRENT_RIGHT_WHEEL)
+		if sim.faultRand.Intn(2) == 1 {
+			spurious = constants.OVERCURRENT_LEFT_WHEEL
+		}
+		sim.logger().Info("fault: spurious overcurrent", "bits", spurious)
+		bits |= spurious
+	}
+	return bits
+}
+
+// fallAsleep decides whether the robot suddenly falls asleep on a command and
+// puts it to sleep if so.
+func (sim *RoombaSimulator) fallAsleep() bool {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	if sim.faults == nil || sim.faults.SleepRate <= 0 ||
+		sim.faultRand.Float64() >= sim.faults.SleepRate {
+		return false
+	}
+	sim.logger().Info("fault: falling asleep")
+	sim.setMode(constants.OI_MODE_OFF)
+	return true
+}
+
+// Sleep puts the robot to sleep, dropping it to Off mode. Like the robot, the
+// simulator then ignores everything until Start.
+func (sim *RoombaSimulator) Sleep() {
+	sim.SetMode(constants.OI_MODE_OFF)
+}
+
+// SetOvercurrent sets the SENSOR_WHEEL_OVERCURRENT bits reported by the
+// simulator until changed.
+func (sim *RoombaSimulator) SetOvercurrent(bits byte) {
+	sim.mu.Lock()
+	sim.overcurrent = bits
+	sim.mu.Unlock()
+}
@@ -0,0 +1,129 @@
+package sim
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/xa4a/go-roomba"
+	"github.com/xa4a/go-roomba/clock"
+	"github.com/xa4a/go-roomba/constants"
+)
+
+// readFaultyStream streams from a simulator with faults and returns what the
+// client received.
+func readFaultyStream(t *testing.T, config FaultConfig) []byte {
+	clk := clock.MakeVirtual(time.Unix(0, 0))
+	sim, socket := MakeRoombaSimClock(clk)
+	defer sim.Stop()
+	faulty := InjectFaults(sim, socket, config)
+
+	// Set up on the clean connection, stream distance and angle.
+	socket.Write([]byte{128, 131, 137, 0, 200, 127, 255})
+	socket.Write([]byte{148, 2, constants.SENSOR_DISTANCE, constants.SENSOR_ANGLE})
+
+	var received []byte
+	buf := make([]byte, 64)
+	for i := 0; i < 50; i++ {
+		clk.Advance(StreamPeriod)
+		n, err := faulty.Read(buf)
+		if err != nil {
+			t.Fatalf("error reading: %s", err)
+		}
+		received = append(received, buf[:n]...)
+	}
+	return received
+}
+
+func TestFaultsReproducible(t *testing.T) {
+	config := FaultConfig{Seed: 42, DropRate: 0.05, BitFlipRate: 0.05, TruncateRate: 0.1}
+	first := readFaultyStream(t, config)
+	if second := readFaultyStream(t, config); !bytes.Equal(first, second) {
+		t.Errorf("same seed, different data:\n%v\n%v", first, second)
+	}
+	clean := readFaultyStream(t, FaultConfig{})
+	if len(clean) != 50*9 {
+		t.Fatalf("got %d bytes without faults, want %d", len(clean), 50*9)
+	}
+	if bytes.Equal(first, clean) {
+		t.Errorf("no faults injected")
+	}
+	config.Seed = 43
+	if other := readFaultyStream(t, config); bytes.Equal(first, other) {
+		t.Errorf("different seeds, same data")
+	}
+}
+
+func TestStallNextRead(t *testing.T) {
+	clk := clock.MakeVirtual(time.Unix(0, 0))
+	sim, socket := MakeRoombaSimClock(clk)
+	defer sim.Stop()
+	faulty := InjectFaults(sim, socket, FaultConfig{})
+	r := &roomba.Roomba{S: faulty, StreamPaused: make(chan bool, 1)}
+	r.Start()
+
+	faulty.StallNextRead(time.Second)
+	done := make(chan time.Time)
+	go func() {
+		r.Sensors(constants.SENSOR_OI_MODE)
+		done <- clk.Now()
+	}()
+	<-clk.Blocked(1)
+	clk.Advance(time.Second)
+	if got := (<-done).Sub(time.Unix(0, 0)); got != time.Second {
+		t.Errorf("read returned after %v, want 1s", got)
+	}
+}
+
+func TestSpuriousOvercurrent(t *testing.T) {
+	sim, socket := MakeRoombaSim()
+	defer sim.Stop()
+	faulty := InjectFaults(sim, socket, FaultConfig{Seed: 1, OvercurrentRate: 0.5})
+	r := &roomba.Roomba{S: faulty, StreamPaused: make(chan bool, 1)}
+	r.Start()
+
+	seen := 0
+	for i := 0; i < 100; i++ {
+		bits, err := r.Sensors(constants.SENSOR_WHEEL_OVERCURRENT)
+		if err != nil {
+			t.Fatalf("error reading overcurrent: %s", err)
+		}
+		if bits[0]&^(constants.OVERCURRENT_LEFT_WHEEL|constants.OVERCURRENT_RIGHT_WHEEL) != 0 {
+			t.Fatalf("unexpected overcurrent bits %08b", bits[0])
+		}
+		if bits[0] != 0 {
+			seen++
+		}
+	}
+	if seen < 30 || seen > 70 {
+		t.Errorf("got %d spurious overcurrents in 100 reads", seen)
+	}
+}
+
+func TestSuddenSleep(t *testing.T) {
+	clk := clock.MakeVirtual(time.Unix(0, 0))
+	sim, socket := MakeRoombaSimClock(clk)
+	defer sim.Stop()
+	r := &roomba.Roomba{S: socket, StreamPaused: make(chan bool, 1)}
+	r.Start()
+	r.Safe()
+	r.DriveStraight(200)
+
+	sim.Sleep()
+	r.DriveStraight(100)
+	clk.Advance(time.Second)
+	if p := sim.Physics(); p.Target.Right != 0 || p.Pose.X != 0 {
+		t.Errorf("sleeping robot moves: %+v", p)
+	}
+	r.Start()
+	if mode := queryMode(t, r); mode != constants.OI_MODE_PASSIVE {
+		t.Errorf("robot didn't wake up on start, mode %d", mode)
+	}
+
+	// With a sleep rate of 1 the robot never gets past Start.
+	InjectFaults(sim, socket, FaultConfig{SleepRate: 1})
+	r.Safe()
+	if mode := sim.Mode(); mode != constants.OI_MODE_OFF {
+		t.Errorf("robot didn't fall asleep, mode %d", mode)
+	}
+}
@@ -0,0 +1,105 @@
+package sim
+
+import (
+	"math"
+	"time"
+
+	"github.com/xa4a/go-roomba/constants"
+	"github.com/xa4a/go-roomba/kinematics"
+)
+
+// Encoder counts per millimeter travelled by a wheel.
+const countsPerMM = constants.ENCODER_COUNTS_PER_REV / (math.Pi * constants.WHEEL_DIAMETER)
+
+// Physics is a differential-drive model of Roomba's motion. It is advanced in
+// simulated time with Step().
+type Physics struct {
+	// Target wheel velocities (mm/s), as set by drive commands.
+	Target kinematics.WheelVelocities
+	// Actual wheel velocities (mm/s).
+	Wheels kinematics.WheelVelocities
+	// WheelAccel limits the wheel acceleration (mm/s^2). Zero means the
+	// wheels reach the target velocity instantly.
+	WheelAccel float64
+
+	Pose    kinematics.Pose
+	Elapsed time.Duration // Simulated time since start.
+
+	// Distance (mm) and angle (degrees) travelled but not yet reported in
+	// the SENSOR_DISTANCE and SENSOR_ANGLE packets.
+	Distance, Angle float64
+
+	// Cumulative encoder counts.
+	LeftEncoder, RightEncoder float64
+}
+
+// Step advances the model by dt.
+func (p *Physics) Step(dt time.Duration) {
+	if dt <= 0 {
+		return
+	}
+	var right, left float64 // mm travelled by each wheel.
+	p.Wheels.Right, right = travel(p.Wheels.Right, p.Target.Right, p.WheelAccel, dt.Seconds())
+	p.Wheels.Left, left = travel(p.Wheels.Left, p.Target.Left, p.WheelAccel, dt.Seconds())
+	mean := kinematics.WheelVelocities{
+		Right: right / dt.Seconds(),
+		Left:  left / dt.Seconds(),
+	}
+	twist := mean.Twist()
+	p.Pose = p.Pose.Integrate(twist, dt)
+	p.Distance += (right + left) / 2
+	p.Angle += twist.Angular * dt.Seconds() * 180 / math.Pi
+	p.RightEncoder += right * countsPerMM
+	p.LeftEncoder += left * countsPerMM
+	p.Elapsed += dt
+}
+
+// travel accelerates a wheel from velocity v towards target for dt seconds.
+// It returns the new velocity and the distance travelled. Non-positive accel
+// reaches the target immediately.
+func travel(v, target, accel, dt float64) (float64, float64) {
+	if accel <= 0 {
+		return target, target * dt
+	}
+	t := math.Abs(target-v) / accel // Time to reach the target.
+	if t >= dt {
+		end := v + math.Copysign(accel*dt, target-v)
+		return end, (v + end) / 2 * dt
+	}
+	return target, (v+target)/2*t + target*(dt-t)
+}
+
+// TakeDistance returns the distance (mm) travelled since the last call, as
+// reported by the SENSOR_DISTANCE packet.
+func (p *Physics) TakeDistance() int16 {
+	return takeWhole(&p.Distance)
+}
+
+// TakeAngle returns the angle (degrees) turned since the last call, as
+// reported by the SENSOR_ANGLE packet.
+func (p *Physics) TakeAngle() int16 {
+	return takeWhole(&p.Angle)
+}
+
+// takeWhole removes the whole part of *v and returns it. The fraction stays in
+// *v to be reported later. Like on the robot, values that don't fit into
+// int16 are capped and the excess is lost.
+func takeWhole(v *float64) int16 {
+	whole := math.Trunc(*v)
+	if whole > math.MaxInt16 || whole < math.MinInt16 {
+		*v = 0
+		return int16(math.Max(math.MinInt16, math.Min(math.MaxInt16, whole)))
+	}
+	*v -= whole
+	return int16(whole)
+}
+
+// EncoderCounts returns the encoder counts as reported by the
+// SENSOR_LEFT_ENCODER_COUNTS and SENSOR_RIGHT_ENCODER_COUNTS packets.
+func (p *Physics) EncoderCounts() (left, right uint16) {
+	return wrapCounts(p.LeftEncoder), wrapCounts(p.RightEncoder)
+}
+
+func wrapCounts(c float64) uint16 {
+	return uint16(int64(math.Floor(c)))
+}
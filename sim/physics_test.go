@@ -0,0 +1,72 @@
+package sim
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/xa4a/go-roomba/kinematics"
+)
+
+func TestPhysicsStraight(t *testing.T) {
+	p := &Physics{Target: kinematics.WheelVelocities{Right: 200, Left: 200}}
+	for i := 0; i < 100; i++ {
+		p.Step(10 * time.Millisecond)
+	}
+	if d := p.TakeDistance(); d != 200 {
+		t.Errorf("expected 200 mm, got %d", d)
+	}
+	if a := p.TakeAngle(); a != 0 {
+		t.Errorf("expected no turn, got %d degrees", a)
+	}
+	if math.Abs(p.Pose.X-0.2) > 1e-9 || p.Pose.Y != 0 {
+		t.Errorf("unexpected pose: %+v", p.Pose)
+	}
+	left, right := p.EncoderCounts()
+	// One wheel revolution is 508.8 counts for 226.2 mm.
+	if left != 449 || right != 449 {
+		t.Errorf("unexpected encoder counts: %d, %d", left, right)
+	}
+}
+
+func TestPhysicsSpin(t *testing.T) {
+	p := &Physics{Target: driveWheels(100, 1)}
+	// Quarter turn: Pi/2 * 149 mm at 100 mm/s.
+	quarter := math.Pi / 2 * 149 / 100
+	p.Step(time.Duration(quarter * float64(time.Second)))
+	if a := p.TakeAngle(); a != 89 && a != 90 {
+		t.Errorf("expected quarter turn, got %d degrees", a)
+	}
+	if d := p.TakeDistance(); d != 0 {
+		t.Errorf("expected no distance when spinning, got %d", d)
+	}
+	left, _ := p.EncoderCounts()
+	if left < 0x8000 {
+		t.Errorf("left encoder should roll back below zero, got %d", left)
+	}
+}
+
+func TestPhysicsWheelAccel(t *testing.T) {
+	p := &Physics{
+		Target:     kinematics.WheelVelocities{Right: 500, Left: 500},
+		WheelAccel: 1000,
+	}
+	p.Step(250 * time.Millisecond)
+	if p.Wheels.Right != 250 {
+		t.Errorf("expected wheels at 250 mm/s, got %+v", p.Wheels)
+	}
+	// Mean velocity of 125 mm/s over 0.25 s.
+	if d := p.TakeDistance(); d != 31 {
+		t.Errorf("expected 31 mm, got %d", d)
+	}
+}
+
+func TestDistanceCapped(t *testing.T) {
+	p := &Physics{Distance: 40000.5}
+	if d := p.TakeDistance(); d != 32767 {
+		t.Errorf("expected capped distance, got %d", d)
+	}
+	if d := p.TakeDistance(); d != 0 {
+		t.Errorf("expected excess distance to be lost, got %d", d)
+	}
+}
@@ -0,0 +1,72 @@
+package sim
+
+import (
+	"io"
+	"sync"
+)
+
+// syncPipe carries bytes from the client to the simulator. Unlike io.Pipe, a
+// Write returns only once the simulator has consumed all the bytes and is
+// waiting for more, i.e. once the commands they contain are executed. This
+// makes the simulator's state after each command deterministic.
+type syncPipe struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	buf     []byte
+	waiting bool // The reader is blocked on an empty buffer.
+	closed  bool
+}
+
+func makeSyncPipe() *syncPipe {
+	p := &syncPipe{}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+func (p *syncPipe) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return 0, io.ErrClosedPipe
+	}
+	p.buf = append(p.buf, b...)
+	p.waiting = false
+	p.cond.Broadcast()
+	for !p.closed && !(p.waiting && len(p.buf) == 0) {
+		p.cond.Wait()
+	}
+	if len(p.buf) > 0 {
+		n := len(b) - len(p.buf)
+		if n < 0 {
+			n = 0
+		}
+		return n, io.ErrClosedPipe
+	}
+	return len(b), nil
+}
+
+func (p *syncPipe) Read(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for len(p.buf) == 0 {
+		if p.closed {
+			return 0, io.EOF
+		}
+		p.waiting = true
+		p.cond.Broadcast()
+		p.cond.Wait()
+	}
+	p.waiting = false
+	n := copy(b, p.buf)
+	p.buf = p.buf[n:]
+	return n, nil
+}
+
+// Close closes both ends of the pipe.
+func (p *syncPipe) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	p.cond.Broadcast()
+	return nil
+}
@@ -3,6 +3,33 @@ Package sim defines a limited OpenInterface simulator type that's mainly used fo
 
 Simulator can be created using MakeRoombaSim() function, which returns a
 simulator instance and a ReadWriter, suitable for passing to go-roomba client.
+
+The simulator models the robot's motion with a differential-drive Physics
+model advanced in real time, or on a virtual clock with MakeRoombaSimClock().
+Drive commands set the wheel velocities, and the
+distance, angle and encoder packets report the resulting motion. An optional
+World provides walls, cliffs, virtual walls and a home base for the bump,
+wall, cliff and IR sensors.
+
+A Battery model drains with the wheel and cleaning motor load and charges
+while the robot sits on the World's home base in Passive mode, driving the
+battery and charging packets. The robot turns off when the battery is empty.
+
+The simulator follows the OI modes: it starts in Off mode and ignores
+everything until Start, ignores actuator commands in Passive mode, and drops
+from Safe to Passive mode when a wheel drops or a cliff is detected while
+driving forward.
+
+Like the robot, the simulator sends a stream frame every 15 ms after a Stream
+command until the stream is paused with ResumeStream.
+
+Writes to the simulator's ReadWriter return once the simulator has executed
+the commands they contain. On a virtual clock, the simulation and the stream
+only move when the clock is advanced, so a test driving the simulator from a
+single goroutine gets the same transcript on every run.
+
+InjectFaults wraps the connection to a simulator to test clients against a
+flaky robot.
 */
 package sim
 
@@ -11,22 +38,63 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"math"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/xa4a/go-roomba"
+	"github.com/xa4a/go-roomba/clock"
 	"github.com/xa4a/go-roomba/constants"
+	"github.com/xa4a/go-roomba/kinematics"
 )
 
 // Roomba simulator instance. Should be constructed with MakeRoombaSim()
 // function.
 type RoombaSimulator struct {
 	rw           io.ReadWriter
+	clock        clock.Clock
 	writeQ       chan []byte
 	WrittenBytes bytes.Buffer // Logs all the bytes written by the simulator to its Writer.
 	ReadBytes    bytes.Buffer // Logs all the bytes read by the simulator from its Reader.
 
-	RequestedVelocity []byte
-	RequestedRadius   []byte
+	// Like WrittenBytes and ReadBytes, but safe for concurrent access
+	// through Sent() and Received().
+	logMu          sync.Mutex
+	sent, received []byte
+
+	// The arguments of the last Drive and DirectDrive commands. Guarded by
+	// mu, as streams read them.
+	RequestedVelocity      []byte
+	RequestedRadius        []byte
+	RequestedRightVelocity []byte
+	RequestedLeftVelocity  []byte
+
+	mu      sync.Mutex
+	mode    byte // One of constants.OI_MODE_*.
+	baud    int
+	physics Physics
+	battery Battery
+	motors  byte // Bits of constants.MOTOR_*.
+	world   *World
+
+	overcurrent byte // SENSOR_WHEEL_OVERCURRENT bits set by SetOvercurrent.
+	faults      *FaultConfig
+	faultRand   *rand.Rand
+
+	streamPackets []byte // nil when no stream was requested.
+	streamPaused  bool
+
+	songs       map[byte][]byte // Note and duration bytes of stored songs.
+	songNumber  byte
+	songPlaying bool
+
+	log atomic.Pointer[slog.Logger] // Set by SetLogger.
+
+	quit    chan struct{}
+	closers []io.Closer
 }
 
 // MockSensorValues contains mapping of sensor codes to sensor values returned
@@ -35,14 +103,7 @@ var MockSensorValues = map[byte][]byte{
 	constants.SENSOR_BUMP_WHEELS_DROPS:       []byte{3},
 	constants.SENSOR_VIRTUAL_WALL:            []byte{5},
 	constants.SENSOR_CLIFF_RIGHT:             []byte{42},
-	constants.SENSOR_TEMPERATURE:             []byte{25},
-	constants.SENSOR_OI_MODE:                 []byte{2},
-	constants.SENSOR_SONG_NUMBER:             []byte{1},
-	constants.SENSOR_DISTANCE:                []byte{10, 20},
 	constants.SENSOR_WALL:                    []byte{35},
-	constants.SENSOR_BATTERY_CHARGE:          roomba.Pack([]interface{}{uint16(1000)}),
-	constants.SENSOR_BATTERY_CAPACITY:        roomba.Pack([]interface{}{uint16(1500)}),
-	constants.SENSOR_CURRENT:                 roomba.Pack([]interface{}{int16(-747)}),
 	constants.SENSOR_CLIFF_FRONT_LEFT_SIGNAL: roomba.Pack([]interface{}{uint8(2), uint8(25)}),
 }
 
@@ -50,21 +111,144 @@ func (sim *RoombaSimulator) serve() {
 	// Write bytes from channel asynchronously.
 	go func() {
 		for {
-			bs := <-sim.writeQ
-			if len(bs) == 0 {
-				break
+			select {
+			case <-sim.quit:
+				return
+			case bs := <-sim.writeQ:
+				sim.logMu.Lock()
+				sim.WrittenBytes.Write(bs)
+				sim.sent = append(sim.sent, bs...)
+				sim.logMu.Unlock()
+				sim.rw.Write(bs)
 			}
-			sim.rw.Write(bs)
 		}
 	}()
 
 	for {
-		sim.executeCMD()
+		select {
+		case <-sim.quit:
+			return
+		default:
+			sim.executeCMD()
+		}
+	}
+}
+
+// BaudRates maps the codes of the Baud command to baud rates.
+var BaudRates = []int{
+	300, 600, 1200, 2400, 4800, 9600, 14400, 19200, 28800, 38400, 57600, 115200,
+}
+
+// StreamPeriod is the interval between stream frames.
+const StreamPeriod = 15 * time.Millisecond
+
+// runStream sends a stream frame every StreamPeriod while a stream is active.
+// If a frame takes longer than that to transmit at the current baud rate,
+// frames are sent less often, skipping whole periods.
+func (sim *RoombaSimulator) runStream() {
+	due := sim.clock.Now().Add(StreamPeriod) // Of the next tick.
+	var next time.Time
+	var tick func()
+	tick = func() {
+		select {
+		case <-sim.quit:
+			return
+		default:
+		}
+		now := sim.clock.Now()
+		due = due.Add(StreamPeriod)
+		defer sim.clock.AfterFunc(due.Sub(now), tick)
+
+		if now.Before(next) {
+			return
+		}
+		frame := sim.streamFrame()
+		if frame == nil {
+			return
+		}
+		periods := 1
+		if t := sim.transmitTime(len(frame)); t > StreamPeriod {
+			periods = int((t + StreamPeriod - 1) / StreamPeriod)
+			sim.logger().Warn("stream frame takes longer than a period to send", "bytes", len(frame), "time", t)
+		}
+		// Halfway between ticks, to be immune to jitter.
+		next = now.Add(time.Duration(periods)*StreamPeriod - StreamPeriod/2)
+		select {
+		case sim.writeQ <- frame:
+		default:
+			// Like the robot's UART, drop data the host doesn't read.
+			sim.logger().Warn("host not reading, dropping stream frame")
+		}
+	}
+	sim.clock.AfterFunc(StreamPeriod, tick)
+}
+
+// transmitTime returns how long it takes to send n bytes at the current baud
+// rate, with 10 bits per byte on the wire.
+func (sim *RoombaSimulator) transmitTime(n int) time.Duration {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	return time.Duration(n) * 10 * time.Second / time.Duration(sim.baud)
+}
+
+// streamFrame builds a frame of the current stream, or returns nil if there
+// is no active stream or the robot is off.
+func (sim *RoombaSimulator) streamFrame() []byte {
+	sim.mu.Lock()
+	packetIds, paused := sim.streamPackets, sim.streamPaused
+	off := sim.mode == constants.OI_MODE_OFF
+	sim.mu.Unlock()
+	if packetIds == nil || paused || off {
+		return nil
+	}
+
+	// Contains just packet ids and values, no headers.
+	sensorValues := bytes.Buffer{}
+	for _, packetId := range packetIds {
+		value := sim.querySensor(packetId)
+		sensorValues.WriteByte(packetId)
+		sensorValues.Write(value)
+	}
+
+	output := bytes.Buffer{}
+	// Header.
+	output.WriteByte(19)
+	// Data length.
+	output.WriteByte(byte(sensorValues.Len()))
+	output.Write(sensorValues.Bytes())
+	checksum := byte(0)
+	for _, b := range output.Bytes() {
+		checksum -= b
+	}
+	output.WriteByte(checksum)
+	return output.Bytes()
+}
+
+// runPhysics advances the simulation with the simulator's clock until the
+// simulator is stopped.
+func (sim *RoombaSimulator) runPhysics() {
+	last := sim.clock.Now()
+	var tick func()
+	tick = func() {
+		select {
+		case <-sim.quit:
+			return
+		default:
+		}
+		now := sim.clock.Now()
+		sim.Advance(now.Sub(last))
+		last = now
+		sim.clock.AfterFunc(PhysicsStep, tick)
 	}
+	sim.clock.AfterFunc(PhysicsStep, tick)
 }
 
+// Stop stops the simulator and closes its end of the connection.
 func (sim *RoombaSimulator) Stop() {
-	sim.writeQ <- []byte{}
+	close(sim.quit)
+	for _, c := range sim.closers {
+		c.Close()
+	}
 }
 
 func (sim *RoombaSimulator) executeCMD() error {
@@ -72,121 +256,548 @@ func (sim *RoombaSimulator) executeCMD() error {
 	if len(cmdBuf) != 1 {
 		return fmt.Errorf("failed reading opcode")
 	}
-	switch cmdBuf[0] {
+	opcode := cmdBuf[0]
+	if sim.Mode() == constants.OI_MODE_OFF && opcode != constants.OpCodes["Start"] {
+		// Like the robot, wait for Start and treat everything else,
+		// including command arguments, as noise.
+		sim.logger().Debug("OI not started, ignoring byte", roomba.LogOpcode, opcode)
+		return nil
+	}
+	if sim.fallAsleep() {
+		return nil
+	}
+	switch opcode {
 	case constants.OpCodes["Sensors"]:
 		packetId := sim.read(1)[0]
-		value, ok := MockSensorValues[packetId]
-		if !ok {
-			if packetId == constants.SENSOR_REQUESTED_RADIUS {
-				value = sim.RequestedRadius
-			} else if packetId == constants.SENSOR_REQUESTED_VELOCITY {
-				value = sim.RequestedVelocity
-			} else {
-				log.Printf("no mock value for sensor packet id %d", packetId)
-			}
-		}
-		log.Printf("sensor %d value: %v", packetId, value)
+		value := sim.querySensor(packetId)
+		sim.logger().Debug("sensor value", roomba.LogPacketId, packetId, "value", value)
 		sim.write(value)
 	case constants.OpCodes["QueryList"]:
 		nPackets := sim.read(1)[0]
-		for i := 0; i < int(nPackets); i++ {
-			packetId := sim.read(1)[0]
-			value, ok := MockSensorValues[packetId]
-			if !ok {
-				if packetId == constants.SENSOR_REQUESTED_RADIUS {
-					value = sim.RequestedRadius
-				} else if packetId == constants.SENSOR_REQUESTED_VELOCITY {
-					value = sim.RequestedVelocity
-				} else {
-					log.Printf("no mock value for sensor packet id %d", packetId)
-				}
-			}
-			log.Printf("sensor %d value: %v", packetId, value)
-			sim.write(value)
+		// Like the robot, answer once the whole request is received, as
+		// the host may only read the response after sending it all.
+		var response []byte
+		for _, packetId := range sim.read(int(nPackets)) {
+			value := sim.querySensor(packetId)
+			sim.logger().Debug("sensor value", roomba.LogPacketId, packetId, "value", value)
+			response = append(response, value...)
 		}
+		sim.write(response)
 	case constants.OpCodes["Stream"]:
 		nBytes := sim.read(1)[0]
 		packetIds := make([]byte, nBytes)
 		for i := byte(0); i < nBytes; i++ {
 			packetIds[i] = sim.read(1)[0]
 		}
-		// Contains just packet ids and values, no headers.
-		sensorValues := bytes.Buffer{}
-		for i := byte(0); i < nBytes; i++ {
-			mockValue, ok := MockSensorValues[packetIds[i]]
-			if !ok {
-				log.Printf("no mock value for streaming packet id: %d", packetIds[i])
-				mockValue = make([]byte, constants.SENSOR_PACKET_LENGTH[packetIds[i]])
-			} else {
-				log.Printf("sensor %d value: %v", packetIds[i], mockValue)
-			}
-			sensorValues.WriteByte(packetIds[i])
-			sensorValues.Write(mockValue)
-		}
-
-		output := bytes.Buffer{}
-		// Header.
-		output.WriteByte(19)
-		// Data length.
-		messageLen := byte(sensorValues.Len())
-		log.Printf("message length: %d", messageLen)
-		output.WriteByte(messageLen)
-		output.Write(sensorValues.Bytes())
-		checksum := byte(0)
-		for _, b := range output.Bytes()[1:] {
-			checksum -= b
-		}
-		output.WriteByte(checksum)
-		log.Printf("checksum: %d", checksum)
-
-		sim.write(output.Bytes())
+		sim.mu.Lock()
+		sim.streamPackets = packetIds
+		sim.streamPaused = false
+		sim.mu.Unlock()
+		sim.logger().Info("streaming packets", "packets", packetIds)
 	case constants.OpCodes["Start"]:
-		log.Printf("switched to passive mode")
+		sim.SetMode(constants.OI_MODE_PASSIVE)
 	case constants.OpCodes["Safe"]:
-		log.Printf("switched to safe mode")
+		sim.SetMode(constants.OI_MODE_SAFE)
+	case constants.OpCodes["Full"]:
+		sim.SetMode(constants.OI_MODE_FULL)
+	case constants.OpCodes["Clean"], constants.OpCodes["Spot"],
+		constants.OpCodes["Max"]:
+		// Cleaning isn't simulated, the robot just stays in place with
+		// its cleaning motors running.
+		sim.logger().Info("cleaning", roomba.LogOpcode, opcode)
+		sim.mu.Lock()
+		sim.setMode(constants.OI_MODE_PASSIVE)
+		sim.motors = constants.MOTOR_SIDE_BRUSH | constants.MOTOR_VACUUM | constants.MOTOR_MAIN_BRUSH
+		sim.mu.Unlock()
+	case constants.OpCodes["Seek_dock"], constants.OpCodes["Power"]:
+		sim.logger().Info("stopping cleaning", roomba.LogOpcode, opcode)
+		sim.mu.Lock()
+		sim.setMode(constants.OI_MODE_PASSIVE)
+		sim.motors = 0
+		sim.mu.Unlock()
+	case constants.OpCodes["Motors"]:
+		motors := sim.read(1)[0]
+		if !sim.actuatorsEnabled("Motors") {
+			break
+		}
+		sim.logger().Debug("motors", roomba.LogOpcode, opcode, "motors", motors)
+		sim.mu.Lock()
+		sim.motors = motors & (constants.MOTOR_SIDE_BRUSH | constants.MOTOR_VACUUM | constants.MOTOR_MAIN_BRUSH)
+		sim.mu.Unlock()
+	case constants.OpCodes["LEDs"]:
+		data := sim.read(3)
+		if !sim.actuatorsEnabled("LEDs") {
+			break
+		}
+		sim.logger().Debug("LEDs", roomba.LogOpcode, opcode, "data", data)
+	case constants.OpCodes["Song"]:
+		header := sim.read(2)
+		if len(header) != 2 {
+			break
+		}
+		notes := sim.read(2 * int(header[1]))
+		sim.logger().Debug("song", roomba.LogOpcode, opcode, "song", header[0], "notes", header[1])
+		sim.mu.Lock()
+		sim.songs[header[0]] = notes
+		sim.mu.Unlock()
+	case constants.OpCodes["Play"]:
+		song := sim.read(1)[0]
+		if !sim.actuatorsEnabled("Play") {
+			break
+		}
+		sim.play(song)
 	case constants.OpCodes["ResumeStream"]:
-		if sim.read(1)[0] == byte(0) {
-			log.Printf("stream paused")
+		paused := sim.read(1)[0] == byte(0)
+		sim.mu.Lock()
+		sim.streamPaused = paused
+		sim.mu.Unlock()
+		if paused {
+			sim.logger().Info("stream paused")
 		} else {
-			log.Printf("stream resumed")
+			sim.logger().Info("stream resumed")
+		}
+	case constants.OpCodes["Baud"]:
+		code := sim.read(1)[0]
+		if int(code) >= len(BaudRates) {
+			sim.logger().Warn("invalid baud code", roomba.LogOpcode, opcode, "code", code)
+			break
 		}
+		sim.mu.Lock()
+		sim.baud = BaudRates[code]
+		sim.mu.Unlock()
+		sim.logger().Info("baud rate changed", "baud", BaudRates[code])
 	case constants.OpCodes["DirectDrive"]:
 		data := sim.read(4)
 		var rigthVelocity, leftVelocity int16
 		binary.Read(bytes.NewReader(data[:2]), binary.BigEndian, &rigthVelocity)
 		binary.Read(bytes.NewReader(data[2:4]), binary.BigEndian, &leftVelocity)
-		log.Printf("DirectDrive: %d, %d (%v)", rigthVelocity, leftVelocity, data)
+		if !sim.actuatorsEnabled("DirectDrive") {
+			break
+		}
+		sim.logger().Debug("DirectDrive", roomba.LogOpcode, opcode, "right", rigthVelocity, "left", leftVelocity)
+		sim.mu.Lock()
+		sim.RequestedRightVelocity = append([]byte(nil), data[:2]...)
+		sim.RequestedLeftVelocity = append([]byte(nil), data[2:4]...)
+		sim.mu.Unlock()
+		sim.setWheels(kinematics.WheelVelocities{
+			Right: float64(rigthVelocity), Left: float64(leftVelocity)})
+	case constants.OpCodes["DrivePwm"]:
+		data := sim.read(4)
+		var rightPWM, leftPWM int16
+		binary.Read(bytes.NewReader(data[:2]), binary.BigEndian, &rightPWM)
+		binary.Read(bytes.NewReader(data[2:4]), binary.BigEndian, &leftPWM)
+		if !sim.actuatorsEnabled("DrivePwm") {
+			break
+		}
+		sim.logger().Debug("DrivePwm", roomba.LogOpcode, opcode, "right", rightPWM, "left", leftPWM)
+		// Full PWM roughly corresponds to the maximum velocity.
+		sim.setWheels(kinematics.WheelVelocities{
+			Right: float64(rightPWM) * kinematics.MaxWheelVelocity / 255,
+			Left:  float64(leftPWM) * kinematics.MaxWheelVelocity / 255,
+		})
 	case constants.OpCodes["Drive"]:
-		sim.RequestedVelocity = sim.read(2)
-		sim.RequestedRadius = sim.read(2)
-		log.Printf("Drive: %d, %d", sim.RequestedVelocity, sim.RequestedRadius)
+		data := sim.read(4)
+		if !sim.actuatorsEnabled("Drive") {
+			break
+		}
+		var velocity, radius int16
+		binary.Read(bytes.NewReader(data[:2]), binary.BigEndian, &velocity)
+		binary.Read(bytes.NewReader(data[2:4]), binary.BigEndian, &radius)
+		sim.mu.Lock()
+		sim.RequestedVelocity = append([]byte(nil), data[:2]...)
+		sim.RequestedRadius = append([]byte(nil), data[2:4]...)
+		sim.mu.Unlock()
+		sim.logger().Debug("Drive", roomba.LogOpcode, opcode, "velocity", velocity, "radius", radius)
+		sim.setWheels(driveWheels(velocity, radius))
 	default:
-		log.Printf("unknown opcode: %d", cmdBuf[0])
+		sim.logger().Warn("unknown opcode", roomba.LogOpcode, opcode)
 	}
 
 	return nil
 }
 
+// sensorValue returns the value of the given sensor packet. Motion packets are
+// computed from the simulated motion, others come from MockSensorValues.
+func (sim *RoombaSimulator) sensorValue(packetId byte) ([]byte, bool) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	switch packetId {
+	case constants.SENSOR_REQUESTED_RADIUS:
+		return sim.RequestedRadius, true
+	case constants.SENSOR_REQUESTED_VELOCITY:
+		return sim.RequestedVelocity, true
+	case constants.SENSOR_REQUESTED_RIGHT_VELOCITY:
+		return sim.RequestedRightVelocity, true
+	case constants.SENSOR_REQUESTED_LEFT_VELOCITY:
+		return sim.RequestedLeftVelocity, true
+	case constants.SENSOR_OI_MODE:
+		return []byte{sim.mode}, true
+	case constants.SENSOR_NUM_STREAM_PACKETS:
+		return []byte{byte(len(sim.streamPackets))}, true
+	case constants.SENSOR_WHEEL_OVERCURRENT:
+		return []byte{sim.overcurrentBits()}, true
+	case constants.SENSOR_SONG_NUMBER:
+		return []byte{sim.songNumber}, true
+	case constants.SENSOR_SONG_PLAYING:
+		return []byte{boolByte(sim.songPlaying)}, true
+	}
+	if sim.world != nil {
+		if value, ok := worldSensorValue(packetId, sim.world.Sense(sim.physics.Pose)); ok {
+			return value, true
+		}
+	}
+	if value, ok := batterySensorValue(packetId, &sim.battery); ok {
+		return value, true
+	}
+	switch packetId {
+	case constants.SENSOR_DISTANCE:
+		return roomba.Pack([]interface{}{sim.physics.TakeDistance()}), true
+	case constants.SENSOR_ANGLE:
+		return roomba.Pack([]interface{}{sim.physics.TakeAngle()}), true
+	case constants.SENSOR_LEFT_ENCODER_COUNTS:
+		left, _ := sim.physics.EncoderCounts()
+		return roomba.Pack([]interface{}{left}), true
+	case constants.SENSOR_RIGHT_ENCODER_COUNTS:
+		_, right := sim.physics.EncoderCounts()
+		return roomba.Pack([]interface{}{right}), true
+	}
+	value, ok := MockSensorValues[packetId]
+	if !ok {
+		sim.logger().Warn("no mock value for sensor packet", roomba.LogPacketId, packetId)
+	}
+	return value, ok
+}
+
+// querySensor returns the value of the given sensor packet, or zeros if the
+// simulator has none, so that the host reads as many bytes as it expects.
+func (sim *RoombaSimulator) querySensor(packetId byte) []byte {
+	value, ok := sim.sensorValue(packetId)
+	if !ok {
+		value = make([]byte, constants.SENSOR_PACKET_LENGTH[packetId])
+	}
+	return value
+}
+
+// worldSensorValue returns the value of an environment sensor packet.
+func worldSensorValue(packetId byte, r Readings) ([]byte, bool) {
+	switch packetId {
+	case constants.SENSOR_BUMP_WHEELS_DROPS:
+		return []byte{r.BumpsAndWheelDrops()}, true
+	case constants.SENSOR_WALL:
+		return []byte{boolByte(r.Wall)}, true
+	case constants.SENSOR_CLIFF_LEFT, constants.SENSOR_CLIFF_FRONT_LEFT,
+		constants.SENSOR_CLIFF_FRONT_RIGHT, constants.SENSOR_CLIFF_RIGHT:
+		return []byte{boolByte(r.Cliffs[packetId-constants.SENSOR_CLIFF_LEFT])}, true
+	case constants.SENSOR_CLIFF_LEFT_SIGNAL, constants.SENSOR_CLIFF_FRONT_LEFT_SIGNAL,
+		constants.SENSOR_CLIFF_FRONT_RIGHT_SIGNAL, constants.SENSOR_CLIFF_RIGHT_SIGNAL:
+		signal := r.CliffSignals[packetId-constants.SENSOR_CLIFF_LEFT_SIGNAL]
+		return roomba.Pack([]interface{}{signal}), true
+	case constants.SENSOR_VIRTUAL_WALL:
+		return []byte{boolByte(r.VirtualWall)}, true
+	case constants.SENSOR_WALL_SIGNAL:
+		return roomba.Pack([]interface{}{r.WallSignal}), true
+	case constants.SENSOR_IR_OMNI:
+		return []byte{r.IROmni}, true
+	case constants.SENSOR_IR_LEFT:
+		return []byte{r.IRLeft}, true
+	case constants.SENSOR_IR_RIGHT:
+		return []byte{r.IRRight}, true
+	}
+	return nil, false
+}
+
+// batterySensorValue returns the value of a battery or charging packet.
+func batterySensorValue(packetId byte, b *Battery) ([]byte, bool) {
+	switch packetId {
+	case constants.SENSOR_CHARGING:
+		return []byte{b.State}, true
+	case constants.SENSOR_VOLTAGE:
+		return roomba.Pack([]interface{}{uint16(math.Round(b.Voltage))}), true
+	case constants.SENSOR_CURRENT:
+		return roomba.Pack([]interface{}{int16(math.Round(b.Current))}), true
+	case constants.SENSOR_TEMPERATURE:
+		return []byte{byte(int8(math.Round(b.Temperature)))}, true
+	case constants.SENSOR_BATTERY_CHARGE:
+		return roomba.Pack([]interface{}{uint16(math.Round(b.Charge))}), true
+	case constants.SENSOR_BATTERY_CAPACITY:
+		return roomba.Pack([]interface{}{uint16(math.Round(b.Capacity))}), true
+	case constants.SENSOR_CHARGING_SOURCE:
+		return []byte{b.Source}, true
+	}
+	return nil, false
+}
+
+func boolByte(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// driveWheels converts the arguments of a Drive command to wheel velocities.
+func driveWheels(velocity, radius int16) kinematics.WheelVelocities {
+	v := float64(velocity)
+	switch {
+	case radius == constants.DRIVE_STRAIGHT || radius == -32768 || radius == 0:
+		return kinematics.WheelVelocities{Right: v, Left: v}
+	case radius == constants.DRIVE_SPIN_CCW:
+		return kinematics.WheelVelocities{Right: v, Left: -v}
+	case radius == constants.DRIVE_SPIN_CW:
+		return kinematics.WheelVelocities{Right: -v, Left: v}
+	}
+	return kinematics.Twist{
+		Linear:  v / 1000,
+		Angular: v / float64(radius),
+	}.Wheels()
+}
+
+func (sim *RoombaSimulator) setWheels(w kinematics.WheelVelocities) {
+	sim.mu.Lock()
+	sim.physics.Target = w
+	sim.mu.Unlock()
+}
+
+// PhysicsStep is the largest time step of the simulation, matching the rate
+// at which Roomba updates its sensors.
+const PhysicsStep = 15 * time.Millisecond
+
+// Advance advances the simulation by d of simulated time.
+func (sim *RoombaSimulator) Advance(d time.Duration) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	for d > 0 {
+		step := d
+		if step > PhysicsStep {
+			step = PhysicsStep
+		}
+		prev := sim.physics.Pose
+		sim.physics.Step(step)
+		if sim.world != nil && sim.world.Collides(sim.physics.Pose) {
+			// Blocked by an obstacle: the wheels slip, so the distance
+			// and encoders still count, but the robot only turns.
+			sim.physics.Pose.X, sim.physics.Pose.Y = prev.X, prev.Y
+		}
+		sim.checkSafety()
+		sim.stepBattery(step)
+		d -= step
+	}
+}
+
+// stepBattery advances the battery model by dt. Must be called with sim.mu
+// held.
+func (sim *RoombaSimulator) stepBattery(dt time.Duration) {
+	docked := sim.world != nil && sim.world.Docked(sim.physics.Pose)
+	canCharge := sim.mode == constants.OI_MODE_OFF || sim.mode == constants.OI_MODE_PASSIVE
+	load := Load{Wheels: sim.physics.Wheels, Motors: sim.motors}
+	prev := sim.battery.State
+	sim.battery.Step(dt, load, docked, canCharge)
+	if sim.battery.State != prev {
+		sim.logger().Info("charging state changed", "state", sim.battery.State)
+	}
+	if sim.battery.Empty() && sim.mode != constants.OI_MODE_OFF {
+		sim.logger().Warn("battery empty, turning off")
+		sim.setMode(constants.OI_MODE_OFF)
+		sim.physics.Wheels = kinematics.WheelVelocities{}
+	}
+}
+
+// Mode returns the current OI mode, one of constants.OI_MODE_*.
+func (sim *RoombaSimulator) Mode() byte {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	return sim.mode
+}
+
+// SetMode switches the OI mode, as if the corresponding command was received.
+// Switching to Off or Passive stops the wheels.
+func (sim *RoombaSimulator) SetMode(mode byte) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	sim.setMode(mode)
+}
+
+// setMode must be called with sim.mu held.
+func (sim *RoombaSimulator) setMode(mode byte) {
+	sim.logger().Info("switched mode", "mode", mode)
+	if sim.mode == constants.OI_MODE_PASSIVE && (mode == constants.OI_MODE_SAFE || mode == constants.OI_MODE_FULL) {
+		// Taking control stops cleaning.
+		sim.motors = 0
+	}
+	sim.mode = mode
+	if mode == constants.OI_MODE_OFF || mode == constants.OI_MODE_PASSIVE {
+		sim.physics.Target = kinematics.WheelVelocities{}
+	}
+	if mode == constants.OI_MODE_OFF {
+		sim.motors = 0
+	}
+}
+
+// actuatorsEnabled reports whether actuator commands are accepted in the
+// current mode, logging the command otherwise.
+func (sim *RoombaSimulator) actuatorsEnabled(command string) bool {
+	mode := sim.Mode()
+	if mode == constants.OI_MODE_SAFE || mode == constants.OI_MODE_FULL {
+		return true
+	}
+	sim.logger().Warn("ignoring command in current mode", "command", command, "mode", mode)
+	return false
+}
+
+// play starts playing a stored song for the sum of its note durations.
+// Like on the robot, a song can't be started while another one is playing.
+func (sim *RoombaSimulator) play(song byte) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	notes, ok := sim.songs[song]
+	if !ok || sim.songPlaying {
+		sim.logger().Warn("not playing song", "song", song, "stored", ok, "playing", sim.songPlaying)
+		return
+	}
+	var duration time.Duration
+	for i := 1; i < len(notes); i += 2 {
+		// Durations are in 1/64 s.
+		duration += time.Duration(notes[i]) * time.Second / 64
+	}
+	sim.logger().Debug("playing song", "song", song, "duration", duration)
+	sim.songNumber, sim.songPlaying = song, true
+	sim.clock.AfterFunc(duration, func() {
+		sim.mu.Lock()
+		sim.songPlaying = false
+		sim.mu.Unlock()
+	})
+}
+
+// checkSafety drops from Safe to Passive mode on wheel drop or on a cliff
+// while driving forward. Must be called with sim.mu held.
+func (sim *RoombaSimulator) checkSafety() {
+	if sim.mode != constants.OI_MODE_SAFE || sim.world == nil {
+		return
+	}
+	r := sim.world.Sense(sim.physics.Pose)
+	cliff := r.Cliffs[0] || r.Cliffs[1] || r.Cliffs[2] || r.Cliffs[3]
+	forward := sim.physics.Wheels.Right+sim.physics.Wheels.Left > 0
+	if r.WheelDropLeft || r.WheelDropRight || (cliff && forward) {
+		sim.logger().Warn("safety feature triggered", "readings", r)
+		sim.setMode(constants.OI_MODE_PASSIVE)
+		// Unlike a drive command, the robot stops at once.
+		sim.physics.Wheels = kinematics.WheelVelocities{}
+	}
+}
+
+// SetWorld sets the environment of the simulated robot. Without a world,
+// environment sensors return MockSensorValues.
+func (sim *RoombaSimulator) SetWorld(w *World) {
+	sim.mu.Lock()
+	sim.world = w
+	sim.mu.Unlock()
+}
+
+// SetPose moves the simulated robot.
+func (sim *RoombaSimulator) SetPose(p kinematics.Pose) {
+	sim.mu.Lock()
+	sim.physics.Pose = p
+	sim.mu.Unlock()
+}
+
+// Physics returns a snapshot of the simulated motion.
+func (sim *RoombaSimulator) Physics() Physics {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	return sim.physics
+}
+
+// SetWheelAccel sets the wheel acceleration limit (mm/s^2) of the simulated
+// robot. Zero, the default, means the wheels reach the requested velocity
+// instantly.
+func (sim *RoombaSimulator) SetWheelAccel(accel float64) {
+	sim.mu.Lock()
+	sim.physics.WheelAccel = accel
+	sim.mu.Unlock()
+}
+
+// Battery returns a snapshot of the simulated battery.
+func (sim *RoombaSimulator) Battery() Battery {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	return sim.battery
+}
+
+// SetBattery replaces the simulated battery, e.g. to start with a low charge.
+func (sim *RoombaSimulator) SetBattery(b Battery) {
+	sim.mu.Lock()
+	sim.battery = b
+	sim.mu.Unlock()
+}
+
+// Motors returns the cleaning motors that are running, as bits of
+// constants.MOTOR_*.
+func (sim *RoombaSimulator) Motors() byte {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	return sim.motors
+}
+
+// Pose returns the simulated pose of the robot. The simulator starts at the
+// origin facing along the X axis.
+func (sim *RoombaSimulator) Pose() kinematics.Pose {
+	return sim.Physics().Pose
+}
+
+// SetLogger sets the logger receiving the simulator's traffic at Debug level
+// and its state changes, with the fields of the roomba package, e.g.
+// roomba.LogOpcode. Nothing is logged by default.
+func (sim *RoombaSimulator) SetLogger(l *slog.Logger) {
+	sim.log.Store(l)
+}
+
+var discardLogger = slog.New(slog.DiscardHandler)
+
+func (sim *RoombaSimulator) logger() *slog.Logger {
+	if l := sim.log.Load(); l != nil {
+		return l
+	}
+	return discardLogger
+}
+
 // Reads given number of bytes from the Reader sim.rw.
 func (sim *RoombaSimulator) read(n int) []byte {
 	buf := make([]byte, n)
-	nRead, err := sim.rw.Read(buf)
+	nRead, err := io.ReadFull(sim.rw, buf)
 	if n != nRead {
 		if err != nil {
-			log.Printf("error reading in RoombaSimulator: %v", err)
+			sim.logger().Debug("error reading", "err", err)
 		}
-		//log.Printf("read %d bytes when expected %d", nRead, n)
 		return []byte{}
 	}
-	log.Printf("roomba reads: %v", buf)
+	sim.logger().Debug("read", roomba.LogDirection, roomba.HostToRobot, "data", buf)
+	sim.logMu.Lock()
 	sim.ReadBytes.Write(buf)
+	sim.received = append(sim.received, buf...)
+	sim.logMu.Unlock()
 	return buf
 }
 
+// Received returns all the bytes received by the simulator so far.
+func (sim *RoombaSimulator) Received() []byte {
+	sim.logMu.Lock()
+	defer sim.logMu.Unlock()
+	return append([]byte(nil), sim.received...)
+}
+
+// Sent returns all the bytes sent by the simulator so far.
+func (sim *RoombaSimulator) Sent() []byte {
+	sim.logMu.Lock()
+	defer sim.logMu.Unlock()
+	return append([]byte(nil), sim.sent...)
+}
+
 // Writes bytes to the Writer w asynchronously.
 func (sim *RoombaSimulator) write(b []byte) {
-	log.Printf("roomba says: %v", b)
-	sim.writeQ <- b
+	sim.logger().Debug("write", roomba.LogDirection, roomba.RobotToHost, "data", b)
+	select {
+	case sim.writeQ <- b:
+	case <-sim.quit:
+	}
 }
 
 // Helper for merging reader and writer into a ReadWriter.
@@ -195,32 +806,41 @@ type readWriter struct {
 	io.Writer
 }
 
+// MakeRoombaSim creates a simulator running in real time.
 func MakeRoombaSim() (*RoombaSimulator, *readWriter) {
+	return MakeRoombaSimClock(clock.Real)
+}
+
+// MakeRoombaSimClock creates a simulator whose physics and stream run on the
+// given clock.
+func MakeRoombaSimClock(c clock.Clock) (*RoombaSimulator, *readWriter) {
 	// Input: driver writes, simulator reads.
-	inp_r, inp_w := io.Pipe()
+	inp := makeSyncPipe()
 
 	// Ouput: simulator writes, driver reads.
 	out_r, out_w := io.Pipe()
 
-	readBytes := &bytes.Buffer{}
-	writtenBytes := &bytes.Buffer{}
-
 	sim := &RoombaSimulator{
-		rw: &readWriter{
-			// Log all read bytes to ReadBytes.
-			io.TeeReader(inp_r, readBytes),
-			// Log all written bytes to writtenBytes.
-			io.MultiWriter(out_w, writtenBytes),
-		},
-		writeQ:    make(chan []byte, 15),
-		ReadBytes: *readBytes,
-
-		RequestedRadius:   []byte{0, 0},
-		RequestedVelocity: []byte{0, 0},
+		rw:     &readWriter{inp, out_w},
+		clock:  c,
+		writeQ: make(chan []byte, 15),
+
+		RequestedRadius:        []byte{0, 0},
+		RequestedVelocity:      []byte{0, 0},
+		RequestedRightVelocity: []byte{0, 0},
+		RequestedLeftVelocity:  []byte{0, 0},
+
+		baud:    115200,
+		battery: MakeBattery(DefaultBatteryCapacity, 0.8*DefaultBatteryCapacity),
+		songs:   map[byte][]byte{},
+		quit:    make(chan struct{}),
+		closers: []io.Closer{inp, out_w},
 	}
 	go sim.serve()
+	sim.runPhysics()
+	sim.runStream()
 
-	rw := &readWriter{out_r, inp_w}
+	rw := &readWriter{out_r, inp}
 
 	return sim, rw
 }
@@ -0,0 +1,274 @@
+package sim
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/xa4a/go-roomba"
+	"github.com/xa4a/go-roomba/clock"
+	"github.com/xa4a/go-roomba/constants"
+	"github.com/xa4a/go-roomba/kinematics"
+)
+
+func makeTestClient() (*RoombaSimulator, *roomba.Roomba) {
+	sim, socket := MakeRoombaSim()
+	return sim, &roomba.Roomba{S: socket, StreamPaused: make(chan bool, 1)}
+}
+
+func queryMode(t *testing.T, r *roomba.Roomba) byte {
+	mode, err := r.Sensors(constants.SENSOR_OI_MODE)
+	if err != nil {
+		t.Fatalf("error querying mode: %s", err)
+	}
+	return mode[0]
+}
+
+func TestModes(t *testing.T) {
+	sim, r := makeTestClient()
+	defer sim.Stop()
+
+	// Not started, the command is dropped.
+	r.DriveStraight(100)
+	r.Start()
+	if mode := queryMode(t, r); mode != constants.OI_MODE_PASSIVE {
+		t.Errorf("expected passive mode after start, got %d", mode)
+	}
+	r.DriveStraight(100)
+	velocity, _ := r.Sensors(constants.SENSOR_REQUESTED_VELOCITY)
+	if velocity[0] != 0 || velocity[1] != 0 {
+		t.Errorf("drive accepted in passive mode: %v", velocity)
+	}
+	r.Full()
+	if mode := queryMode(t, r); mode != constants.OI_MODE_FULL {
+		t.Errorf("expected full mode, got %d", mode)
+	}
+	r.Safe()
+	r.DriveStraight(100)
+	velocity, _ = r.Sensors(constants.SENSOR_REQUESTED_VELOCITY)
+	if velocity[0] != 0 || velocity[1] != 100 {
+		t.Errorf("drive ignored in safe mode: %v", velocity)
+	}
+	r.Power()
+	if mode := queryMode(t, r); mode != constants.OI_MODE_PASSIVE {
+		t.Errorf("expected passive mode after power, got %d", mode)
+	}
+
+	// Taking control stops cleaning.
+	r.Clean()
+	if sim.Motors() == 0 {
+		t.Errorf("cleaning motors not running after clean")
+	}
+	r.Safe()
+	if motors := sim.Motors(); motors != 0 {
+		t.Errorf("cleaning motors %b running in safe mode", motors)
+	}
+}
+
+func TestUnknownSensorValue(t *testing.T) {
+	sim, r := makeTestClient()
+	defer sim.Stop()
+	r.Start()
+	// No mock value, the simulator answers with zeros.
+	values, err := r.QueryList([]byte{constants.SENSOR_DIRT_DETECT, constants.SENSOR_OI_MODE})
+	if err != nil {
+		t.Fatalf("error querying sensors: %s", err)
+	}
+	if values[0][0] != 0 || values[1][0] != constants.OI_MODE_PASSIVE {
+		t.Errorf("got values %v", values)
+	}
+}
+
+func TestSafeModeCliff(t *testing.T) {
+	sim, r := makeTestClient()
+	defer sim.Stop()
+	sim.SetWorld(loadTestWorld(t))
+	sim.SetPose(kinematics.Pose{X: 2, Y: -0.5})
+
+	r.Start()
+	r.Safe()
+	r.DriveStraight(200)
+	// Wait for the command to be processed.
+	queryMode(t, r)
+	sim.Advance(5 * time.Second)
+	if mode := queryMode(t, r); mode != constants.OI_MODE_PASSIVE {
+		t.Errorf("expected passive mode at the cliff, got %d", mode)
+	}
+	// Front cliff sensors are 20 degrees off the heading.
+	if pose := sim.Pose(); pose.X > 2.5-RobotRadius*0.94+0.01 {
+		t.Errorf("robot didn't stop at the cliff: %+v", pose)
+	}
+}
+
+func TestSongs(t *testing.T) {
+	clk := clock.MakeVirtual(time.Unix(0, 0))
+	sim, socket := MakeRoombaSimClock(clk)
+	defer sim.Stop()
+	r := &roomba.Roomba{S: socket, StreamPaused: make(chan bool, 1)}
+	r.Start()
+	r.Song(2, []roomba.Note{{Number: 60, Duration: 32}, {Number: 64, Duration: 32}})
+	querySong := func() []byte {
+		song, err := r.QueryList([]byte{constants.SENSOR_SONG_NUMBER, constants.SENSOR_SONG_PLAYING})
+		if err != nil {
+			t.Fatalf("error querying song: %s", err)
+		}
+		return []byte{song[0][0], song[1][0]}
+	}
+
+	// Not played in Passive mode.
+	r.Play(2)
+	if song := querySong(); song[1] != 0 {
+		t.Errorf("song played in passive mode: %v", song)
+	}
+	r.Safe()
+	r.Play(2)
+	clk.Advance(time.Second - time.Millisecond)
+	if song := querySong(); !bytes.Equal(song, []byte{2, 1}) {
+		t.Errorf("expected song 2 playing, got %v", song)
+	}
+	clk.Advance(time.Millisecond)
+	if song := querySong(); !bytes.Equal(song, []byte{2, 0}) {
+		t.Errorf("expected song 2 played, got %v", song)
+	}
+}
+
+func TestContinuousStream(t *testing.T) {
+	sim, r := makeTestClient()
+	defer sim.Stop()
+	r.Start()
+
+	frames, err := r.Stream([]byte{constants.SENSOR_OI_MODE})
+	if err != nil {
+		t.Fatalf("error starting stream: %s", err)
+	}
+	for i := 0; i < 3; i++ {
+		select {
+		case frame := <-frames:
+			if frame[0][0] != constants.OI_MODE_PASSIVE {
+				t.Errorf("unexpected frame %v", frame)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("only received %d frames", i)
+		}
+	}
+	r.PauseStream()
+	// Drain frames until the client closes the channel.
+	for range frames {
+	}
+	if frame := sim.streamFrame(); frame != nil {
+		t.Errorf("stream not paused, next frame %v", frame)
+	}
+}
+
+// Run with -race: the stream reads the requested velocity while commands set
+// it.
+func TestStreamWhileDriving(t *testing.T) {
+	clk := clock.MakeVirtual(time.Unix(0, 0))
+	sim, socket := MakeRoombaSimClock(clk)
+	defer sim.Stop()
+	// Start, Safe, and stream many copies of the requested velocity, to
+	// read it often.
+	socket.Write([]byte{128, 131, 148, 50})
+	socket.Write(bytes.Repeat([]byte{constants.SENSOR_REQUESTED_VELOCITY}, 50))
+	go io.Copy(io.Discard, socket)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for v := 0; v < 2000; v++ {
+			socket.Write([]byte{137, byte(v % 500 >> 8), byte(v % 500), 1, 244})
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		default:
+			clk.Advance(StreamPeriod)
+		}
+	}
+}
+
+func TestStreamBandwidth(t *testing.T) {
+	clk := clock.MakeVirtual(time.Unix(0, 0))
+	sim, socket := MakeRoombaSimClock(clk)
+	defer sim.Stop()
+	// Start at 9600 baud and stream 30 voltage packets.
+	cmd := []byte{128, 129, 5, 148, 30}
+	for i := 0; i < 30; i++ {
+		cmd = append(cmd, constants.SENSOR_VOLTAGE)
+	}
+	socket.Write(cmd)
+
+	// 90 bytes of packets and 3 of header and checksum take 96.875 ms, so
+	// frames are only sent every 7 periods. After each period, a query
+	// marks the end of what the stream sent.
+	frame := make([]byte, 93)
+	mode := make([]byte, 1)
+	for tick := 1; tick <= 16; tick++ {
+		clk.Advance(StreamPeriod)
+		socket.Write([]byte{142, constants.SENSOR_OI_MODE})
+		if tick%7 == 1 {
+			if _, err := io.ReadFull(socket, frame); err != nil {
+				t.Fatalf("error reading frame: %s", err)
+			}
+			if frame[0] != 19 || frame[1] != 90 {
+				t.Fatalf("unexpected frame at tick %d: %v", tick, frame)
+			}
+		}
+		if _, err := io.ReadFull(socket, mode); err != nil {
+			t.Fatalf("error reading mode: %s", err)
+		}
+		if mode[0] != constants.OI_MODE_PASSIVE {
+			t.Fatalf("unexpected data at tick %d: %v", tick, mode)
+		}
+	}
+}
+
+// runScripted drives a simulator on a virtual clock and returns everything it
+// sent.
+func runScripted(t *testing.T) []byte {
+	clk := clock.MakeVirtual(time.Unix(0, 0))
+	sim, socket := MakeRoombaSimClock(clk)
+	defer sim.Stop()
+	sim.SetWheelAccel(500)
+
+	// Start, Safe, drive straight at 200 mm/s, then stream distance and
+	// angle.
+	socket.Write([]byte{128, 131})
+	socket.Write([]byte{137, 0, 200, 127, 255})
+	socket.Write([]byte{148, 2, constants.SENSOR_DISTANCE, constants.SENSOR_ANGLE})
+
+	var transcript []byte
+	frame := make([]byte, 9)
+	for i := 0; i < 40; i++ {
+		clk.Advance(StreamPeriod)
+		if _, err := io.ReadFull(socket, frame); err != nil {
+			t.Fatalf("error reading frame %d: %s", i, err)
+		}
+		transcript = append(transcript, frame...)
+	}
+	if got := clk.Now().Sub(time.Unix(0, 0)); sim.Physics().Elapsed != got {
+		t.Errorf("simulated %v in %v of virtual time", sim.Physics().Elapsed, got)
+	}
+	return transcript
+}
+
+func TestVirtualClockTranscript(t *testing.T) {
+	first := runScripted(t)
+	second := runScripted(t)
+	if !bytes.Equal(first, second) {
+		t.Errorf("transcripts differ:\n%v\n%v", first, second)
+	}
+
+	var distance int
+	for i := 0; i < len(first); i += 9 {
+		distance += int(int16(binary.BigEndian.Uint16(first[i+3:])))
+	}
+	// 0.4 s at 500 mm/s^2 to reach 200 mm/s, then 0.2 s at full speed.
+	if distance < 79 || distance > 80 {
+		t.Errorf("streamed distance %d mm, want 80", distance)
+	}
+}
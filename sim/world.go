@@ -0,0 +1,348 @@
+package sim
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/xa4a/go-roomba/constants"
+	"github.com/xa4a/go-roomba/kinematics"
+)
+
+// Robot geometry used for sensing, in meters and radians.
+const (
+	RobotRadius = 0.17
+
+	// Obstacles closer than this to the robot's edge press the bumper.
+	bumpMargin = 0.01
+	// The wall sensor looks to the right of the robot and sees walls up
+	// to this distance.
+	wallSensorRange = 0.1
+	// Half-width of a virtual wall beam.
+	beamWidth = 0.05
+	// Distance from the docking position and heading error within which
+	// the robot touches the home base's charging contacts.
+	dockTolerance        = 0.03
+	dockHeadingTolerance = math.Pi / 12
+)
+
+// Cliff sensors, in the order of the SENSOR_CLIFF_* packets: left, front
+// left, front right and right. Angles are relative to the heading.
+var cliffSensorAngles = [4]float64{
+	math.Pi / 3, math.Pi / 9, -math.Pi / 9, -math.Pi / 3,
+}
+
+// Cliff signal strength over the floor and over a cliff.
+const (
+	floorSignal = 1800
+	cliffSignal = 8
+)
+
+// Point is a position in the world, in meters.
+type Point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Polygon is a closed polygon; the last vertex connects to the first.
+type Polygon []Point
+
+// VirtualWall is a Roomba 500 Virtual Wall. Its beam starts at Position and
+// extends Range meters along Heading.
+type VirtualWall struct {
+	Position Point   `json:"position"`
+	Heading  float64 `json:"heading"` // rad
+	Range    float64 `json:"range"`   // m
+}
+
+// HomeBase is the Roomba dock. Its front faces along Heading. Looking out of
+// the dock, the red buoy covers the right side and the green buoy the left,
+// overlapping in the middle, up to Range meters away. The force field
+// surrounds the dock up to ForceFieldRange meters.
+type HomeBase struct {
+	Position        Point   `json:"position"`
+	Heading         float64 `json:"heading"`           // rad
+	Range           float64 `json:"range"`             // m
+	ForceFieldRange float64 `json:"force_field_range"` // m
+}
+
+// World describes the environment of the simulated robot. Coordinates are in
+// meters in the same frame as the simulated pose.
+type World struct {
+	// Walls and obstacles block the robot. Walls are usually the outline
+	// of the room, obstacles are furniture inside it.
+	Walls     []Polygon `json:"walls"`
+	Obstacles []Polygon `json:"obstacles"`
+
+	// Cliffs are areas without floor, e.g. stairs.
+	Cliffs []Polygon `json:"cliffs"`
+
+	VirtualWalls []VirtualWall `json:"virtual_walls"`
+	HomeBase     *HomeBase     `json:"home_base"`
+}
+
+// ParseWorld parses a JSON world description.
+func ParseWorld(data []byte) (*World, error) {
+	w := &World{}
+	if err := json.Unmarshal(data, w); err != nil {
+		return nil, fmt.Errorf("invalid world description: %s", err)
+	}
+	return w, nil
+}
+
+// LoadWorld reads a JSON world description from a file.
+func LoadWorld(path string) (*World, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseWorld(data)
+}
+
+// Readings are the values of the environment sensors at a given pose.
+type Readings struct {
+	BumpLeft, BumpRight           bool
+	WheelDropLeft, WheelDropRight bool
+	Cliffs                        [4]bool   // Left, front left, front right, right.
+	CliffSignals                  [4]uint16 // Same order as Cliffs.
+	Wall                          bool
+	WallSignal                    uint16
+	VirtualWall                   bool
+	IROmni, IRLeft, IRRight       byte
+}
+
+// BumpsAndWheelDrops returns the value of the SENSOR_BUMP_WHEELS_DROPS packet.
+func (r Readings) BumpsAndWheelDrops() byte {
+	var b byte
+	for i, set := range []bool{r.BumpRight, r.BumpLeft, r.WheelDropRight, r.WheelDropLeft} {
+		if set {
+			b |= 1 << uint(i)
+		}
+	}
+	return b
+}
+
+// edges calls f for every edge of the blocking polygons.
+func (w *World) edges(f func(a, b Point)) {
+	for _, polygons := range [][]Polygon{w.Walls, w.Obstacles} {
+		for _, p := range polygons {
+			for i := range p {
+				f(p[i], p[(i+1)%len(p)])
+			}
+		}
+	}
+}
+
+// Collides reports whether the robot at pose overlaps a wall or obstacle.
+func (w *World) Collides(pose kinematics.Pose) bool {
+	c := Point{pose.X, pose.Y}
+	collides := false
+	w.edges(func(a, b Point) {
+		if distance(c, closestOnSegment(c, a, b)) < RobotRadius {
+			collides = true
+		}
+	})
+	return collides
+}
+
+// Sense computes the sensor readings of the robot at pose.
+func (w *World) Sense(pose kinematics.Pose) Readings {
+	var r Readings
+	c := Point{pose.X, pose.Y}
+
+	// Bumper covers the front half of the robot.
+	w.edges(func(a, b Point) {
+		p := closestOnSegment(c, a, b)
+		if distance(c, p) > RobotRadius+bumpMargin {
+			return
+		}
+		bearing := bearingTo(pose, p)
+		switch {
+		case math.Abs(bearing) < math.Pi/18:
+			r.BumpLeft, r.BumpRight = true, true
+		case bearing > 0 && bearing <= math.Pi/2:
+			r.BumpLeft = true
+		case bearing < 0 && bearing >= -math.Pi/2:
+			r.BumpRight = true
+		}
+	})
+
+	if w.inCliff(c) {
+		r.WheelDropLeft, r.WheelDropRight = true, true
+	}
+	for i, angle := range cliffSensorAngles {
+		r.CliffSignals[i] = floorSignal
+		if w.inCliff(offset(pose, angle, RobotRadius)) {
+			r.Cliffs[i] = true
+			r.CliffSignals[i] = cliffSignal
+		}
+	}
+
+	// The wall sensor sits on the right side and looks to the right.
+	sensor := offset(pose, -math.Pi/2, RobotRadius)
+	end := offset(pose, -math.Pi/2, RobotRadius+wallSensorRange)
+	if d, ok := w.raycast(sensor, end); ok {
+		r.WallSignal = uint16(math.Round(1023 * (1 - d/wallSensorRange)))
+		r.Wall = r.WallSignal > 100
+	}
+
+	w.senseIR(pose, &r)
+	return r
+}
+
+// raycast returns the distance from a to the first blocking edge crossing
+// segment a-b.
+func (w *World) raycast(a, b Point) (float64, bool) {
+	best, found := math.Inf(1), false
+	w.edges(func(p, q Point) {
+		if x, ok := intersect(a, b, p, q); ok {
+			if d := distance(a, x); d < best {
+				best, found = d, true
+			}
+		}
+	})
+	return best, found
+}
+
+func (w *World) inCliff(p Point) bool {
+	for _, cliff := range w.Cliffs {
+		if cliff.Contains(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// senseIR fills in the IR characters received from virtual walls and the home
+// base. The omnidirectional receiver sees every source around the robot, the
+// left and right receivers only those in front on their side.
+func (w *World) senseIR(pose kinematics.Pose, r *Readings) {
+	c := Point{pose.X, pose.Y}
+	receive := func(char byte, source Point) {
+		r.IROmni |= char
+		bearing := bearingTo(pose, source)
+		if -math.Pi/12 <= bearing && bearing <= 5*math.Pi/12 {
+			r.IRLeft |= char
+		}
+		if -5*math.Pi/12 <= bearing && bearing <= math.Pi/12 {
+			r.IRRight |= char
+		}
+	}
+
+	for _, vw := range w.VirtualWalls {
+		end := Point{
+			vw.Position.X + vw.Range*math.Cos(vw.Heading),
+			vw.Position.Y + vw.Range*math.Sin(vw.Heading),
+		}
+		p := closestOnSegment(c, vw.Position, end)
+		if distance(c, p) <= RobotRadius+beamWidth {
+			r.VirtualWall = true
+			receive(constants.IR_VIRTUAL_WALL, p)
+		}
+	}
+	if r.VirtualWall {
+		// The virtual wall character can't be combined with the home
+		// base ones, the beam takes precedence.
+		return
+	}
+
+	if hb := w.HomeBase; hb != nil {
+		// Robot position in the dock's frame.
+		dx, dy := c.X-hb.Position.X, c.Y-hb.Position.Y
+		x := dx*math.Cos(hb.Heading) + dy*math.Sin(hb.Heading)
+		y := -dx*math.Sin(hb.Heading) + dy*math.Cos(hb.Heading)
+		dist := math.Hypot(x, y)
+		if dist <= hb.ForceFieldRange {
+			receive(constants.IR_FORCE_FIELD, hb.Position)
+		}
+		// Buoys are visible in front of the dock, within 60 degrees.
+		if dist <= hb.Range && x > 0 && math.Abs(math.Atan2(y, x)) <= math.Pi/3 {
+			if y <= beamWidth {
+				receive(constants.IR_RED_BUOY, hb.Position)
+			}
+			if y >= -beamWidth {
+				receive(constants.IR_GREEN_BUOY, hb.Position)
+			}
+		}
+	}
+}
+
+// DockingPose returns the pose of the robot sitting on the home base: in
+// front of the dock, facing it.
+func (hb *HomeBase) DockingPose() kinematics.Pose {
+	return kinematics.Pose{
+		X:     hb.Position.X + RobotRadius*math.Cos(hb.Heading),
+		Y:     hb.Position.Y + RobotRadius*math.Sin(hb.Heading),
+		Theta: kinematics.NormalizeAngle(hb.Heading + math.Pi),
+	}
+}
+
+// Docked reports whether the robot at pose sits on the home base.
+func (w *World) Docked(pose kinematics.Pose) bool {
+	if w.HomeBase == nil {
+		return false
+	}
+	dock := w.HomeBase.DockingPose()
+	return math.Hypot(pose.X-dock.X, pose.Y-dock.Y) <= dockTolerance &&
+		math.Abs(kinematics.NormalizeAngle(pose.Theta-dock.Theta)) <= dockHeadingTolerance
+}
+
+// Contains reports whether p is inside the polygon.
+func (poly Polygon) Contains(p Point) bool {
+	inside := false
+	for i := range poly {
+		a, b := poly[i], poly[(i+1)%len(poly)]
+		if (a.Y > p.Y) != (b.Y > p.Y) &&
+			p.X < a.X+(p.Y-a.Y)*(b.X-a.X)/(b.Y-a.Y) {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+func distance(a, b Point) float64 {
+	return math.Hypot(a.X-b.X, a.Y-b.Y)
+}
+
+// closestOnSegment returns the point of segment a-b closest to p.
+func closestOnSegment(p, a, b Point) Point {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	l := dx*dx + dy*dy
+	if l == 0 {
+		return a
+	}
+	t := ((p.X-a.X)*dx + (p.Y-a.Y)*dy) / l
+	t = math.Max(0, math.Min(1, t))
+	return Point{a.X + t*dx, a.Y + t*dy}
+}
+
+// intersect returns the intersection of segments a-b and c-d.
+func intersect(a, b, c, d Point) (Point, bool) {
+	rx, ry := b.X-a.X, b.Y-a.Y
+	sx, sy := d.X-c.X, d.Y-c.Y
+	denom := rx*sy - ry*sx
+	if denom == 0 {
+		return Point{}, false
+	}
+	t := ((c.X-a.X)*sy - (c.Y-a.Y)*sx) / denom
+	u := ((c.X-a.X)*ry - (c.Y-a.Y)*rx) / denom
+	if t < 0 || t > 1 || u < 0 || u > 1 {
+		return Point{}, false
+	}
+	return Point{a.X + t*rx, a.Y + t*ry}, true
+}
+
+// offset returns the point at distance d from the robot's center in the
+// direction angle, relative to its heading.
+func offset(pose kinematics.Pose, angle, d float64) Point {
+	return Point{
+		pose.X + d*math.Cos(pose.Theta+angle),
+		pose.Y + d*math.Sin(pose.Theta+angle),
+	}
+}
+
+// bearingTo returns the direction of p relative to the robot's heading.
+func bearingTo(pose kinematics.Pose, p Point) float64 {
+	return kinematics.NormalizeAngle(math.Atan2(p.Y-pose.Y, p.X-pose.X) - pose.Theta)
+}
@@ -0,0 +1,91 @@
+package sim
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/xa4a/go-roomba/constants"
+	"github.com/xa4a/go-roomba/kinematics"
+)
+
+func loadTestWorld(t *testing.T) *World {
+	w, err := LoadWorld("testdata/room.json")
+	if err != nil {
+		t.Fatalf("failed loading world: %s", err)
+	}
+	return w
+}
+
+func TestSenseOpenFloor(t *testing.T) {
+	r := loadTestWorld(t).Sense(kinematics.Pose{X: 2, Y: -0.5})
+	expected := Readings{CliffSignals: [4]uint16{floorSignal, floorSignal, floorSignal, floorSignal}}
+	if r != expected {
+		t.Errorf("unexpected readings on open floor: %+v", r)
+	}
+}
+
+func TestSenseBump(t *testing.T) {
+	w := loadTestWorld(t)
+	r := w.Sense(kinematics.Pose{X: 3 - RobotRadius - 0.005})
+	if !r.BumpLeft || !r.BumpRight {
+		t.Errorf("expected both bumpers pressed against the wall: %+v", r)
+	}
+	r = w.Sense(kinematics.Pose{X: 3 - RobotRadius - 0.005, Theta: math.Pi / 4})
+	if r.BumpLeft || !r.BumpRight || r.BumpsAndWheelDrops() != 1 {
+		t.Errorf("expected right bumper pressed: %+v", r)
+	}
+}
+
+func TestSenseCliff(t *testing.T) {
+	r := loadTestWorld(t).Sense(kinematics.Pose{X: 2.4, Y: -0.5})
+	expected := [4]bool{false, true, true, false}
+	if r.Cliffs != expected || r.CliffSignals[1] != cliffSignal {
+		t.Errorf("expected front cliff sensors over the cliff: %+v", r)
+	}
+	if r.WheelDropLeft || r.WheelDropRight {
+		t.Errorf("unexpected wheel drop: %+v", r)
+	}
+}
+
+func TestSenseWall(t *testing.T) {
+	r := loadTestWorld(t).Sense(kinematics.Pose{X: 0, Y: -0.78})
+	if !r.Wall || r.WallSignal != 512 {
+		t.Errorf("expected wall on the right: %+v", r)
+	}
+}
+
+func TestSenseIR(t *testing.T) {
+	w := loadTestWorld(t)
+	r := w.Sense(kinematics.Pose{X: 1, Y: 1.2})
+	if !r.VirtualWall || r.IROmni != constants.IR_VIRTUAL_WALL {
+		t.Errorf("expected virtual wall: %+v", r)
+	}
+	// Facing the dock from its front.
+	r = w.Sense(kinematics.Pose{X: -0.6, Y: 0.5, Theta: math.Pi})
+	all := byte(constants.IR_FORCE_FIELD | constants.IR_RED_BUOY | constants.IR_GREEN_BUOY)
+	if r.IROmni != all || r.IRLeft != all || r.IRRight != all {
+		t.Errorf("expected all dock beams: %+v", r)
+	}
+	// Right of the dock, looking out of it.
+	r = w.Sense(kinematics.Pose{X: 0.5, Y: 0, Theta: 0})
+	if r.IROmni != constants.IR_RED_BUOY || r.IRLeft != 0 || r.IRRight != 0 {
+		t.Errorf("expected red buoy behind the robot: %+v", r)
+	}
+}
+
+func TestObstacleBlocksMotion(t *testing.T) {
+	sim, _ := MakeRoombaSim()
+	defer sim.Stop()
+	sim.SetWorld(loadTestWorld(t))
+	sim.SetPose(kinematics.Pose{X: 0, Y: 0.75})
+	sim.setWheels(kinematics.WheelVelocities{Right: 300, Left: 300})
+	sim.Advance(10 * time.Second)
+	pose := sim.Pose()
+	if pose.X > 1.5-RobotRadius || pose.X < 1.5-RobotRadius-0.01 {
+		t.Errorf("expected robot stopped by the obstacle, got %+v", pose)
+	}
+	if r := sim.world.Sense(pose); !r.BumpLeft || !r.BumpRight {
+		t.Errorf("expected bump against the obstacle: %+v", r)
+	}
+}
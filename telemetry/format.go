@@ -0,0 +1,257 @@
+package telemetry
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/xa4a/go-roomba/constants"
+	"github.com/xa4a/go-roomba/oi"
+)
+
+// Format is the file format of recorded telemetry.
+type Format int
+
+const (
+	// FormatCSV has a header line naming the packets, then a line per
+	// frame with its time and a column per packet.
+	FormatCSV Format = iota
+	// FormatJSONLines has a JSON object per frame, with its time and a
+	// field per packet, in the order they were streamed.
+	FormatJSONLines
+)
+
+// Extension returns the file name extension of the format.
+func (f Format) Extension() string {
+	if f == FormatJSONLines {
+		return ".jsonl"
+	}
+	return ".csv"
+}
+
+// Frame is a stream frame and the time it was received.
+type Frame struct {
+	Time time.Time
+	// The data of each packet, as sent by Roomba.Stream.
+	Packets [][]byte
+}
+
+// timeFormat keeps frames in order when sorted as text.
+const timeFormat = "2006-01-02T15:04:05.000000000Z07:00"
+
+// encodeValue formats the data of a packet as its decoded value, or in hex
+// for packets of more than two bytes.
+func encodeValue(id byte, data []byte) string {
+	if len(data) > 2 {
+		return hex.EncodeToString(data)
+	}
+	return strconv.Itoa(oi.Packet{ID: id, Data: data}.Value())
+}
+
+// decodeValue is the inverse of encodeValue.
+func decodeValue(id byte, s string) ([]byte, error) {
+	n := int(constants.SENSOR_PACKET_LENGTH[id])
+	if n > 2 {
+		return hex.DecodeString(s)
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return nil, err
+	}
+	data := []byte{byte(v >> 8), byte(v)}
+	return data[2-n:], nil
+}
+
+func packetNames(packetIds []byte) []string {
+	names := make([]string, len(packetIds))
+	for i, id := range packetIds {
+		names[i] = oi.Packet{ID: id}.Name()
+	}
+	return names
+}
+
+func packetIds(names []string) ([]byte, error) {
+	ids := make([]byte, len(names))
+	for i, name := range names {
+		id, ok := oi.PacketId(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown packet %q", name)
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+// encoder writes frames to a file.
+type encoder interface {
+	encode(f Frame) error
+}
+
+func makeEncoder(w io.Writer, format Format, packetIds []byte) (encoder, error) {
+	if format == FormatJSONLines {
+		return &jsonEncoder{w, packetNames(packetIds), packetIds}, nil
+	}
+	c := csv.NewWriter(w)
+	if err := c.Write(append([]string{"time"}, packetNames(packetIds)...)); err != nil {
+		return nil, err
+	}
+	c.Flush()
+	return &csvEncoder{c, packetIds}, c.Error()
+}
+
+type csvEncoder struct {
+	w         *csv.Writer
+	packetIds []byte
+}
+
+func (e *csvEncoder) encode(f Frame) error {
+	record := []string{f.Time.Format(timeFormat)}
+	for i, data := range f.Packets {
+		record = append(record, encodeValue(e.packetIds[i], data))
+	}
+	e.w.Write(record)
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type jsonEncoder struct {
+	w         io.Writer
+	names     []string
+	packetIds []byte
+}
+
+func (e *jsonEncoder) encode(f Frame) error {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, `{"time":%q`, f.Time.Format(timeFormat))
+	for i, data := range f.Packets {
+		value := encodeValue(e.packetIds[i], data)
+		if len(data) > 2 {
+			value = strconv.Quote(value)
+		}
+		fmt.Fprintf(buf, ",%q:%s", e.names[i], value)
+	}
+	buf.WriteString("}\n")
+	_, err := e.w.Write(buf.Bytes())
+	return err
+}
+
+// Reader reads frames of recorded telemetry. Should be constructed with
+// MakeReader() function.
+type Reader struct {
+	format    Format
+	csv       *csv.Reader
+	json      *json.Decoder
+	packetIds []byte
+}
+
+// MakeReader creates a Reader of telemetry in the given format.
+func MakeReader(r io.Reader, format Format) *Reader {
+	reader := &Reader{format: format}
+	if format == FormatJSONLines {
+		reader.json = json.NewDecoder(r)
+		reader.json.UseNumber()
+	} else {
+		reader.csv = csv.NewReader(r)
+	}
+	return reader
+}
+
+// PacketIds returns the IDs of the recorded packets, known after the first
+// call to Read.
+func (r *Reader) PacketIds() []byte {
+	return r.packetIds
+}
+
+// Read returns the next frame, or io.EOF at the end of the data.
+func (r *Reader) Read() (Frame, error) {
+	if r.format == FormatJSONLines {
+		return r.readJSON()
+	}
+	return r.readCSV()
+}
+
+func (r *Reader) readCSV() (Frame, error) {
+	if r.packetIds == nil {
+		header, err := r.csv.Read()
+		if err != nil {
+			return Frame{}, err
+		}
+		if len(header) == 0 || header[0] != "time" {
+			return Frame{}, fmt.Errorf("invalid header %v", header)
+		}
+		if r.packetIds, err = packetIds(header[1:]); err != nil {
+			return Frame{}, err
+		}
+	}
+	record, err := r.csv.Read()
+	if err != nil {
+		return Frame{}, err
+	}
+	return r.decodeFrame(record[0], record[1:])
+}
+
+func (r *Reader) readJSON() (Frame, error) {
+	if !r.json.More() {
+		return Frame{}, io.EOF
+	}
+	// Fields are read as tokens, as their order gives the order of the
+	// packets.
+	if _, err := r.json.Token(); err != nil {
+		return Frame{}, err
+	}
+	var names, values []string
+	var t string
+	for r.json.More() {
+		key, err := r.json.Token()
+		if err != nil {
+			return Frame{}, err
+		}
+		value, err := r.json.Token()
+		if err != nil {
+			return Frame{}, err
+		}
+		if key == "time" {
+			t = fmt.Sprint(value)
+			continue
+		}
+		names = append(names, fmt.Sprint(key))
+		values = append(values, fmt.Sprint(value))
+	}
+	if _, err := r.json.Token(); err != nil {
+		return Frame{}, err
+	}
+	ids, err := packetIds(names)
+	if err != nil {
+		return Frame{}, err
+	}
+	if r.packetIds == nil {
+		r.packetIds = ids
+	} else if !bytes.Equal(ids, r.packetIds) {
+		return Frame{}, fmt.Errorf("frame of packets %v in telemetry of %v", ids, r.packetIds)
+	}
+	return r.decodeFrame(t, values)
+}
+
+func (r *Reader) decodeFrame(t string, values []string) (Frame, error) {
+	var f Frame
+	var err error
+	if f.Time, err = time.Parse(timeFormat, t); err != nil {
+		return Frame{}, err
+	}
+	if len(values) != len(r.packetIds) {
+		return Frame{}, fmt.Errorf("frame of %d packets in telemetry of %d", len(values), len(r.packetIds))
+	}
+	for i, value := range values {
+		data, err := decodeValue(r.packetIds[i], value)
+		if err != nil {
+			return Frame{}, fmt.Errorf("invalid value of %s: %s", packetNames(r.packetIds[i : i+1])[0], err)
+		}
+		f.Packets = append(f.Packets, data)
+	}
+	return f, nil
+}
@@ -0,0 +1,171 @@
+/*
+Package telemetry records the sensor data streamed by Roomba.Stream to files,
+for offline analysis, and replays it.
+
+Frames are written with their time and decoded packet values to CSV or JSON
+Lines files, which are rotated by size or age:
+
+	frames, _ := r.Stream(packets)
+	rec, _ := telemetry.MakeRecorder(packets, telemetry.Config{Dir: "logs", MaxAge: time.Hour})
+	go rec.Run(frames)
+
+Replay sends recorded frames to a channel of the same type Roomba.Stream
+returns, so the code processing live data can process recordings too.
+*/
+package telemetry
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/xa4a/go-roomba/clock"
+)
+
+// Config configures a Recorder.
+type Config struct {
+	// Dir is the directory of the files, the current one if empty.
+	Dir string
+	// Prefix of the file names, followed by the time the file was
+	// started. "telemetry" if empty.
+	Prefix string
+	Format Format
+
+	// A new file is started when the current one reaches MaxSize bytes or
+	// MaxAge. Zero values disable rotation.
+	MaxSize int64
+	MaxAge  time.Duration
+
+	// Clock giving the time of frames, the real clock if nil.
+	Clock clock.Clock
+}
+
+// Recorder writes stream frames to files. Should be constructed with
+// MakeRecorder() function.
+type Recorder struct {
+	config    Config
+	packetIds []byte
+
+	mu      sync.Mutex
+	file    *os.File
+	w       *bufio.Writer
+	enc     encoder
+	size    int64
+	started time.Time
+	files   []string
+}
+
+// MakeRecorder creates a Recorder of the frames of a stream of the given
+// packets. The first file is created with the first frame.
+func MakeRecorder(packetIds []byte, config Config) (*Recorder, error) {
+	if config.Prefix == "" {
+		config.Prefix = "telemetry"
+	}
+	config.Clock = clock.OrReal(config.Clock)
+	if config.Dir != "" {
+		if err := os.MkdirAll(config.Dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	return &Recorder{config: config, packetIds: packetIds}, nil
+}
+
+// Run records the frames received from frames, as returned by Roomba.Stream,
+// until the channel is closed, then closes the Recorder.
+func (r *Recorder) Run(frames <-chan [][]byte) error {
+	for frame := range frames {
+		if err := r.Record(frame); err != nil {
+			r.Close()
+			return err
+		}
+	}
+	return r.Close()
+}
+
+// Record writes a frame received now.
+func (r *Recorder) Record(packets [][]byte) error {
+	if len(packets) != len(r.packetIds) {
+		return fmt.Errorf("frame of %d packets, recording %d", len(packets), len(r.packetIds))
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.config.Clock.Now()
+	if r.file == nil || r.rotationDue(now) {
+		if err := r.rotate(now); err != nil {
+			return err
+		}
+	}
+	if err := r.enc.encode(Frame{Time: now, Packets: packets}); err != nil {
+		return err
+	}
+	// Flushed for every frame, so that the file can be followed.
+	return r.w.Flush()
+}
+
+func (r *Recorder) rotationDue(now time.Time) bool {
+	return (r.config.MaxSize > 0 && r.size >= r.config.MaxSize) ||
+		(r.config.MaxAge > 0 && now.Sub(r.started) >= r.config.MaxAge)
+}
+
+// rotate closes the current file and starts a new one. Must be called with
+// r.mu held.
+func (r *Recorder) rotate(now time.Time) error {
+	if err := r.closeFile(); err != nil {
+		return err
+	}
+	// The sequence number keeps names unique and in order if files are
+	// started at the same time.
+	name := fmt.Sprintf("%s-%s-%04d%s", r.config.Prefix, now.UTC().Format("20060102T150405.000000000"),
+		len(r.files), r.config.Format.Extension())
+	path := filepath.Join(r.config.Dir, name)
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	r.file, r.size, r.started = file, 0, now
+	r.w = bufio.NewWriter(countingWriter{file, &r.size})
+	r.files = append(r.files, path)
+	r.enc, err = makeEncoder(r.w, r.config.Format, r.packetIds)
+	return err
+}
+
+func (r *Recorder) closeFile() error {
+	if r.file == nil {
+		return nil
+	}
+	err := r.w.Flush()
+	if cerr := r.file.Close(); err == nil {
+		err = cerr
+	}
+	r.file = nil
+	return err
+}
+
+// Files returns the paths of the files written so far, oldest first.
+func (r *Recorder) Files() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.files...)
+}
+
+// Close closes the current file.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.closeFile()
+}
+
+// countingWriter counts the bytes written to a file.
+type countingWriter struct {
+	f *os.File
+	n *int64
+}
+
+func (w countingWriter) Write(p []byte) (int, error) {
+	n, err := w.f.Write(p)
+	*w.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,151 @@
+package telemetry
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/xa4a/go-roomba/clock"
+)
+
+// ReplayConfig configures Replay.
+type ReplayConfig struct {
+	// OriginalTiming sends frames at the intervals they were recorded at.
+	// Otherwise they are sent as fast as they are received.
+	OriginalTiming bool
+
+	// Clock used for OriginalTiming, the real clock if nil.
+	Clock clock.Clock
+}
+
+// Player sends recorded frames to a channel. Should be constructed with
+// Replay() function.
+type Player struct {
+	// PacketIds are the IDs of the recorded packets.
+	PacketIds []byte
+	// Frames receives the recorded frames, like the channel returned by
+	// Roomba.Stream. It is closed at the end of the recording.
+	Frames <-chan [][]byte
+
+	config  ReplayConfig
+	readers []*Reader
+	closers []io.Closer
+
+	mu  sync.Mutex
+	err error
+}
+
+// Files returns the telemetry files in dir with the given prefix, oldest
+// first.
+func Files(dir, prefix string) ([]string, error) {
+	var paths []string
+	for _, format := range []Format{FormatCSV, FormatJSONLines} {
+		matches, err := filepath.Glob(filepath.Join(dir, prefix+"-*"+format.Extension()))
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, matches...)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// Replay replays the frames of the given files, in order. The format of each
+// file is taken from its extension.
+func Replay(paths []string, config ReplayConfig) (*Player, error) {
+	config.Clock = clock.OrReal(config.Clock)
+	p := &Player{config: config}
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			p.close()
+			return nil, err
+		}
+		format := FormatCSV
+		if filepath.Ext(path) == FormatJSONLines.Extension() {
+			format = FormatJSONLines
+		}
+		p.readers = append(p.readers, MakeReader(f, format))
+		p.closers = append(p.closers, f)
+	}
+
+	// The first frame gives the packets.
+	first, err := p.next()
+	if err != nil && err != io.EOF {
+		p.close()
+		return nil, err
+	}
+	frames := make(chan [][]byte)
+	p.Frames = frames
+	go p.run(first, err == io.EOF, frames)
+	return p, nil
+}
+
+// Err returns the error which ended the replay early, if any, once Frames
+// is closed.
+func (p *Player) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+func (p *Player) run(first Frame, empty bool, frames chan<- [][]byte) {
+	defer close(frames)
+	defer p.close()
+	if empty {
+		return
+	}
+	start := p.config.Clock.Now()
+	for f := first; ; {
+		if p.config.OriginalTiming {
+			due := start.Add(f.Time.Sub(first.Time))
+			if wait := due.Sub(p.config.Clock.Now()); wait > 0 {
+				p.config.Clock.Sleep(wait)
+			}
+		}
+		frames <- f.Packets
+		var err error
+		if f, err = p.next(); err != nil {
+			if err != io.EOF {
+				p.mu.Lock()
+				p.err = err
+				p.mu.Unlock()
+			}
+			return
+		}
+	}
+}
+
+// next reads the next frame, moving on to the next file at the end of one.
+// All files must have the same packets.
+func (p *Player) next() (Frame, error) {
+	for len(p.readers) > 0 {
+		r := p.readers[0]
+		f, err := r.Read()
+		if err == nil {
+			if p.PacketIds == nil {
+				p.PacketIds = r.PacketIds()
+			} else if !bytes.Equal(r.PacketIds(), p.PacketIds) {
+				return Frame{}, fmt.Errorf("packets %v recorded after %v", r.PacketIds(), p.PacketIds)
+			}
+		}
+		if err != io.EOF {
+			return f, err
+		}
+		if len(p.readers) == 1 {
+			break
+		}
+		p.readers = p.readers[1:]
+	}
+	return Frame{}, io.EOF
+}
+
+func (p *Player) close() {
+	for _, c := range p.closers {
+		c.Close()
+	}
+}
@@ -0,0 +1,161 @@
+package telemetry_test
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xa4a/go-roomba"
+	"github.com/xa4a/go-roomba/clock"
+	"github.com/xa4a/go-roomba/constants"
+	"github.com/xa4a/go-roomba/sim"
+	"github.com/xa4a/go-roomba/telemetry"
+)
+
+var packets = []byte{
+	constants.SENSOR_BUMP_WHEELS_DROPS,
+	constants.SENSOR_DISTANCE,
+	constants.SENSOR_REQUESTED_VELOCITY,
+	constants.SENSOR_TEMPERATURE,
+	constants.SENSOR_VOLTAGE,
+	16, // Unused, recorded as bytes.
+}
+
+func replayAll(t *testing.T, paths []string, config telemetry.ReplayConfig) [][][]byte {
+	p, err := telemetry.Replay(paths, config)
+	if err != nil {
+		t.Fatalf("failed replaying: %s", err)
+	}
+	var frames [][][]byte
+	for f := range p.Frames {
+		frames = append(frames, f)
+	}
+	if err := p.Err(); err != nil {
+		t.Fatalf("replay failed: %s", err)
+	}
+	if !reflect.DeepEqual(p.PacketIds, packets) {
+		t.Errorf("replayed packets %v, want %v", p.PacketIds, packets)
+	}
+	return frames
+}
+
+func TestRecordStream(t *testing.T) {
+	roombaSim, socket := sim.MakeRoombaSim()
+	defer roombaSim.Stop()
+	r := &roomba.Roomba{S: socket, StreamPaused: make(chan bool, 1)}
+	r.Start()
+	r.Safe()
+	r.Drive(-100, 200)
+	stream, err := r.Stream(packets)
+	if err != nil {
+		t.Fatalf("failed starting stream: %s", err)
+	}
+	var recorded [][][]byte
+	frames := make(chan [][]byte)
+	go func() {
+		for i := 0; i < 10; i++ {
+			f := <-stream
+			recorded = append(recorded, f)
+			frames <- f
+		}
+		close(frames)
+	}()
+
+	dir := t.TempDir()
+	csvRec, _ := telemetry.MakeRecorder(packets, telemetry.Config{Dir: dir})
+	jsonRec, _ := telemetry.MakeRecorder(packets, telemetry.Config{Dir: dir, Prefix: "json",
+		Format: telemetry.FormatJSONLines})
+	csvFrames := make(chan [][]byte)
+	done := make(chan error)
+	go func() { done <- csvRec.Run(csvFrames) }()
+	go func() {
+		for f := range frames {
+			csvFrames <- f
+			if err := jsonRec.Record(f); err != nil {
+				t.Errorf("failed recording: %s", err)
+			}
+		}
+		close(csvFrames)
+	}()
+	if err := <-done; err != nil {
+		t.Fatalf("failed recording: %s", err)
+	}
+	jsonRec.Close()
+
+	data, _ := os.ReadFile(csvRec.Files()[0])
+	lines := strings.Split(string(data), "\n")
+	if want := "time,bump_wheels_drops,distance,requested_velocity,temperature,voltage,packet_16"; lines[0] != want {
+		t.Errorf("got header %q, want %q", lines[0], want)
+	}
+	if !strings.Contains(lines[1], ",-100,") || !strings.HasSuffix(lines[1], ",000000") {
+		t.Errorf("got line %q", lines[1])
+	}
+	data, _ = os.ReadFile(jsonRec.Files()[0])
+	if !strings.Contains(string(data), `"requested_velocity":-100,`) || !strings.Contains(string(data), `"packet_16":"000000"}`) {
+		t.Errorf("got JSON lines\n%s", data)
+	}
+
+	for _, files := range [][]string{csvRec.Files(), jsonRec.Files()} {
+		if frames := replayAll(t, files, telemetry.ReplayConfig{}); !reflect.DeepEqual(frames, recorded) {
+			t.Errorf("replayed %v, recorded %v", frames, recorded)
+		}
+	}
+}
+
+func TestRotation(t *testing.T) {
+	clk := clock.MakeVirtual(time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC))
+	dir := t.TempDir()
+	rec, _ := telemetry.MakeRecorder(packets, telemetry.Config{Dir: dir, MaxAge: time.Second, Clock: clk})
+	var recorded [][][]byte
+	for i := 0; i < 10; i++ {
+		frame := [][]byte{{byte(i)}, {0, byte(i)}, {0xff, 0x9c}, {byte(-i)}, {0x3a, byte(i)}, {1, 2, 3}}
+		if err := rec.Record(frame); err != nil {
+			t.Fatalf("failed recording: %s", err)
+		}
+		recorded = append(recorded, frame)
+		clk.Advance(300 * time.Millisecond)
+	}
+	rec.Close()
+
+	// Files are started at 0, 1.2 and 2.4 s.
+	files, _ := telemetry.Files(dir, "telemetry")
+	if !reflect.DeepEqual(files, rec.Files()) || len(files) != 3 {
+		t.Fatalf("got files %v, recorded %v", files, rec.Files())
+	}
+
+	// By size, the file is rotated after a frame takes it over MaxSize.
+	sizeRec, _ := telemetry.MakeRecorder(packets, telemetry.Config{Dir: dir, Prefix: "size", MaxSize: 1, Clock: clk})
+	for _, frame := range recorded[:3] {
+		sizeRec.Record(frame)
+	}
+	sizeRec.Close()
+	if files := sizeRec.Files(); len(files) != 3 {
+		t.Errorf("got files %v, want one per frame", files)
+	}
+
+	// With the original timing, frames come 300 ms apart.
+	p, err := telemetry.Replay(files, telemetry.ReplayConfig{OriginalTiming: true, Clock: clk})
+	if err != nil {
+		t.Fatalf("failed replaying: %s", err)
+	}
+	if f := <-p.Frames; !reflect.DeepEqual(f, recorded[0]) {
+		t.Errorf("replayed %v, recorded %v", f, recorded[0])
+	}
+	for i := 1; i < len(recorded); i++ {
+		<-clk.Blocked(1)
+		select {
+		case <-p.Frames:
+			t.Fatalf("frame %d replayed early", i)
+		default:
+		}
+		clk.Advance(300 * time.Millisecond)
+		if f := <-p.Frames; !reflect.DeepEqual(f, recorded[i]) {
+			t.Errorf("replayed %v, recorded %v", f, recorded[i])
+		}
+	}
+	if _, ok := <-p.Frames; ok {
+		t.Errorf("frames not closed at the end")
+	}
+}
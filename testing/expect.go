@@ -0,0 +1,230 @@
+package testing
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"testing"
+	"text/tabwriter"
+	"time"
+
+	"github.com/xa4a/go-roomba/constants"
+	"github.com/xa4a/go-roomba/oi"
+	"github.com/xa4a/go-roomba/sim"
+)
+
+// DefaultTimeout is how long Expect and VerifyWritten wait for the simulator
+// to receive the expected bytes.
+const DefaultTimeout = time.Second
+
+// Command is a command sent to the robot, used to state expectations in
+// Harness.Expect.
+type Command struct {
+	Opcode byte
+	Args   []byte
+}
+
+// Cmd returns the command with the given name from constants.OpCodes and
+// raw argument bytes. It panics on unknown names.
+func Cmd(name string, args ...byte) Command {
+	opcode, ok := constants.OpCodes[name]
+	if !ok {
+		panic(fmt.Sprintf("unknown command %q", name))
+	}
+	return Command{opcode, args}
+}
+
+// Constructors of the common commands, matching the client's methods. Other
+// commands can be built with Cmd.
+
+func Start() Command { return Cmd("Start") }
+func Safe() Command  { return Cmd("Safe") }
+func Full() Command  { return Cmd("Full") }
+
+func Drive(velocity, radius int16) Command {
+	return Cmd("Drive", pack(velocity, radius)...)
+}
+
+func DirectDrive(right, left int16) Command {
+	return Cmd("DirectDrive", pack(right, left)...)
+}
+
+func DrivePWM(right, left int16) Command {
+	return Cmd("DrivePwm", pack(right, left)...)
+}
+
+func Sensors(packetId byte) Command {
+	return Cmd("Sensors", packetId)
+}
+
+func QueryList(packetIds ...byte) Command {
+	return Cmd("QueryList", append([]byte{byte(len(packetIds))}, packetIds...)...)
+}
+
+func Stream(packetIds ...byte) Command {
+	return Cmd("Stream", append([]byte{byte(len(packetIds))}, packetIds...)...)
+}
+
+func ResumeStream(resume bool) Command {
+	if resume {
+		return Cmd("ResumeStream", 1)
+	}
+	return Cmd("ResumeStream", 0)
+}
+
+func pack(values ...int16) []byte {
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.BigEndian, values)
+	return buf.Bytes()
+}
+
+// Name returns the name of the command in constants.OpCodes.
+func (c Command) Name() string {
+	return oi.Name(c.Opcode)
+}
+
+// String formats the command like the expectation constructors, e.g.
+// "Drive(-200, 500)".
+func (c Command) String() string {
+	var args []string
+	switch c.Opcode {
+	case constants.OpCodes["Drive"], constants.OpCodes["DirectDrive"], constants.OpCodes["DrivePwm"]:
+		values := make([]int16, len(c.Args)/2)
+		binary.Read(bytes.NewReader(c.Args), binary.BigEndian, values)
+		for _, v := range values {
+			args = append(args, fmt.Sprint(v))
+		}
+	case constants.OpCodes["QueryList"], constants.OpCodes["Stream"]:
+		if len(c.Args) == 0 {
+			break
+		}
+		for _, id := range c.Args[1:] {
+			args = append(args, fmt.Sprint(id))
+		}
+	default:
+		for _, b := range c.Args {
+			args = append(args, fmt.Sprint(b))
+		}
+	}
+	return fmt.Sprintf("%s(%s)", c.Name(), strings.Join(args, ", "))
+}
+
+// Equal reports whether both commands have the same opcode and arguments.
+func (c Command) Equal(other Command) bool {
+	return c.Opcode == other.Opcode && bytes.Equal(c.Args, other.Args)
+}
+
+// decodeCommands splits data into complete commands. It returns the commands
+// and the number of bytes each of them takes.
+func decodeCommands(data []byte) ([]Command, []int) {
+	decoded, _ := oi.DecodeCommands(data)
+	cmds := make([]Command, len(decoded))
+	sizes := make([]int, len(decoded))
+	for i, c := range decoded {
+		cmds[i] = Command{c.Opcode, c.Args}
+		sizes[i] = 1 + len(c.Args)
+	}
+	return cmds, sizes
+}
+
+// Expect waits until the simulator has received the expected commands, in
+// order, after everything checked by earlier calls to Expect or
+// VerifyWritten. It fails the test with a decoded diff if other commands
+// arrive or the expected ones don't arrive within Timeout.
+//
+//	h.Expect(rt.Drive(-200, 500), rt.Sensors(7))
+func (h *Harness) Expect(expected ...Command) {
+	h.T.Helper()
+	deadline := time.Now().Add(h.timeout())
+	for {
+		received, sizes := decodeCommands(h.Sim.Received()[h.checked:])
+		n := len(received)
+		if n > len(expected) {
+			n = len(expected)
+		}
+		for i := 0; i < n; i++ {
+			if !received[i].Equal(expected[i]) {
+				h.T.Errorf("unexpected commands received by the simulator:\n%s",
+					commandDiff(expected, received))
+				return
+			}
+		}
+		if n == len(expected) {
+			for _, size := range sizes[:n] {
+				h.checked += size
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			h.T.Errorf("timed out after %v waiting for commands:\n%s",
+				h.timeout(), commandDiff(expected, received))
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (h *Harness) timeout() time.Duration {
+	if h.Timeout == 0 {
+		return DefaultTimeout
+	}
+	return h.Timeout
+}
+
+// commandDiff lists expected and received commands side by side, marking
+// the differences with "!".
+func commandDiff(expected, received []Command) string {
+	buf := &bytes.Buffer{}
+	w := tabwriter.NewWriter(buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "  expected\treceived")
+	for i := 0; i < len(expected) || i < len(received); i++ {
+		e, r := "-", "-"
+		if i < len(expected) {
+			e = expected[i].String()
+		}
+		if i < len(received) {
+			r = received[i].String()
+		}
+		mark := " "
+		if e != r {
+			mark = "!"
+		}
+		fmt.Fprintf(w, "%s %s\t%s\n", mark, e, r)
+	}
+	w.Flush()
+	return buf.String()
+}
+
+// verifyWritten waits until the simulator has received len(expected) bytes
+// after the first *checked ones and compares them with expected.
+func verifyWritten(t testing.TB, s *sim.RoombaSimulator, checked *int, timeout time.Duration, expected []byte) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	received := s.Received()[*checked:]
+	for len(received) < len(expected) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+		received = s.Received()[*checked:]
+	}
+	actual := received
+	if len(actual) > len(expected) {
+		actual = actual[:len(expected)]
+	}
+	*checked += len(actual)
+	t.Logf("Actual: %v", actual)
+
+	expectedCmds, _ := decodeCommands(expected)
+	actualCmds, _ := decodeCommands(actual)
+	if len(actual) != len(expected) {
+		t.Errorf("actual written length (%d) doesn't match expected (%d).\n%s",
+			len(actual), len(expected), commandDiff(expectedCmds, actualCmds))
+		return
+	}
+	for i, b := range expected {
+		if b != actual[i] {
+			t.Errorf("Expected output: % d, actual output: % d. Byte %d doesn't match\n%s",
+				expected, actual, i, commandDiff(expectedCmds, actualCmds))
+			return
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package testing_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xa4a/go-roomba/constants"
+	rt "github.com/xa4a/go-roomba/testing"
+)
+
+// recordingT records failures instead of failing the test.
+type recordingT struct {
+	testing.TB
+	errors []string
+}
+
+func (t *recordingT) Helper()                                 {}
+func (t *recordingT) Logf(format string, args ...interface{}) {}
+func (t *recordingT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestExpect(t *testing.T) {
+	t.Parallel()
+	h := rt.NewHarness(t)
+	h.Roomba.Drive(-200, 500)
+	h.Roomba.Sensors(constants.SENSOR_BUMP_WHEELS_DROPS)
+	h.Roomba.QueryList([]byte{constants.SENSOR_DISTANCE, constants.SENSOR_ANGLE})
+	h.Expect(rt.Drive(-200, 500), rt.Sensors(7))
+	h.Expect(rt.QueryList(constants.SENSOR_DISTANCE, constants.SENSOR_ANGLE))
+}
+
+func TestExpectMismatch(t *testing.T) {
+	t.Parallel()
+	h := rt.NewHarness(t)
+	rec := &recordingT{TB: t}
+	h.T = rec
+	h.Roomba.Drive(-200, 500)
+	h.Roomba.Sensors(constants.SENSOR_WALL)
+	h.Expect(rt.Drive(-200, 500), rt.Sensors(7))
+
+	if len(rec.errors) != 1 {
+		t.Fatalf("got %d errors, want 1: %v", len(rec.errors), rec.errors)
+	}
+	for _, line := range []string{
+		"  Drive(-200, 500)  Drive(-200, 500)",
+		"! Sensors(7)        Sensors(8)",
+	} {
+		if !strings.Contains(rec.errors[0], line) {
+			t.Errorf("diff doesn't contain %q:\n%s", line, rec.errors[0])
+		}
+	}
+}
+
+func TestExpectTimeout(t *testing.T) {
+	t.Parallel()
+	h := rt.NewHarness(t)
+	rec := &recordingT{TB: t}
+	h.T = rec
+	h.Timeout = 10 * time.Millisecond
+	h.Roomba.Start()
+	h.Expect(rt.Start(), rt.Safe())
+
+	if len(rec.errors) != 1 || !strings.Contains(rec.errors[0], "! Safe()    -") {
+		t.Errorf("unexpected errors: %v", rec.errors)
+	}
+}
@@ -0,0 +1,45 @@
+package testing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/xa4a/go-roomba"
+	"github.com/xa4a/go-roomba/constants"
+	"github.com/xa4a/go-roomba/sim"
+)
+
+// Harness is a client connected to its own simulator, for a single test.
+// Unlike MakeTestRoomba, harnesses share no state, so tests using them can
+// run in parallel. Should be constructed with NewHarness() function.
+type Harness struct {
+	T      testing.TB
+	Roomba *roomba.Roomba
+	Sim    *sim.RoombaSimulator
+
+	// Timeout of Expect and VerifyWritten, DefaultTimeout if zero.
+	Timeout time.Duration
+
+	checked int // Received bytes already checked.
+}
+
+// NewHarness creates a client and a simulator for the test t. The simulator
+// starts in Safe mode, so that the test only sees the bytes it sends, and is
+// stopped when the test completes.
+func NewHarness(t testing.TB) *Harness {
+	s, socket := sim.MakeRoombaSim()
+	t.Cleanup(s.Stop)
+	s.SetMode(constants.OI_MODE_SAFE)
+	return &Harness{
+		T:      t,
+		Roomba: &roomba.Roomba{S: socket, StreamPaused: make(chan bool, 1)},
+		Sim:    s,
+	}
+}
+
+// VerifyWritten waits until the simulator has received len(expected) more
+// bytes and checks that they match expected.
+func (h *Harness) VerifyWritten(expected []byte) {
+	h.T.Helper()
+	verifyWritten(h.T, h.Sim, &h.checked, h.timeout(), expected)
+}
@@ -0,0 +1,29 @@
+package testing_test
+
+import (
+	"testing"
+
+	"github.com/xa4a/go-roomba/constants"
+	rt "github.com/xa4a/go-roomba/testing"
+)
+
+func TestHarnessesAreIndependent(t *testing.T) {
+	for _, velocity := range []int16{-200, 100, 300} {
+		velocity := velocity
+		t.Run("", func(t *testing.T) {
+			t.Parallel()
+			h := rt.NewHarness(t)
+			h.Roomba.DriveStraight(velocity)
+			requested, err := h.Roomba.Sensors(constants.SENSOR_REQUESTED_VELOCITY)
+			if err != nil {
+				t.Fatalf("error querying sensors: %s", err)
+			}
+			if got := int16(requested[0])<<8 | int16(requested[1]); got != velocity {
+				t.Errorf("requested velocity %d, want %d", got, velocity)
+			}
+			if right := h.Sim.Physics().Target.Right; right != float64(velocity) {
+				t.Errorf("simulator drives at %f, want %d", right, velocity)
+			}
+		})
+	}
+}
@@ -1,21 +1,25 @@
 package testing
 
 import (
-	"fmt"
 	"io"
 	"testing"
 
 	"github.com/xa4a/go-roomba"
+	"github.com/xa4a/go-roomba/constants"
 	"github.com/xa4a/go-roomba/sim"
 )
 
 var roombaSim *sim.RoombaSimulator
 var mockRoombaClient *roomba.Roomba
+var verified int // Bytes received by roombaSim already verified.
 
 func MakeTestRoomba() *roomba.Roomba {
 	if mockRoombaClient == nil {
 		var socket io.ReadWriter
 		roombaSim, socket = sim.MakeRoombaSim()
+		// Skip the mode commands, so that tests only see the bytes
+		// they send.
+		roombaSim.SetMode(constants.OI_MODE_SAFE)
 
 		mockRoombaClient = &roomba.Roomba{S: socket, StreamPaused: make(chan bool, 1)}
 	}
@@ -24,23 +28,12 @@ func MakeTestRoomba() *roomba.Roomba {
 
 func ClearTestRoomba() {
 	mockRoombaClient = nil
+	verified = 0
 	roombaSim.Stop()
 	roombaSim = nil
 }
 
 func VerifyWritten(r *roomba.Roomba, expected []byte, t *testing.T) {
-	actual := make([]byte, len(expected))
-	roombaSim.ReadBytes.Read(actual)
-	fmt.Println("Actual: ", actual)
-
-	if len(actual) != len(expected) {
-		t.Errorf("actual written length (%d) doesn't match expected (%d).",
-			len(actual), len(expected))
-	}
-	for i, b := range expected {
-		if b != actual[i] {
-			t.Errorf("Expected output: % d, actual output: % d. Byte %d doesn't match",
-				expected, actual, i)
-		}
-	}
+	t.Helper()
+	verifyWritten(t, roombaSim, &verified, DefaultTimeout, expected)
 }